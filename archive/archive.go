@@ -0,0 +1,18 @@
+package archive
+
+import "context"
+
+type ctxKey int
+
+const includedKey ctxKey = iota
+
+// WithArchived returns a copy of ctx whose lookups of orders also find the orders moved to the archive
+func WithArchived(ctx context.Context) context.Context {
+	return context.WithValue(ctx, includedKey, true)
+}
+
+// Included reports whether the lookups of ctx include archived orders
+func Included(ctx context.Context) bool {
+	included, _ := ctx.Value(includedKey).(bool)
+	return included
+}
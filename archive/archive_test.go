@@ -0,0 +1,13 @@
+package archive
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncluded(t *testing.T) {
+	assert.True(t, Included(WithArchived(context.Background())))
+	assert.False(t, Included(context.Background()))
+}
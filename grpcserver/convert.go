@@ -0,0 +1,48 @@
+package grpcserver
+
+import (
+	"encoding/json"
+
+	"order-service/models"
+	orderv1 "order-service/proto/order/v1"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// toOrder converts an order, its sequential id is only sent while legacyIds accepts it in requests,
+// otherwise it would let callers count and enumerate the orders
+func toOrder(order *models.Order, legacyIds bool) *orderv1.Order {
+	msg := &orderv1.Order{
+		Distance: int32(order.Distance),
+		Status:   order.Status,
+		PublicId: order.PublicId,
+	}
+	if legacyIds {
+		msg.Id = order.Id
+	}
+
+	return msg
+}
+
+// toOrderEvent converts an event, its payload is the JSON of the order at the time of the event
+func toOrderEvent(event models.Event, legacyIds bool) (*orderv1.OrderEvent, error) {
+	var payload struct {
+		models.Order
+		// the sequential id in events written before public ids, shadowed so it does not fail the decoding
+		Id json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return nil, err
+	}
+
+	order := payload.Order
+	order.Id = event.OrderId
+	order.PublicId = event.OrderPublicId()
+
+	return &orderv1.OrderEvent{
+		Id:        event.Id,
+		Type:      event.Type,
+		Order:     toOrder(&order, legacyIds),
+		CreatedAt: timestamppb.New(event.CreatedAt),
+	}, nil
+}
@@ -0,0 +1,34 @@
+package grpcserver
+
+import (
+	"order-service/models"
+	srvorder "order-service/services"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatus maps the errors of the services to gRPC status codes,
+// other errors are reported as INTERNAL with message instead of their own
+func toStatus(err error, message string) error {
+	switch {
+	case err == models.ErrNotFound:
+		return status.Error(codes.NotFound, "Order not found")
+	case err == srvorder.ErrOrderAlreadyTaken:
+		return status.Error(codes.FailedPrecondition, "Order already taken")
+	case err == srvorder.ErrInvalidOrderId:
+		return status.Error(codes.InvalidArgument, "public_id is not an order id")
+	case err == srvorder.ErrCannotCalculateDistance:
+		return status.Error(codes.InvalidArgument, srvorder.ErrCannotCalculateDistance.Error())
+	case err == srvorder.ErrIdempotencyKeyReused:
+		return status.Error(codes.AlreadyExists, "idempotency-key already used for a different request")
+	case err == srvorder.ErrRequestInProgress:
+		return status.Error(codes.Aborted, "Request with the same idempotency-key is in progress")
+	case err == models.ErrNoTenant:
+		return status.Error(codes.Unauthenticated, "Missing merchant")
+	case srvorder.IsTimeout(err):
+		return status.Error(codes.DeadlineExceeded, "Request timed out")
+	default:
+		return status.Error(codes.Internal, message)
+	}
+}
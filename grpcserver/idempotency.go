@@ -0,0 +1,99 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"order-service/logger"
+	orderv1 "order-service/proto/order/v1"
+	"order-service/services/idempotency"
+	"order-service/tenant"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// metadata keys of idempotent calls, like the headers of the REST API
+const (
+	idempotencyKeyMetadata     = "idempotency-key"
+	idempotentReplayedMetadata = "idempotent-replayed"
+)
+
+// idempotentMethods creates an empty response of each method which honours an idempotency key
+var idempotentMethods = map[string]func() proto.Message{
+	"/order.v1.OrderService/PlaceOrder": func() proto.Message { return new(orderv1.Order) },
+}
+
+// idempotencyInterceptor makes a call sent with an idempotency-key metadata entry safe to retry, the response
+// of the first call is stored and returned again for retries with the same key and the same request.
+// A failed call releases the key, so the client can retry it. It runs after the caller is authenticated,
+// since keys are scoped to the merchant.
+func (s *server) idempotencyInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	newResponse, ok := idempotentMethods[info.FullMethod]
+	md, _ := metadata.FromIncomingContext(ctx)
+	key := first(md, idempotencyKeyMetadata)
+	if !ok || key == "" || s.opts.IdempotencyService == nil {
+		return handler(ctx, req)
+	}
+
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "grpc", "method": "Idempotency", "idempotency_key": key})
+
+	if len(key) > idempotency.MaxKeyLength {
+		return nil, status.Error(codes.InvalidArgument, "idempotency-key must be at most 255 characters")
+	}
+
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(req.(proto.Message))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Failed to read request")
+	}
+	hash := sha256.Sum256(body)
+
+	record, err := s.opts.IdempotencyService.Begin(ctx, key, hex.EncodeToString(hash[:]))
+	if err != nil {
+		return nil, toStatus(err, "Service unavailable")
+	}
+
+	if record.Completed() {
+		log.Debug("Replaying stored response")
+
+		resp := newResponse()
+		if err := proto.Unmarshal(record.Response, resp); err != nil {
+			log.WithError(err).Error("Failed to decode stored response")
+			return nil, status.Error(codes.Internal, "Service unavailable")
+		}
+		grpc.SetHeader(ctx, metadata.Pairs(idempotentReplayedMetadata, "true"))
+		return resp, nil
+	}
+
+	resp, err := handler(ctx, req)
+
+	// the call context may already be done, store the outcome on a fresh one
+	c := logger.WithRequestId(context.Background(), logger.RequestId(ctx))
+	if merchantId, ok := tenant.MerchantId(ctx); ok {
+		c = tenant.WithMerchantId(c, merchantId)
+	}
+
+	if err != nil {
+		if err := s.opts.IdempotencyService.Release(c, key); err != nil {
+			log.WithError(err).Error("Failed to release idempotency key")
+		}
+		return nil, err
+	}
+
+	response, err := proto.Marshal(resp.(proto.Message))
+	if err == nil {
+		// the record is shared with the REST API, which stores the status of its responses
+		err = s.opts.IdempotencyService.Complete(c, key, http.StatusOK, response)
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to store response for idempotency key")
+	}
+
+	return resp, nil
+}
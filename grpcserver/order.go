@@ -0,0 +1,205 @@
+package grpcserver
+
+import (
+	"context"
+	"time"
+
+	"order-service/archive"
+	"order-service/logger"
+	"order-service/models"
+	orderv1 "order-service/proto/order/v1"
+	"order-service/replica"
+	srvorder "order-service/services"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// watchBatchSize is the maximum number of events read on each poll of WatchOrders
+const watchBatchSize = 100
+
+var validate = validator.New()
+
+type orderServer struct {
+	orderv1.UnimplementedOrderServiceServer
+
+	orderService srvorder.OrderService
+	eventService srvorder.OrderEventService
+	pollInterval time.Duration
+	settleWindow time.Duration
+	legacyIds    bool
+}
+
+func (s *orderServer) PlaceOrder(ctx context.Context, req *orderv1.PlaceOrderRequest) (*orderv1.Order, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "grpc", "method": "PlaceOrder"})
+
+	if !validLocation(req.Origin) || !validLocation(req.Destination) {
+		return nil, status.Error(codes.InvalidArgument, "Invalid origin or destination")
+	}
+
+	origin := []string{req.Origin.Lat, req.Origin.Lng}
+	destination := []string{req.Destination.Lat, req.Destination.Lng}
+
+	log = log.WithFields(logrus.Fields{"origin": origin, "destination": destination})
+
+	order, err := s.orderService.PlaceOrder(ctx, origin, destination)
+	if err != nil {
+		log.WithField("err", err).Error("Failed to place order")
+		return nil, toStatus(err, "Failed to place order")
+	}
+
+	log.WithField("order_id", order.Id).Debug("Successfully created order")
+	return toOrder(order, s.legacyIds), nil
+}
+
+func (s *orderServer) GetOrder(ctx context.Context, req *orderv1.GetOrderRequest) (*orderv1.Order, error) {
+	if req.IncludeArchived {
+		ctx = archive.WithArchived(ctx)
+	}
+
+	order, err := s.findOrder(ctx, req.Id, req.PublicId)
+	if err != nil {
+		if err != models.ErrNotFound && err != srvorder.ErrInvalidOrderId {
+			logger.FromContext(ctx).WithFields(logrus.Fields{"module": "grpc", "method": "GetOrder", "order_id": req.Id, "public_id": req.PublicId}).
+				WithField("err", err).Error("Failed to find order")
+		}
+		return nil, toStatus(err, "Failed to find order")
+	}
+
+	return toOrder(order, s.legacyIds), nil
+}
+
+func (s *orderServer) TakeOrder(ctx context.Context, req *orderv1.TakeOrderRequest) (*orderv1.Order, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "grpc", "method": "TakeOrder", "order_id": req.Id, "public_id": req.PublicId})
+
+	// the order is read from the primary, a lagging replica could report it missing or taken
+	order, err := s.findOrder(replica.WithPrimary(ctx), req.Id, req.PublicId)
+	if err != nil {
+		if err != models.ErrNotFound && err != srvorder.ErrInvalidOrderId {
+			log.WithField("err", err).Error("Failed to find order")
+		}
+		return nil, toStatus(err, "Failed to find order")
+	}
+
+	order, err = s.orderService.TakeOrder(ctx, order)
+	if err != nil {
+		log.WithField("err", err).Error("Failed to take order")
+		return nil, toStatus(err, "Failed to take order")
+	}
+
+	log.Debug("Successfully took order")
+	return toOrder(order, s.legacyIds), nil
+}
+
+// findOrder loads the order of a request by its public id, or by its sequential id while legacy ids are accepted
+func (s *orderServer) findOrder(ctx context.Context, id int64, publicId string) (*models.Order, error) {
+	if publicId != "" {
+		return srvorder.FindOrder(ctx, s.orderService, publicId, false)
+	}
+	if !s.legacyIds {
+		return nil, srvorder.ErrInvalidOrderId
+	}
+
+	return s.orderService.GetById(ctx, id)
+}
+
+func (s *orderServer) ListOrders(ctx context.Context, req *orderv1.ListOrdersRequest) (*orderv1.ListOrdersResponse, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "grpc", "method": "ListOrders", "page": req.Page, "limit": req.Limit})
+
+	if req.Page < 1 {
+		return nil, status.Error(codes.InvalidArgument, "page should be greater or equal to 1")
+	}
+	if req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit should be greater or equal to 0")
+	}
+
+	limit := int(req.Limit)
+	if limit > srvorder.MaxPageLimit {
+		limit = srvorder.MaxPageLimit
+	}
+
+	if req.IncludeArchived {
+		ctx = archive.WithArchived(ctx)
+	}
+
+	orders, err := s.orderService.ListOrders(ctx, int(req.Page-1)*limit, limit)
+	if err != nil {
+		log.WithField("err", err).Error("Failed to retrieve orders")
+		return nil, toStatus(err, "Failed to retrieve orders")
+	}
+
+	resp := &orderv1.ListOrdersResponse{Orders: make([]*orderv1.Order, 0, len(orders))}
+	for i := range orders {
+		resp.Orders = append(resp.Orders, toOrder(&orders[i], s.legacyIds))
+	}
+
+	return resp, nil
+}
+
+func (s *orderServer) WatchOrders(req *orderv1.WatchOrdersRequest, stream orderv1.OrderService_WatchOrdersServer) error {
+	ctx := stream.Context()
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "grpc", "method": "WatchOrders"})
+
+	var area *models.Area
+	if req.Area != nil {
+		if validate.Var(req.Area.Lat, "latitude") != nil || validate.Var(req.Area.Lng, "longitude") != nil || req.Area.Radius <= 0 {
+			return status.Error(codes.InvalidArgument, "Invalid area")
+		}
+		area = &models.Area{Lat: req.Area.Lat, Lng: req.Area.Lng, Radius: req.Area.Radius}
+	}
+
+	var after int64
+	if req.AfterEventId != nil {
+		after = req.GetAfterEventId()
+	} else {
+		latest, err := s.eventService.LatestEventId(ctx)
+		if err != nil {
+			log.WithField("err", err).Error("Failed to open order stream")
+			return toStatus(err, "Failed to open order stream")
+		}
+		after = latest
+	}
+	cursor := srvorder.NewEventCursor(after, s.settleWindow)
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			events, err := cursor.Next(ctx, s.eventService, watchBatchSize)
+			if err != nil {
+				// the client resumes after the last event it received
+				log.WithField("err", err).Error("Failed to poll order stream")
+				return toStatus(err, "Failed to poll order stream")
+			}
+
+			for _, event := range events {
+				if area != nil && !area.Contains(event.Origins[0], event.Origins[1]) {
+					continue
+				}
+
+				msg, err := toOrderEvent(event, s.legacyIds)
+				if err != nil {
+					log.WithFields(logrus.Fields{"err": err, "event_id": event.Id}).Error("Failed to decode event")
+					continue
+				}
+				msg.ResumeAfterEventId = cursor.ResumeId(event)
+
+				if err := stream.Send(msg); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func validLocation(location *orderv1.Location) bool {
+	return location != nil &&
+		validate.Var(location.Lat, "latitude") == nil &&
+		validate.Var(location.Lng, "longitude") == nil
+}
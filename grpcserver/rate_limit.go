@@ -0,0 +1,83 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	"order-service/metrics"
+	"order-service/services/ratelimit"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// metadata keys of the rate limit sent back to the client, like the headers of the REST API
+const (
+	rateLimitLimitMetadata     = "x-ratelimit-limit"
+	rateLimitRemainingMetadata = "x-ratelimit-remaining"
+	rateLimitResetMetadata     = "x-ratelimit-reset"
+	retryAfterMetadata         = "retry-after"
+)
+
+// limits of each method, the same as the matching REST routes
+var methodRateLimits = map[string]string{
+	"/order.v1.OrderService/PlaceOrder": ratelimit.PlaceOrder,
+	"/order.v1.OrderService/GetOrder":   ratelimit.GetOrder,
+	"/order.v1.OrderService/TakeOrder":  ratelimit.TakeOrder,
+	"/order.v1.OrderService/ListOrders": ratelimit.ListOrders,
+}
+
+// rateLimitInterceptor limits the calls each client makes to a method against the buckets of its IP and
+// api key, the same buckets as the REST routes. Like the REST API it runs before the caller is authenticated.
+func (s *server) rateLimitInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	route, ok := methodRateLimits[info.FullMethod]
+	limit := s.opts.RateLimits[route]
+	if !ok || s.opts.RateLimiter == nil || !limit.Enabled() {
+		return handler(ctx, req)
+	}
+
+	ctx = withRequestId(ctx)
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	keys := ratelimit.ClientKeys(peerIP(ctx), first(md, apiKeyMetadata))
+	tightest := ratelimit.Allow(ctx, s.opts.RateLimiter, route, keys, limit)
+	if tightest == nil {
+		return handler(ctx, req)
+	}
+
+	header := metadata.Pairs(
+		rateLimitLimitMetadata, strconv.Itoa(tightest.Limit),
+		rateLimitRemainingMetadata, strconv.Itoa(tightest.Remaining),
+		rateLimitResetMetadata, strconv.Itoa(ratelimit.CeilSeconds(tightest.ResetAfter)),
+	)
+
+	if !tightest.Allowed {
+		metrics.RateLimitedRequests.WithLabelValues(route).Inc()
+
+		header.Set(retryAfterMetadata, strconv.Itoa(ratelimit.CeilSeconds(tightest.RetryAfter)))
+		grpc.SetHeader(ctx, header)
+		return nil, status.Error(codes.ResourceExhausted, "Too many requests")
+	}
+
+	grpc.SetHeader(ctx, header)
+	return handler(ctx, req)
+}
+
+// peerIP is the IP of the client of a call, or its whole address if it has no port
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return addr
+}
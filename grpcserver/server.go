@@ -0,0 +1,185 @@
+package grpcserver
+
+import (
+	"context"
+	"time"
+
+	"order-service/logger"
+	"order-service/models"
+	orderv1 "order-service/proto/order/v1"
+	srvorder "order-service/services"
+	srvauth "order-service/services/auth"
+	"order-service/tenant"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// metadata keys read from the incoming calls
+const (
+	apiKeyMetadata        = "x-api-key"
+	authorizationMetadata = "authorization"
+	requestIdMetadata     = "x-request-id"
+)
+
+// roles allowed to call each method, the same as the matching REST routes
+var methodRoles = map[string][]string{
+	"/order.v1.OrderService/PlaceOrder":  srvauth.PlaceOrderRoles,
+	"/order.v1.OrderService/GetOrder":    srvauth.GetOrderRoles,
+	"/order.v1.OrderService/TakeOrder":   srvauth.TakeOrderRoles,
+	"/order.v1.OrderService/ListOrders":  srvauth.ListOrdersRoles,
+	"/order.v1.OrderService/WatchOrders": srvauth.StreamOrdersRoles,
+}
+
+// Options holds the services and settings the gRPC server is created with
+type Options struct {
+	OrderService      srvorder.OrderService
+	OrderEventService srvorder.OrderEventService
+	Authenticator     srvorder.Authenticator
+	// RequestTimeout bounds unary calls, streams are only cancelled when the client goes away
+	RequestTimeout time.Duration
+	// WatchPollInterval is how often WatchOrders polls for new events
+	WatchPollInterval time.Duration
+	// WatchSettleWindow is how long WatchOrders reads events again for events with lower ids which commit late
+	WatchSettleWindow time.Duration
+	// LegacyOrderIds looks orders up by their sequential id when requests have no public id
+	LegacyOrderIds bool
+	// RateLimiter counts the calls of each client against RateLimits, keyed like the REST routes,
+	// calls are not limited without it
+	RateLimiter srvorder.RateLimiter
+	RateLimits  map[string]models.RateLimit
+	// IdempotencyService stores the responses of calls sent with an idempotency key,
+	// keys are ignored without it
+	IdempotencyService srvorder.IdempotencyService
+}
+
+// NewServer will create a gRPC server exposing the OrderService, it authenticates every call
+// and scopes its context to the merchant of the caller
+func NewServer(opts Options) *grpc.Server {
+	s := &server{opts: opts}
+
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.rateLimitInterceptor, s.unaryInterceptor, s.idempotencyInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	)
+	orderv1.RegisterOrderServiceServer(srv, &orderServer{
+		orderService: opts.OrderService,
+		eventService: opts.OrderEventService,
+		pollInterval: opts.WatchPollInterval,
+		settleWindow: opts.WatchSettleWindow,
+		legacyIds:    opts.LegacyOrderIds,
+	})
+
+	return srv
+}
+
+// Shutdown stops srv gracefully, calls still running after timeout, like open WatchOrders streams, are cancelled
+func Shutdown(srv *grpc.Server, timeout time.Duration) {
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		srv.Stop()
+	}
+}
+
+type server struct {
+	opts Options
+}
+
+func (s *server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authenticate(withRequestId(ctx), info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.opts.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.RequestTimeout)
+		defer cancel()
+	}
+
+	return handler(ctx, req)
+}
+
+func (s *server) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(withRequestId(ss.Context()), info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticate resolves the caller from the x-api-key or authorization metadata,
+// checks its role may call method and scopes ctx to its merchant
+func (s *server) authenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	var principal *models.Principal
+	var err error
+
+	if key := first(md, apiKeyMetadata); key != "" {
+		principal, err = s.opts.Authenticator.AuthenticateApiKey(ctx, key)
+	} else if token := srvauth.BearerToken(first(md, authorizationMetadata)); token != "" {
+		principal, err = s.opts.Authenticator.AuthenticateToken(ctx, token)
+	} else {
+		return nil, status.Error(codes.Unauthenticated, "Missing credentials")
+	}
+
+	if err == srvorder.ErrUnauthenticated {
+		return nil, status.Error(codes.Unauthenticated, "Invalid credentials")
+	}
+	if err != nil {
+		return nil, toStatus(err, "Failed to authenticate")
+	}
+
+	if !srvauth.HasRole(principal, methodRoles[method]) {
+		return nil, status.Error(codes.PermissionDenied, "Permission denied")
+	}
+
+	return tenant.WithMerchantId(ctx, principal.MerchantId), nil
+}
+
+// withRequestId accepts the x-request-id provided by the client or generates a new one,
+// a context which already has one is kept
+func withRequestId(ctx context.Context) context.Context {
+	if logger.RequestId(ctx) != "" {
+		return ctx
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	requestId := first(md, requestIdMetadata)
+	if !srvauth.ValidRequestId(requestId) {
+		requestId = uuid.New().String()
+	}
+
+	return logger.WithRequestId(ctx, requestId)
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// serverStream replaces the context of a stream with the authenticated one
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,467 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"order-service/archive"
+	"order-service/models"
+	orderv1 "order-service/proto/order/v1"
+	"order-service/replica"
+	srvorder "order-service/services"
+	srvmocks "order-service/services/mocks"
+	"order-service/services/ratelimit"
+	"order-service/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// merchantCtx matches the context of calls authenticated as a caller of merchant-1
+var merchantCtx = mock.MatchedBy(func(ctx context.Context) bool {
+	merchantId, _ := tenant.MerchantId(ctx)
+	return merchantId == "merchant-1"
+})
+
+// merchantPrimaryCtx matches merchantCtx when its reads must be served by the primary database
+var merchantPrimaryCtx = mock.MatchedBy(func(ctx context.Context) bool {
+	merchantId, _ := tenant.MerchantId(ctx)
+	return merchantId == "merchant-1" && replica.Primary(ctx)
+})
+
+// merchantArchivedCtx matches merchantCtx when its lookups include archived orders
+var merchantArchivedCtx = mock.MatchedBy(func(ctx context.Context) bool {
+	merchantId, _ := tenant.MerchantId(ctx)
+	return merchantId == "merchant-1" && archive.Included(ctx)
+})
+
+type fixture struct {
+	orderService *srvmocks.OrderService
+	eventService *srvmocks.OrderEventService
+	client       orderv1.OrderServiceClient
+}
+
+// newFixture serves the gRPC server on an in-process listener and returns a client connected to it,
+// sequential ids are looked up while legacyIds accepts them. configure changes the options of the server
+func newFixture(t *testing.T, legacyIds bool, configure ...func(*Options)) (*fixture, func()) {
+	f := &fixture{
+		orderService: new(srvmocks.OrderService),
+		eventService: new(srvmocks.OrderEventService),
+	}
+
+	auth := new(srvmocks.Authenticator)
+	auth.On("AuthenticateToken", mock.Anything, "customer").
+		Return(&models.Principal{Subject: "customer", Role: models.RoleCustomer, MerchantId: "merchant-1"}, nil)
+	auth.On("AuthenticateToken", mock.Anything, "driver").
+		Return(&models.Principal{Subject: "driver", Role: models.RoleDriver, MerchantId: "merchant-1"}, nil)
+	auth.On("AuthenticateApiKey", mock.Anything, "ops-key").
+		Return(&models.Principal{Subject: "ops", Role: models.RoleOps, MerchantId: "merchant-1"}, nil)
+	auth.On("AuthenticateToken", mock.Anything, mock.Anything).Return(nil, srvorder.ErrUnauthenticated)
+
+	opts := Options{
+		OrderService:      f.orderService,
+		OrderEventService: f.eventService,
+		Authenticator:     auth,
+		RequestTimeout:    time.Second,
+		WatchPollInterval: 10 * time.Millisecond,
+		LegacyOrderIds:    legacyIds,
+	}
+	for _, c := range configure {
+		c(&opts)
+	}
+	srv := NewServer(opts)
+
+	lis := bufconn.Listen(1024 * 1024)
+	go srv.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.client = orderv1.NewOrderServiceClient(conn)
+	return f, func() {
+		conn.Close()
+		srv.Stop()
+	}
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func assertCode(t *testing.T, code codes.Code, err error) {
+	t.Helper()
+	assert.Equal(t, code, status.Code(err), "unexpected error: %v", err)
+}
+
+func TestAuthentication(t *testing.T) {
+	f, stop := newFixture(t, true)
+	defer stop()
+
+	_, err := f.client.GetOrder(context.Background(), &orderv1.GetOrderRequest{Id: 1})
+	assertCode(t, codes.Unauthenticated, err)
+
+	_, err = f.client.GetOrder(withToken("invalid"), &orderv1.GetOrderRequest{Id: 1})
+	assertCode(t, codes.Unauthenticated, err)
+
+	_, err = f.client.TakeOrder(withToken("customer"), &orderv1.TakeOrderRequest{Id: 1})
+	assertCode(t, codes.PermissionDenied, err)
+
+	f.orderService.On("ListOrders", merchantCtx, 0, 10).Return([]models.Order{}, nil).Once()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "ops-key")
+	_, err = f.client.ListOrders(ctx, &orderv1.ListOrdersRequest{Page: 1, Limit: 10})
+	assert.NoError(t, err)
+}
+
+func TestPlaceOrder(t *testing.T) {
+	f, stop := newFixture(t, true)
+	defer stop()
+
+	origin := &orderv1.Location{Lat: "22.3193", Lng: "114.1694"}
+	destination := &orderv1.Location{Lat: "22.2783", Lng: "114.1747"}
+
+	t.Run("places the order", func(t *testing.T) {
+		f.orderService.On("PlaceOrder", merchantCtx, []string{"22.3193", "114.1694"}, []string{"22.2783", "114.1747"}).
+			Return(&models.Order{Id: 1, Distance: 1200, Status: models.StatusUnassigned}, nil).Once()
+
+		order, err := f.client.PlaceOrder(withToken("customer"), &orderv1.PlaceOrderRequest{Origin: origin, Destination: destination})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), order.Id)
+		assert.Equal(t, int32(1200), order.Distance)
+		assert.Equal(t, models.StatusUnassigned, order.Status)
+	})
+
+	t.Run("rejects an invalid location", func(t *testing.T) {
+		_, err := f.client.PlaceOrder(withToken("customer"), &orderv1.PlaceOrderRequest{
+			Origin:      &orderv1.Location{Lat: "95", Lng: "114.1694"},
+			Destination: destination,
+		})
+		assertCode(t, codes.InvalidArgument, err)
+
+		_, err = f.client.PlaceOrder(withToken("customer"), &orderv1.PlaceOrderRequest{Origin: origin})
+		assertCode(t, codes.InvalidArgument, err)
+	})
+
+	t.Run("maps a distance failure to INVALID_ARGUMENT", func(t *testing.T) {
+		f.orderService.On("PlaceOrder", merchantCtx, mock.Anything, mock.Anything).
+			Return(nil, srvorder.ErrCannotCalculateDistance).Once()
+
+		_, err := f.client.PlaceOrder(withToken("customer"), &orderv1.PlaceOrderRequest{Origin: origin, Destination: destination})
+		assertCode(t, codes.InvalidArgument, err)
+	})
+}
+
+func TestPlaceOrder_Idempotency(t *testing.T) {
+	idempotencyService := new(srvmocks.IdempotencyService)
+	f, stop := newFixture(t, true, func(opts *Options) {
+		opts.IdempotencyService = idempotencyService
+	})
+	defer stop()
+
+	req := &orderv1.PlaceOrderRequest{
+		Origin:      &orderv1.Location{Lat: "22.3193", Lng: "114.1694"},
+		Destination: &orderv1.Location{Lat: "22.2783", Lng: "114.1747"},
+	}
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(withToken("customer"), "idempotency-key", key)
+	}
+
+	var hash string
+	var stored []byte
+	t.Run("stores the response of the first call", func(t *testing.T) {
+		idempotencyService.On("Begin", merchantCtx, "key-1", mock.AnythingOfType("string")).
+			Run(func(args mock.Arguments) { hash = args.String(2) }).
+			Return(&models.IdempotencyRecord{Key: "key-1"}, nil).Once()
+		f.orderService.On("PlaceOrder", merchantCtx, mock.Anything, mock.Anything).
+			Return(&models.Order{Id: 1, Distance: 1200, Status: models.StatusUnassigned}, nil).Once()
+		idempotencyService.On("Complete", merchantCtx, "key-1", 200, mock.Anything).
+			Run(func(args mock.Arguments) { stored = args.Get(3).([]byte) }).
+			Return(nil).Once()
+
+		order, err := f.client.PlaceOrder(withKey("key-1"), req)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), order.Id)
+	})
+
+	t.Run("replays the stored response to a retry", func(t *testing.T) {
+		idempotencyService.On("Begin", merchantCtx, "key-1", hash).
+			Return(&models.IdempotencyRecord{Key: "key-1", StatusCode: 200, Response: stored}, nil).Once()
+
+		var header metadata.MD
+		order, err := f.client.PlaceOrder(withKey("key-1"), req, grpc.Header(&header))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), order.Id)
+		assert.Equal(t, int32(1200), order.Distance)
+		assert.Equal(t, []string{"true"}, header.Get("idempotent-replayed"))
+	})
+
+	t.Run("rejects a key used for a different request", func(t *testing.T) {
+		idempotencyService.On("Begin", merchantCtx, "key-1", mock.Anything).Return(nil, srvorder.ErrIdempotencyKeyReused).Once()
+
+		_, err := f.client.PlaceOrder(withKey("key-1"), req)
+		assertCode(t, codes.AlreadyExists, err)
+	})
+
+	t.Run("releases the key of a failed call", func(t *testing.T) {
+		idempotencyService.On("Begin", merchantCtx, "key-2", hash).Return(&models.IdempotencyRecord{Key: "key-2"}, nil).Once()
+		f.orderService.On("PlaceOrder", merchantCtx, mock.Anything, mock.Anything).Return(nil, srvorder.ErrCannotCalculateDistance).Once()
+		idempotencyService.On("Release", merchantCtx, "key-2").Return(nil).Once()
+
+		_, err := f.client.PlaceOrder(withKey("key-2"), req)
+		assertCode(t, codes.InvalidArgument, err)
+	})
+
+	t.Run("rejects an oversized key", func(t *testing.T) {
+		_, err := f.client.PlaceOrder(withKey(strings.Repeat("k", 256)), req)
+		assertCode(t, codes.InvalidArgument, err)
+	})
+
+	idempotencyService.AssertExpectations(t)
+	f.orderService.AssertExpectations(t)
+}
+
+func TestPlaceOrder_RateLimit(t *testing.T) {
+	f, stop := newFixture(t, true, func(opts *Options) {
+		opts.RateLimiter = ratelimit.NewMemoryRateLimiter()
+		opts.RateLimits = map[string]models.RateLimit{ratelimit.PlaceOrder: {Requests: 1, Per: time.Minute}}
+	})
+	defer stop()
+
+	req := &orderv1.PlaceOrderRequest{
+		Origin:      &orderv1.Location{Lat: "22.3193", Lng: "114.1694"},
+		Destination: &orderv1.Location{Lat: "22.2783", Lng: "114.1747"},
+	}
+	f.orderService.On("PlaceOrder", merchantCtx, mock.Anything, mock.Anything).
+		Return(&models.Order{Id: 1, Status: models.StatusUnassigned}, nil).Once()
+
+	var header metadata.MD
+	_, err := f.client.PlaceOrder(withToken("customer"), req, grpc.Header(&header))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1"}, header.Get("x-ratelimit-limit"))
+	assert.Equal(t, []string{"0"}, header.Get("x-ratelimit-remaining"))
+
+	// the limit applies before the caller is authenticated
+	_, err = f.client.PlaceOrder(withToken("invalid"), req, grpc.Header(&header))
+	assertCode(t, codes.ResourceExhausted, err)
+	assert.Equal(t, []string{"60"}, header.Get("retry-after"))
+
+	// other methods have their own limits
+	f.orderService.On("ListOrders", merchantCtx, 0, 10).Return([]models.Order{}, nil).Once()
+	_, err = f.client.ListOrders(metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "ops-key"), &orderv1.ListOrdersRequest{Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	f.orderService.AssertExpectations(t)
+}
+
+func TestGetOrder(t *testing.T) {
+	f, stop := newFixture(t, true)
+	defer stop()
+
+	f.orderService.On("GetById", merchantCtx, int64(1)).Return(&models.Order{Id: 1, Status: models.StatusTaken}, nil)
+	f.orderService.On("GetById", merchantCtx, int64(2)).Return(nil, models.ErrNotFound)
+	f.orderService.On("GetById", merchantCtx, int64(3)).Return(nil, errors.New("db down"))
+	f.orderService.On("GetById", merchantArchivedCtx, int64(4)).Return(&models.Order{Id: 4, Status: models.StatusTaken}, nil)
+
+	order, err := f.client.GetOrder(withToken("driver"), &orderv1.GetOrderRequest{Id: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusTaken, order.Status)
+
+	_, err = f.client.GetOrder(withToken("driver"), &orderv1.GetOrderRequest{Id: 2})
+	assertCode(t, codes.NotFound, err)
+
+	_, err = f.client.GetOrder(withToken("driver"), &orderv1.GetOrderRequest{Id: 3})
+	assertCode(t, codes.Internal, err)
+	assert.Equal(t, "Failed to find order", status.Convert(err).Message())
+
+	order, err = f.client.GetOrder(withToken("driver"), &orderv1.GetOrderRequest{Id: 4, IncludeArchived: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), order.Id)
+}
+
+func TestGetOrder_PublicId(t *testing.T) {
+	f, stop := newFixture(t, false)
+	defer stop()
+
+	const publicId = "01KDZ3J0K8A7Z4W9X2M5N6P7Q8"
+	f.orderService.On("GetByPublicId", merchantCtx, publicId).Return(&models.Order{Id: 1, PublicId: publicId, Status: models.StatusTaken}, nil).Once()
+	f.orderService.On("GetByPublicId", merchantArchivedCtx, publicId).Return(&models.Order{Id: 1, PublicId: publicId, Status: models.StatusTaken}, nil).Once()
+
+	// public ids are accepted in any case
+	order, err := f.client.GetOrder(withToken("driver"), &orderv1.GetOrderRequest{PublicId: strings.ToLower(publicId)})
+	assert.NoError(t, err)
+	assert.Equal(t, publicId, order.PublicId)
+	// the sequential id is not sent without legacy ids
+	assert.Equal(t, int64(0), order.Id)
+
+	_, err = f.client.GetOrder(withToken("driver"), &orderv1.GetOrderRequest{PublicId: publicId, IncludeArchived: true})
+	assert.NoError(t, err)
+
+	// sequential ids are refused once legacy ids are turned off, in either field
+	_, err = f.client.GetOrder(withToken("driver"), &orderv1.GetOrderRequest{Id: 1})
+	assertCode(t, codes.InvalidArgument, err)
+	_, err = f.client.GetOrder(withToken("driver"), &orderv1.GetOrderRequest{PublicId: "1"})
+	assertCode(t, codes.InvalidArgument, err)
+
+	f.orderService.AssertExpectations(t)
+}
+
+func TestTakeOrder(t *testing.T) {
+	f, stop := newFixture(t, true)
+	defer stop()
+
+	order := &models.Order{Id: 1, Status: models.StatusUnassigned}
+	f.orderService.On("GetById", merchantPrimaryCtx, int64(1)).Return(order, nil)
+	f.orderService.On("TakeOrder", merchantCtx, order).Return(&models.Order{Id: 1, Status: models.StatusTaken}, nil).Once()
+	f.orderService.On("TakeOrder", merchantCtx, order).Return(nil, srvorder.ErrOrderAlreadyTaken).Once()
+	f.orderService.On("GetById", merchantPrimaryCtx, int64(2)).Return(nil, models.ErrNotFound)
+
+	taken, err := f.client.TakeOrder(withToken("driver"), &orderv1.TakeOrderRequest{Id: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusTaken, taken.Status)
+
+	_, err = f.client.TakeOrder(withToken("driver"), &orderv1.TakeOrderRequest{Id: 1})
+	assertCode(t, codes.FailedPrecondition, err)
+
+	_, err = f.client.TakeOrder(withToken("driver"), &orderv1.TakeOrderRequest{Id: 2})
+	assertCode(t, codes.NotFound, err)
+
+	f.orderService.AssertExpectations(t)
+}
+
+func TestTakeOrder_PublicId(t *testing.T) {
+	f, stop := newFixture(t, false)
+	defer stop()
+
+	const publicId = "01KDZ3J0K8A7Z4W9X2M5N6P7Q8"
+	order := &models.Order{Id: 1, PublicId: publicId, Status: models.StatusUnassigned}
+	f.orderService.On("GetByPublicId", merchantPrimaryCtx, publicId).Return(order, nil).Once()
+	f.orderService.On("TakeOrder", merchantCtx, order).Return(&models.Order{Id: 1, PublicId: publicId, Status: models.StatusTaken}, nil).Once()
+
+	taken, err := f.client.TakeOrder(withToken("driver"), &orderv1.TakeOrderRequest{PublicId: publicId})
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusTaken, taken.Status)
+
+	_, err = f.client.TakeOrder(withToken("driver"), &orderv1.TakeOrderRequest{Id: 1})
+	assertCode(t, codes.InvalidArgument, err)
+
+	f.orderService.AssertExpectations(t)
+}
+
+func TestListOrders(t *testing.T) {
+	f, stop := newFixture(t, true)
+	defer stop()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "ops-key")
+
+	f.orderService.On("ListOrders", merchantCtx, 10, 5).
+		Return([]models.Order{{Id: 11}, {Id: 12}}, nil).Once()
+	f.orderService.On("ListOrders", merchantCtx, 0, 5).
+		Return(nil, context.DeadlineExceeded).Once()
+	f.orderService.On("ListOrders", merchantArchivedCtx, 5, 5).
+		Return([]models.Order{{Id: 6}}, nil).Once()
+
+	resp, err := f.client.ListOrders(ctx, &orderv1.ListOrdersRequest{Page: 3, Limit: 5})
+	assert.NoError(t, err)
+	if assert.Len(t, resp.Orders, 2) {
+		assert.Equal(t, int64(12), resp.Orders[1].Id)
+	}
+
+	_, err = f.client.ListOrders(ctx, &orderv1.ListOrdersRequest{Page: 1, Limit: 5})
+	assertCode(t, codes.DeadlineExceeded, err)
+
+	_, err = f.client.ListOrders(ctx, &orderv1.ListOrdersRequest{Page: 0, Limit: 5})
+	assertCode(t, codes.InvalidArgument, err)
+
+	resp, err = f.client.ListOrders(ctx, &orderv1.ListOrdersRequest{Page: 2, Limit: 5, IncludeArchived: true})
+	assert.NoError(t, err)
+	assert.Len(t, resp.Orders, 1)
+
+	// like the REST API, a page holds at most srvorder.MaxPageLimit orders
+	f.orderService.On("ListOrders", merchantCtx, srvorder.MaxPageLimit, srvorder.MaxPageLimit).Return([]models.Order{}, nil).Once()
+	_, err = f.client.ListOrders(ctx, &orderv1.ListOrdersRequest{Page: 2, Limit: 100000})
+	assert.NoError(t, err)
+
+	f.orderService.AssertExpectations(t)
+}
+
+func TestWatchOrders(t *testing.T) {
+	f, stop := newFixture(t, false)
+	defer stop()
+
+	const publicId = "01KDZ3J0K8A7Z4W9X2M5N6P7Q8"
+	f.eventService.On("EventsAfter", merchantCtx, int64(5), watchBatchSize).Return([]models.Event{
+		{Id: 6, Type: models.EventTypeOrderPlaced, OrderId: 1, Payload: []byte(`{"id":"` + publicId + `","distance":1200,"status":"UNASSIGNED"}`), Origins: []float64{22.3193, 114.1694}},
+		// about 100km away from the area
+		{Id: 7, Type: models.EventTypeOrderPlaced, OrderId: 2, Payload: []byte(`{"id":"01KDZ3J0K8A7Z4W9X2M5N6P7Q9","status":"UNASSIGNED"}`), Origins: []float64{23.2193, 114.1694}},
+		// events written before public ids carry the sequential id
+		{Id: 8, Type: models.EventTypeOrderTaken, OrderId: 1, Payload: []byte(`{"id":1,"distance":1200,"status":"TAKEN"}`), Origins: []float64{22.3193, 114.1694}},
+	}, nil).Once()
+	f.eventService.On("EventsAfter", merchantCtx, int64(8), watchBatchSize).Return([]models.Event{}, nil)
+
+	ctx, cancel := context.WithCancel(withToken("driver"))
+	defer cancel()
+
+	after := int64(5)
+	stream, err := f.client.WatchOrders(ctx, &orderv1.WatchOrdersRequest{
+		AfterEventId: &after,
+		Area:         &orderv1.Area{Lat: 22.3193, Lng: 114.1694, Radius: 5000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), event.Id)
+	assert.Equal(t, models.EventTypeOrderPlaced, event.Type)
+	assert.Equal(t, int32(1200), event.Order.Distance)
+	assert.Equal(t, publicId, event.Order.PublicId)
+	// the sequential id is not sent without legacy ids
+	assert.Equal(t, int64(0), event.Order.Id)
+
+	event, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), event.Id)
+	assert.Equal(t, models.StatusTaken, event.Order.Status)
+	assert.Equal(t, int64(0), event.Order.Id)
+	assert.Empty(t, event.Order.PublicId)
+
+	cancel()
+	_, err = stream.Recv()
+	assertCode(t, codes.Canceled, err)
+}
+
+func TestWatchOrders_FromLatest(t *testing.T) {
+	f, stop := newFixture(t, true)
+	defer stop()
+
+	f.eventService.On("LatestEventId", merchantCtx).Return(int64(0), errors.New("db down")).Once()
+
+	stream, err := f.client.WatchOrders(withToken("driver"), &orderv1.WatchOrdersRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = stream.Recv()
+	assertCode(t, codes.Internal, err)
+
+	stream, err = f.client.WatchOrders(withToken("driver"), &orderv1.WatchOrdersRequest{Area: &orderv1.Area{Lat: 22.3, Lng: 114.1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = stream.Recv()
+	assertCode(t, codes.InvalidArgument, err)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"time"
+
+	"order-service/logger"
+	"order-service/problem"
+	srvorder "order-service/services"
+
+	"github.com/kataras/iris"
+	"github.com/kataras/iris/context"
+	"github.com/sirupsen/logrus"
+)
+
+type CreateApiKeyReq struct {
+	Name string `json:"name" validate:"required,max=255"`
+	Role string `json:"role" validate:"required,oneof=customer driver ops admin"`
+}
+
+// CreateApiKeyResp is the created api key, Key is the only time the plain key is returned
+type CreateApiKeyResp struct {
+	Id         int64     `json:"id"`
+	Name       string    `json:"name"`
+	MerchantId string    `json:"merchant_id"`
+	Role       string    `json:"role"`
+	CreatedAt  time.Time `json:"created_at"`
+	Key        string    `json:"key"`
+}
+
+func CreateApiKey(auth srvorder.Authenticator) context.Handler {
+	return func(ctx iris.Context) {
+		log := logger.FromContext(ctx.Request().Context()).WithFields(logrus.Fields{"module": "handler", "method": "CreateApiKey"})
+
+		var req CreateApiKeyReq
+		err := ctx.ReadJSON(&req)
+		if err != nil {
+			problem.Write(ctx, iris.StatusBadRequest, problem.CodeInvalidJSON, "Invalid json provided")
+			return
+		}
+
+		err = validate.Struct(req)
+		if err != nil {
+			problem.Send(ctx, problem.Validation(err))
+			return
+		}
+
+		log = log.WithFields(logrus.Fields{"name": req.Name, "role": req.Role})
+
+		key, apiKey, err := auth.CreateApiKey(ctx.Request().Context(), req.Name, req.Role)
+		if err != nil {
+			log.WithField("err", err).Error("Failed to create api key")
+			problem.Write(ctx, iris.StatusInternalServerError, problem.CodeInternal, "Failed to create api key")
+			return
+		}
+
+		log.WithField("api_key_id", apiKey.Id).Info("Successfully created api key")
+
+		ctx.StatusCode(iris.StatusCreated)
+		ctx.JSON(CreateApiKeyResp{
+			Id:         apiKey.Id,
+			Name:       apiKey.Name,
+			MerchantId: apiKey.MerchantId,
+			Role:       apiKey.Role,
+			CreatedAt:  apiKey.CreatedAt,
+			Key:        key,
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	stdcontext "context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"order-service/logger"
+	"order-service/metrics"
+	"order-service/models"
+	"order-service/problem"
+	"order-service/replica"
+	srvorder "order-service/services"
+
+	"github.com/kataras/iris"
+	"github.com/kataras/iris/context"
+	"github.com/kataras/iris/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// types of the messages sent by drivers
+const (
+	// MessageTake takes an order
+	MessageTake = "take"
+	// MessageAccept accepts an offer, it takes the offered order
+	MessageAccept = "accept"
+	// MessageLocation reports the location of the driver, offers are then limited to orders within Radius meters
+	MessageLocation = "location"
+)
+
+// types of the messages sent to drivers
+const (
+	// MessageOffer carries a newly placed, unassigned order
+	MessageOffer = "offer"
+	// MessageUpdate carries an order which changed, e.g. was taken
+	MessageUpdate = "update"
+	// MessageResult replies to a take or accept message
+	MessageResult = "result"
+	// MessageError replies to a message which could not be handled
+	MessageError = "error"
+)
+
+// statuses of a result message
+const (
+	ResultSuccess      = "SUCCESS"
+	ResultAlreadyTaken = "ALREADY_TAKEN"
+	ResultNotFound     = "NOT_FOUND"
+	ResultTimeout      = "TIMEOUT"
+	ResultError        = "ERROR"
+)
+
+// DriverMessage is a message sent by a driver, Ref is echoed in the reply to correlate it. OrderId is
+// the public id of the order, or its sequential id while legacy ids are accepted
+type DriverMessage struct {
+	Type    string  `json:"type"`
+	Ref     string  `json:"ref,omitempty"`
+	OrderId string  `json:"order_id,omitempty"`
+	Lat     float64 `json:"lat,omitempty"`
+	Lng     float64 `json:"lng,omitempty"`
+	Radius  float64 `json:"radius,omitempty"`
+}
+
+// ChannelMessage is a message sent to a driver, OrderId is the id of the order in the REST API
+type ChannelMessage struct {
+	Type    string          `json:"type"`
+	Ref     string          `json:"ref,omitempty"`
+	EventId int64           `json:"event_id,omitempty"`
+	OrderId string          `json:"order_id,omitempty"`
+	Order   json.RawMessage `json:"order,omitempty"`
+	Status  string          `json:"status,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// DriverChannelOptions configures the connections of the driver channel
+type DriverChannelOptions struct {
+	// PingPeriod is how often connections are pinged, a connection is closed when nothing
+	// was read from it for two periods
+	PingPeriod time.Duration
+	// MessageTimeout bounds the handling of each message, zero means no deadline
+	MessageTimeout time.Duration
+}
+
+// DriverChannel upgrades the request to a WebSocket connection over which the driver receives the offers
+// and updates of the merchant's orders, and takes orders. Orders are taken by their public id, or their
+// sequential id while legacyIds accepts them
+func DriverChannel(orderService srvorder.OrderService, hub srvorder.EventHub, opts DriverChannelOptions, legacyIds bool) context.Handler {
+	ws := websocket.New(websocket.Config{
+		PingPeriod:     opts.PingPeriod,
+		ReadTimeout:    2 * opts.PingPeriod,
+		WriteTimeout:   opts.PingPeriod,
+		MaxMessageSize: 4096,
+	})
+
+	return func(ctx iris.Context) {
+		log := logger.FromContext(ctx.Request().Context()).WithFields(logrus.Fields{"module": "handler", "method": "DriverChannel"})
+
+		events, unsubscribe, err := hub.Subscribe(ctx.Request().Context())
+		if err != nil {
+			log.WithField("err", err).Error("Failed to subscribe to order events")
+			problem.Write(ctx, iris.StatusInternalServerError, problem.CodeInternal, "Failed to open driver channel")
+			return
+		}
+		defer unsubscribe()
+
+		conn := ws.Upgrade(ctx)
+		if conn.Err() != nil {
+			log.WithField("err", conn.Err()).Error("Failed to upgrade driver channel")
+			return
+		}
+
+		metrics.DriverConnections.Inc()
+		defer metrics.DriverConnections.Dec()
+
+		ch := &driverChannel{
+			conn:         conn,
+			orderService: orderService,
+			ctx:          ctx.Request().Context(),
+			timeout:      opts.MessageTimeout,
+			legacyIds:    legacyIds,
+			log:          log,
+		}
+
+		conn.OnMessage(ch.receive)
+		go ch.forward(events)
+
+		log.Debug("Opened driver channel")
+		// blocks until the connection is closed
+		conn.Wait()
+		log.Debug("Closed driver channel")
+	}
+}
+
+// driverChannel is the state of one driver connection
+type driverChannel struct {
+	conn         websocket.Connection
+	orderService srvorder.OrderService
+	ctx          stdcontext.Context
+	timeout      time.Duration
+	legacyIds    bool
+	log          *logrus.Entry
+
+	mu   sync.Mutex
+	area *models.Area
+}
+
+// forward sends the offers and updates of events until the channel is closed
+func (c *driverChannel) forward(events <-chan models.Event) {
+	for event := range events {
+		msg := ChannelMessage{EventId: event.Id, OrderId: event.OrderPublicId(), Order: event.Payload}
+
+		switch event.Type {
+		case models.EventTypeOrderPlaced:
+			if area := c.currentArea(); area != nil && !area.Contains(event.Origins[0], event.Origins[1]) {
+				continue
+			}
+			msg.Type = MessageOffer
+		default:
+			msg.Type = MessageUpdate
+		}
+
+		c.send(msg)
+	}
+}
+
+// receive handles a message of the driver, messages of a connection are handled one at a time
+func (c *driverChannel) receive(data []byte) {
+	var msg DriverMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		c.send(ChannelMessage{Type: MessageError, Error: "Invalid json provided"})
+		return
+	}
+
+	switch msg.Type {
+	case MessageTake, MessageAccept:
+		c.take(msg)
+	case MessageLocation:
+		c.locate(msg)
+	default:
+		c.send(ChannelMessage{Type: MessageError, Ref: msg.Ref, Error: "Unknown message type"})
+	}
+}
+
+func (c *driverChannel) take(msg DriverMessage) {
+	log := c.log.WithFields(logrus.Fields{"order_id": msg.OrderId, "ref": msg.Ref})
+	reply := ChannelMessage{Type: MessageResult, Ref: msg.Ref, OrderId: msg.OrderId}
+
+	ctx := c.ctx
+	if c.timeout > 0 {
+		var cancel stdcontext.CancelFunc
+		ctx, cancel = stdcontext.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	// the order is read from the primary, a lagging replica could report it missing or taken
+	order, err := srvorder.FindOrder(replica.WithPrimary(ctx), c.orderService, msg.OrderId, c.legacyIds)
+	if err == srvorder.ErrInvalidOrderId {
+		c.send(ChannelMessage{Type: MessageError, Ref: msg.Ref, Error: "Invalid order id"})
+		return
+	}
+	if err == nil {
+		_, err = c.orderService.TakeOrder(ctx, order)
+	}
+
+	switch {
+	case err == nil:
+		log.Debug("Successfully took order")
+		reply.Status = ResultSuccess
+	case err == models.ErrNotFound:
+		reply.Status = ResultNotFound
+	case err == srvorder.ErrOrderAlreadyTaken:
+		log.WithField("err", err).Error("Failed to take order, since order already taken")
+		reply.Status = ResultAlreadyTaken
+	case srvorder.IsTimeout(err):
+		log.WithField("err", err).Error("Failed to take order, since request timed out")
+		reply.Status = ResultTimeout
+	default:
+		log.WithField("err", err).Error("Failed to take order")
+		reply.Status = ResultError
+	}
+
+	c.send(reply)
+}
+
+func (c *driverChannel) locate(msg DriverMessage) {
+	if msg.Radius < 0 || validate.Var(msg.Lat, "latitude") != nil || validate.Var(msg.Lng, "longitude") != nil {
+		c.send(ChannelMessage{Type: MessageError, Ref: msg.Ref, Error: "Invalid location"})
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// a zero radius stops limiting offers by location
+	c.area = nil
+	if msg.Radius > 0 {
+		c.area = &models.Area{Lat: msg.Lat, Lng: msg.Lng, Radius: msg.Radius}
+	}
+}
+
+func (c *driverChannel) currentArea() *models.Area {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.area
+}
+
+func (c *driverChannel) send(msg ChannelMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		c.log.WithField("err", err).Error("Failed to encode message")
+		return
+	}
+
+	// writes are serialized by the connection, a failed write closes it
+	c.conn.EmitMessage(data)
+}
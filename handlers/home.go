@@ -5,7 +5,7 @@ import (
 )
 
 func Home(ctx iris.Context) {
-	ctx.JSON(iris.Map{
-		"status": "OK",
+	ctx.JSON(StatusResp{
+		Status: "OK",
 	})
 }
@@ -1,7 +1,12 @@
 package handlers
 
 import (
+	"reflect"
+	"strings"
+
+	"order-service/logger"
 	"order-service/models"
+	"order-service/problem"
 	srvorder "order-service/services"
 
 	"github.com/kataras/iris"
@@ -15,6 +20,15 @@ var validate *validator.Validate
 func init() {
 	validate = validator.New()
 
+	// report fields by their json names
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
 	// add custom validation for latitude and longitude
 	validate.RegisterValidation("location", func(fl validator.FieldLevel) bool {
 		latitudeStr := fl.Field().Index(0).String()
@@ -32,15 +46,12 @@ type PlaceOrderReq struct {
 
 func PlaceOrder(orderService srvorder.OrderService) context.Handler {
 	return func(ctx iris.Context) {
-		log := logrus.WithFields(logrus.Fields{"module": "handler", "method": "PlaceOrder"})
+		log := logger.FromContext(ctx.Request().Context()).WithFields(logrus.Fields{"module": "handler", "method": "PlaceOrder"})
 
 		var req PlaceOrderReq
 		err := ctx.ReadJSON(&req)
 		if err != nil {
-			ctx.StatusCode(iris.StatusBadRequest)
-			ctx.JSON(iris.Map{
-				"error": err.Error(),
-			})
+			problem.Write(ctx, iris.StatusBadRequest, problem.CodeInvalidJSON, "Invalid json provided")
 			return
 		}
 
@@ -48,28 +59,24 @@ func PlaceOrder(orderService srvorder.OrderService) context.Handler {
 
 		err = validate.Struct(req)
 		if err != nil {
-			ctx.StatusCode(iris.StatusBadRequest)
-			ctx.JSON(iris.Map{
-				"error": err.Error(),
-			})
+			problem.Send(ctx, problem.Validation(err))
 			return
 		}
 
-		order, err := orderService.PlaceOrder(req.Origin, req.Destination)
+		order, err := orderService.PlaceOrder(ctx.Request().Context(), req.Origin, req.Destination)
 		if err == srvorder.ErrCannotCalculateDistance {
 			log.WithField("err", err).Error("Failed to calculate distance for location")
-			ctx.StatusCode(iris.StatusBadRequest)
-			ctx.JSON(iris.Map{
-				"error": srvorder.ErrCannotCalculateDistance.Error(),
-			})
+			problem.Write(ctx, iris.StatusBadRequest, problem.CodeCannotCalculateDistance, "Cannot calculate distance for given location")
+			return
+		}
+		if srvorder.IsTimeout(err) {
+			log.WithField("err", err).Error("Failed to place order, since request timed out")
+			problem.Write(ctx, iris.StatusGatewayTimeout, problem.CodeTimeout, "Request timed out")
 			return
 		}
 		if err != nil {
 			log.WithField("err", err).Error("Failed to place order")
-			ctx.StatusCode(iris.StatusInternalServerError)
-			ctx.JSON(iris.Map{
-				"error": err.Error(),
-			})
+			problem.Write(ctx, iris.StatusInternalServerError, problem.CodeInternal, "Failed to place order")
 			return
 		}
 
@@ -84,24 +91,18 @@ type TakeOrderReq struct {
 
 func TakeOrder(orderService srvorder.OrderService) context.Handler {
 	return func(ctx iris.Context) {
-		log := logrus.WithFields(logrus.Fields{"module": "handler", "method": "TakeOrder"})
+		log := logger.FromContext(ctx.Request().Context()).WithFields(logrus.Fields{"module": "handler", "method": "TakeOrder"})
 
 		var req TakeOrderReq
 		err := ctx.ReadJSON(&req)
 		if err != nil {
-			ctx.StatusCode(iris.StatusBadRequest)
-			ctx.JSON(iris.Map{
-				"error": "Invalid json provided",
-			})
+			problem.Write(ctx, iris.StatusBadRequest, problem.CodeInvalidJSON, "Invalid json provided")
 			return
 		}
 
 		err = validate.Struct(req)
 		if err != nil {
-			ctx.StatusCode(iris.StatusBadRequest)
-			ctx.JSON(iris.Map{
-				"error": err.Error(),
-			})
+			problem.Send(ctx, problem.Validation(err))
 			return
 		}
 
@@ -109,34 +110,41 @@ func TakeOrder(orderService srvorder.OrderService) context.Handler {
 
 		log = log.WithFields(logrus.Fields{"order_id": order.Id, "status": order.Status})
 
-		_, err = orderService.TakeOrder(order)
+		order, err = orderService.TakeOrder(ctx.Request().Context(), order)
 		if err == srvorder.ErrOrderAlreadyTaken {
 			log.WithField("err", err).Error("Failed to take order, since order already taken")
-			ctx.StatusCode(iris.StatusConflict)
-			ctx.JSON(iris.Map{
-				"error": "Order already taken",
-			})
+			problem.Write(ctx, iris.StatusConflict, problem.CodeOrderAlreadyTaken, "Order already taken")
+			return
+		} else if srvorder.IsTimeout(err) {
+			log.WithField("err", err).Error("Failed to take order, since request timed out")
+			problem.Write(ctx, iris.StatusGatewayTimeout, problem.CodeTimeout, "Request timed out")
 			return
 		} else if err != nil {
 			log.WithField("err", err).Error("Failed to take order")
-			ctx.StatusCode(iris.StatusInternalServerError)
-			ctx.JSON(iris.Map{
-				"error": "Service unavailable",
-			})
+			problem.Write(ctx, iris.StatusInternalServerError, problem.CodeInternal, "Service unavailable")
 			return
 		}
 
 		log.Debug("Successfully took order")
 
-		ctx.JSON(iris.Map{
-			"status": "SUCCESS",
+		ctx.Header("ETag", order.ETag())
+		ctx.JSON(StatusResp{
+			Status: "SUCCESS",
 		})
 	}
 }
 
+// GetOrder returns the order loaded by FetchOrder, with the ETag of its version
+func GetOrder(ctx iris.Context) {
+	order := ctx.Values().Get("_order").(*models.Order)
+
+	ctx.Header("ETag", order.ETag())
+	ctx.JSON(order)
+}
+
 func ListOrders(orderService srvorder.OrderService) context.Handler {
 	return func(ctx iris.Context) {
-		log := logrus.WithFields(logrus.Fields{"module": "handler", "method": "ListOrders"})
+		log := logger.FromContext(ctx.Request().Context()).WithFields(logrus.Fields{"module": "handler", "method": "ListOrders"})
 
 		page := ctx.Values().Get("_page").(int)
 		limit := ctx.Values().Get("_limit").(int)
@@ -144,13 +152,15 @@ func ListOrders(orderService srvorder.OrderService) context.Handler {
 
 		log = log.WithFields(logrus.Fields{"page": page, "limit": limit, "offset": offset})
 
-		orders, err := orderService.ListOrders(offset, limit)
+		orders, err := orderService.ListOrders(ctx.Request().Context(), offset, limit)
+		if srvorder.IsTimeout(err) {
+			log.WithField("err", err).Error("Failed to retrieve orders, since request timed out")
+			problem.Write(ctx, iris.StatusGatewayTimeout, problem.CodeTimeout, "Request timed out")
+			return
+		}
 		if err != nil {
 			log.WithField("err", err).Error("Failed to retrieve orders")
-			ctx.StatusCode(iris.StatusInternalServerError)
-			ctx.JSON(iris.Map{
-				"error": "Failed to retrieve orders",
-			})
+			problem.Write(ctx, iris.StatusInternalServerError, problem.CodeInternal, "Failed to retrieve orders")
 			return
 		}
 
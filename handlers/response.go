@@ -0,0 +1,6 @@
+package handlers
+
+// StatusResp is the body of responses which only report a status
+type StatusResp struct {
+	Status string `json:"status"`
+}
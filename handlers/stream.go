@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"order-service/logger"
+	"order-service/models"
+	"order-service/problem"
+	srvorder "order-service/services"
+
+	"github.com/kataras/iris"
+	"github.com/kataras/iris/context"
+	"github.com/sirupsen/logrus"
+)
+
+// streamBatchSize is the maximum number of events read on each poll of a stream
+const streamBatchSize = 100
+
+// StreamOrdersOptions configures how often a stream polls for new events and sends heartbeats
+type StreamOrdersOptions struct {
+	PollInterval      time.Duration
+	HeartbeatInterval time.Duration
+	// SettleWindow is how long events are read again for events with lower ids which commit late
+	SettleWindow time.Duration
+}
+
+// StreamOrders streams the order events of the merchant as server-sent events. The stream starts after
+// the event in the Last-Event-ID header, or with the events written after it was opened. When lat, lng
+// and radius (in meters) are given, only the events of orders originating within that area are sent.
+func StreamOrders(eventService srvorder.OrderEventService, opts StreamOrdersOptions) context.Handler {
+	return func(ctx iris.Context) {
+		log := logger.FromContext(ctx.Request().Context()).WithFields(logrus.Fields{"module": "handler", "method": "StreamOrders"})
+
+		area, err := parseArea(ctx)
+		if err != nil {
+			problem.Write(ctx, iris.StatusBadRequest, problem.CodeInvalidParameter, err.Error())
+			return
+		}
+
+		after, err := lastEventId(ctx, eventService)
+		if err == errInvalidLastEventId {
+			problem.Write(ctx, iris.StatusBadRequest, problem.CodeInvalidParameter, err.Error())
+			return
+		}
+		if err != nil {
+			log.WithField("err", err).Error("Failed to open order stream")
+			problem.Write(ctx, iris.StatusInternalServerError, problem.CodeInternal, "Failed to open order stream")
+			return
+		}
+
+		flusher, ok := ctx.ResponseWriter().Flusher()
+		if !ok {
+			problem.Write(ctx, iris.StatusHTTPVersionNotSupported, problem.CodeStreamingUnsupported, "Streaming unsupported")
+			return
+		}
+
+		log = log.WithField("last_event_id", after)
+		log.Debug("Opened order stream")
+
+		ctx.ContentType("text/event-stream")
+		ctx.Header("Cache-Control", "no-cache")
+		ctx.Header("Connection", "keep-alive")
+		ctx.Header("X-Accel-Buffering", "no")
+		ctx.StatusCode(iris.StatusOK)
+
+		fmt.Fprintf(ctx, "retry: %d\n\n", opts.PollInterval.Milliseconds())
+		flusher.Flush()
+
+		poll := time.NewTicker(opts.PollInterval)
+		defer poll.Stop()
+		heartbeat := time.NewTicker(opts.HeartbeatInterval)
+		defer heartbeat.Stop()
+
+		done := ctx.Request().Context().Done()
+		cursor := srvorder.NewEventCursor(after, opts.SettleWindow)
+
+		for {
+			select {
+			case <-done:
+				log.Debug("Closed order stream")
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(ctx, ": heartbeat\n\n")
+				flusher.Flush()
+			case <-poll.C:
+				events, err := cursor.Next(ctx.Request().Context(), eventService, streamBatchSize)
+				if err != nil {
+					// the client reconnects from the last event it received
+					log.WithField("err", err).Error("Failed to poll order stream")
+					return
+				}
+
+				for _, event := range events {
+					if area != nil && !area.Contains(event.Origins[0], event.Origins[1]) {
+						continue
+					}
+
+					data, err := json.Marshal(event)
+					if err != nil {
+						log.WithFields(logrus.Fields{"err": err, "event_id": event.Id}).Error("Failed to encode event")
+						continue
+					}
+
+					// the client resumes below events which may still commit, so it may get some events again
+					fmt.Fprintf(ctx, "id: %d\nevent: %s\ndata: %s\n\n", cursor.ResumeId(event), event.Type, data)
+				}
+
+				if len(events) > 0 {
+					flusher.Flush()
+				}
+			}
+		}
+	}
+}
+
+var errInvalidLastEventId = errors.New("Invalid Last-Event-ID")
+
+// lastEventId returns the id of the event a stream starts after
+func lastEventId(ctx iris.Context, eventService srvorder.OrderEventService) (int64, error) {
+	header := ctx.GetHeader("Last-Event-ID")
+	if header == "" {
+		return eventService.LatestEventId(ctx.Request().Context())
+	}
+
+	id, err := strconv.ParseInt(header, 10, 64)
+	if err != nil || id < 0 {
+		return 0, errInvalidLastEventId
+	}
+
+	return id, nil
+}
+
+// parseArea reads the optional lat, lng and radius query parameters, which must be given together
+func parseArea(ctx iris.Context) (*models.Area, error) {
+	lat, lng, radius := ctx.URLParam("lat"), ctx.URLParam("lng"), ctx.URLParam("radius")
+	if lat == "" && lng == "" && radius == "" {
+		return nil, nil
+	}
+	if lat == "" || lng == "" || radius == "" {
+		return nil, errors.New("lat, lng and radius must be given together")
+	}
+
+	if validate.Var(lat, "latitude") != nil || validate.Var(lng, "longitude") != nil {
+		return nil, errors.New("Invalid lat or lng")
+	}
+
+	r, err := strconv.ParseFloat(radius, 64)
+	if err != nil || r <= 0 {
+		return nil, errors.New("Invalid radius")
+	}
+
+	area := &models.Area{Radius: r}
+	area.Lat, _ = strconv.ParseFloat(lat, 64)
+	area.Lng, _ = strconv.ParseFloat(lng, 64)
+
+	return area, nil
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"order-service/logger"
+	"order-service/models"
+	"order-service/problem"
+	srvorder "order-service/services"
+
+	"github.com/kataras/iris"
+	"github.com/kataras/iris/context"
+	"github.com/sirupsen/logrus"
+)
+
+type CreateWebhookReq struct {
+	Url    string   `json:"url" validate:"required,url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=order.placed order.taken"`
+	Secret string   `json:"secret" validate:"required,min=16,max=255"`
+}
+
+func CreateWebhook(webhookService srvorder.WebhookService) context.Handler {
+	return func(ctx iris.Context) {
+		log := logger.FromContext(ctx.Request().Context()).WithFields(logrus.Fields{"module": "handler", "method": "CreateWebhook"})
+
+		var req CreateWebhookReq
+		err := ctx.ReadJSON(&req)
+		if err != nil {
+			problem.Write(ctx, iris.StatusBadRequest, problem.CodeInvalidJSON, "Invalid json provided")
+			return
+		}
+
+		err = validate.Struct(req)
+		if err != nil {
+			problem.Send(ctx, problem.Validation(err))
+			return
+		}
+
+		log = log.WithFields(logrus.Fields{"url": req.Url, "events": req.Events})
+
+		webhook, err := webhookService.Subscribe(ctx.Request().Context(), req.Url, req.Events, req.Secret)
+		if err == srvorder.ErrInvalidWebhookUrl {
+			problem.Send(ctx, problem.Invalid(problem.FieldError{Field: "url", Code: "url", Message: "must be an absolute http or https URL"}))
+			return
+		}
+		if err == srvorder.ErrWebhookUrlNotPublic {
+			problem.Send(ctx, problem.Invalid(problem.FieldError{Field: "url", Code: "public_url", Message: "must resolve to public addresses only"}))
+			return
+		}
+		if err == srvorder.ErrInvalidEvent {
+			problem.Send(ctx, problem.Invalid(problem.FieldError{Field: "events", Code: "oneof", Message: "must be one of order.placed, order.taken"}))
+			return
+		}
+		if srvorder.IsTimeout(err) {
+			log.WithField("err", err).Error("Failed to create webhook, since request timed out")
+			problem.Write(ctx, iris.StatusGatewayTimeout, problem.CodeTimeout, "Request timed out")
+			return
+		}
+		if err != nil {
+			log.WithField("err", err).Error("Failed to create webhook")
+			problem.Write(ctx, iris.StatusInternalServerError, problem.CodeInternal, "Failed to create webhook")
+			return
+		}
+
+		log.WithField("webhook_id", webhook.Id).Info("Successfully created webhook")
+
+		ctx.StatusCode(iris.StatusCreated)
+		ctx.JSON(webhook)
+	}
+}
+
+func ListWebhookDeliveries(webhookService srvorder.WebhookService) context.Handler {
+	return func(ctx iris.Context) {
+		log := logger.FromContext(ctx.Request().Context()).WithFields(logrus.Fields{"module": "handler", "method": "ListWebhookDeliveries"})
+
+		id, err := ctx.Params().GetInt64("id")
+		if err != nil || id < 0 {
+			problem.Write(ctx, iris.StatusBadRequest, problem.CodeInvalidParameter, "webhook_id must be an integer")
+			return
+		}
+
+		limit := ctx.Values().Get("_limit").(int)
+		offset := ctx.Values().Get("_offset").(int)
+
+		log = log.WithFields(logrus.Fields{"webhook_id": id, "limit": limit, "offset": offset})
+
+		deliveries, err := webhookService.ListDeliveries(ctx.Request().Context(), id, offset, limit)
+		if err == models.ErrNotFound {
+			problem.Write(ctx, iris.StatusNotFound, problem.CodeWebhookNotFound, "Webhook not found")
+			return
+		}
+		if srvorder.IsTimeout(err) {
+			log.WithField("err", err).Error("Failed to retrieve webhook deliveries, since request timed out")
+			problem.Write(ctx, iris.StatusGatewayTimeout, problem.CodeTimeout, "Request timed out")
+			return
+		}
+		if err != nil {
+			log.WithField("err", err).Error("Failed to retrieve webhook deliveries")
+			problem.Write(ctx, iris.StatusInternalServerError, problem.CodeInternal, "Failed to retrieve webhook deliveries")
+			return
+		}
+
+		ctx.JSON(deliveries)
+	}
+}
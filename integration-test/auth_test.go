@@ -0,0 +1,94 @@
+package integration_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	merchant      = "integration-test"
+	otherMerchant = "integration-test-other"
+)
+
+var (
+	customerToken = newToken("customer", merchant)
+	driverToken   = newToken("driver", merchant)
+	opsToken      = newToken("ops", merchant)
+
+	otherDriverToken = newToken("driver", otherMerchant)
+	otherOpsToken    = newToken("ops", otherMerchant)
+)
+
+// newToken signs a short lived token for role of merchantId with the JWT_HS256_SECRET the service under test is running with
+func newToken(role, merchantId string) string {
+	godotenv.Load("../.env")
+
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":         "integration-test",
+		"role":        role,
+		"merchant_id": merchantId,
+		"exp":         time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(os.Getenv("JWT_HS256_SECRET")))
+
+	return token
+}
+
+func get(url, token string) (*http.Response, error) {
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return http.DefaultClient.Do(req)
+}
+
+func post(url, token string, body io.Reader) (*http.Response, error) {
+	req, _ := http.NewRequest("POST", url, body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return http.DefaultClient.Do(req)
+}
+
+func TestAuth(t *testing.T) {
+	t.Run("it should return 401 without credentials", func(t *testing.T) {
+		bs, _ := json.Marshal(getPlaceOrderParams())
+		resp, err := http.Post(host+"/orders", "application/json", bytes.NewBuffer(bs))
+		if assert.Nil(t, err) {
+			defer resp.Body.Close()
+			assert.Equal(t, 401, resp.StatusCode)
+		}
+	})
+
+	t.Run("it should return 401 for an invalid token", func(t *testing.T) {
+		resp, err := get(host+"/orders?page=1&limit=1", "invalid")
+		if assert.Nil(t, err) {
+			defer resp.Body.Close()
+			assert.Equal(t, 401, resp.StatusCode)
+		}
+	})
+
+	t.Run("it should return 403 when a customer lists all orders", func(t *testing.T) {
+		resp, err := get(host+"/orders?page=1&limit=1", customerToken)
+		if assert.Nil(t, err) {
+			defer resp.Body.Close()
+			assert.Equal(t, 403, resp.StatusCode)
+		}
+	})
+
+	t.Run("it should return 403 when a customer takes an order", func(t *testing.T) {
+		bs, _ := json.Marshal(getTakeOrderParams())
+		req, _ := http.NewRequest("PATCH", host+"/orders/1", bytes.NewBuffer(bs))
+		req.Header.Set("Authorization", "Bearer "+customerToken)
+		resp, err := http.DefaultClient.Do(req)
+		if assert.Nil(t, err) {
+			defer resp.Body.Close()
+			assert.Equal(t, 403, resp.StatusCode)
+		}
+	})
+}
@@ -35,7 +35,7 @@ func TestNotFoundURL(t *testing.T) {
 				defer resp.Body.Close()
 
 				assert.Equal(t, 404, resp.StatusCode, "it should return 404 as status code")
-				assert.Equal(t, "application/json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/json in header")
+				assert.Equal(t, "application/problem+json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/problem+json in header")
 
 				var m map[string]interface{}
 				bs, _ := ioutil.ReadAll(resp.Body)
@@ -56,7 +56,7 @@ func TestPlaceOrder(t *testing.T) {
 				delete(params, "origin")
 
 				bs, _ := json.Marshal(params)
-				resp, err := http.Post(host+"/orders", "application/json", bytes.NewBuffer(bs))
+				resp, err := post(host+"/orders", customerToken, bytes.NewBuffer(bs))
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 					assert.Equal(t, 400, resp.StatusCode)
@@ -68,7 +68,7 @@ func TestPlaceOrder(t *testing.T) {
 				delete(params, "destination")
 
 				bs, _ := json.Marshal(params)
-				resp, err := http.Post(host+"/orders", "application/json", bytes.NewBuffer(bs))
+				resp, err := post(host+"/orders", customerToken, bytes.NewBuffer(bs))
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 					assert.Equal(t, 400, resp.StatusCode)
@@ -80,7 +80,7 @@ func TestPlaceOrder(t *testing.T) {
 				params["origin"] = []float64{1, 1}
 
 				bs, _ := json.Marshal(params)
-				resp, err := http.Post(host+"/orders", "application/json", bytes.NewBuffer(bs))
+				resp, err := post(host+"/orders", customerToken, bytes.NewBuffer(bs))
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 
@@ -88,7 +88,7 @@ func TestPlaceOrder(t *testing.T) {
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.IsType(t, "", m["error"], "it should contain error message")
+						assert.IsType(t, "", m["code"], "it should contain error code")
 					}
 				}
 			})
@@ -98,7 +98,7 @@ func TestPlaceOrder(t *testing.T) {
 				params["origin"] = []string{"a", "b"}
 
 				bs, _ := json.Marshal(params)
-				resp, err := http.Post(host+"/orders", "application/json", bytes.NewBuffer(bs))
+				resp, err := post(host+"/orders", customerToken, bytes.NewBuffer(bs))
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 
@@ -106,7 +106,7 @@ func TestPlaceOrder(t *testing.T) {
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.IsType(t, "", m["error"], "it should contain error message")
+						assert.IsType(t, "", m["code"], "it should contain error code")
 					}
 				}
 			})
@@ -116,7 +116,7 @@ func TestPlaceOrder(t *testing.T) {
 				params["origin"] = []string{"9999999", "-99999999"}
 
 				bs, _ := json.Marshal(params)
-				resp, err := http.Post(host+"/orders", "application/json", bytes.NewBuffer(bs))
+				resp, err := post(host+"/orders", customerToken, bytes.NewBuffer(bs))
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 
@@ -124,7 +124,7 @@ func TestPlaceOrder(t *testing.T) {
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.IsType(t, "", m["error"], "it should contain error message")
+						assert.IsType(t, "", m["code"], "it should contain error code")
 					}
 				}
 			})
@@ -134,7 +134,7 @@ func TestPlaceOrder(t *testing.T) {
 				params["origin"] = []string{"22.286681", "114.193260", "114.193260"}
 
 				bs, _ := json.Marshal(params)
-				resp, err := http.Post(host+"/orders", "application/json", bytes.NewBuffer(bs))
+				resp, err := post(host+"/orders", customerToken, bytes.NewBuffer(bs))
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 
@@ -142,7 +142,7 @@ func TestPlaceOrder(t *testing.T) {
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.IsType(t, "", m["error"], "it should contain error message")
+						assert.IsType(t, "", m["code"], "it should contain error code")
 					}
 				}
 			})
@@ -151,7 +151,7 @@ func TestPlaceOrder(t *testing.T) {
 				params := getFarAwayPlaceOrderParams()
 
 				bs, _ := json.Marshal(params)
-				resp, err := http.Post(host+"/orders", "application/json", bytes.NewBuffer(bs))
+				resp, err := post(host+"/orders", customerToken, bytes.NewBuffer(bs))
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 
@@ -159,7 +159,7 @@ func TestPlaceOrder(t *testing.T) {
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.Equal(t, "cannot calculate distance for given location", m["error"], "it should contain error message")
+						assert.Equal(t, "cannot_calculate_distance", m["code"], "it should contain error code")
 					}
 				}
 			})
@@ -170,7 +170,7 @@ func TestPlaceOrder(t *testing.T) {
 
 			params := getPlaceOrderParams()
 			bs, _ := json.Marshal(params)
-			resp, err := http.Post(host+"/orders", "application/json", bytes.NewBuffer(bs))
+			resp, err := post(host+"/orders", customerToken, bytes.NewBuffer(bs))
 
 			if assert.Nil(t, err) {
 				defer resp.Body.Close()
@@ -182,7 +182,7 @@ func TestPlaceOrder(t *testing.T) {
 				bs, _ := ioutil.ReadAll(resp.Body)
 				err = json.Unmarshal(bs, &m)
 				if assert.Nil(t, err, "it should return a valid order json object") {
-					_, ok := m["id"].(float64)
+					_, ok := m["id"].(string)
 					assert.True(t, ok)
 
 					assert.Equal(t, "UNASSIGNED", m["status"], "order status should be UNASSIGNED")
@@ -204,6 +204,7 @@ func TestTakeOrder(t *testing.T) {
 				bs, _ := json.Marshal(params)
 
 				req, _ := http.NewRequest("PATCH", host+"/orders", bytes.NewBuffer(bs))
+				req.Header.Set("Authorization", "Bearer "+driverToken)
 				resp, err := http.DefaultClient.Do(req)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
@@ -214,7 +215,7 @@ func TestTakeOrder(t *testing.T) {
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.IsType(t, "", m["error"], "it should contain error message")
+						assert.IsType(t, "", m["code"], "it should contain error code")
 					}
 				}
 			})
@@ -224,6 +225,7 @@ func TestTakeOrder(t *testing.T) {
 				bs, _ := json.Marshal(params)
 
 				req, _ := http.NewRequest("PATCH", host+"/orders/abc", bytes.NewBuffer(bs))
+				req.Header.Set("Authorization", "Bearer "+driverToken)
 				resp, err := http.DefaultClient.Do(req)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
@@ -234,7 +236,7 @@ func TestTakeOrder(t *testing.T) {
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.Equal(t, "order_id must be an integer", m["error"])
+						assert.Equal(t, "invalid_parameter", m["code"])
 					}
 				}
 			})
@@ -244,6 +246,7 @@ func TestTakeOrder(t *testing.T) {
 				bs, _ := json.Marshal(params)
 
 				req, _ := http.NewRequest("PATCH", host+"/orders/9999999999999", bytes.NewBuffer(bs))
+				req.Header.Set("Authorization", "Bearer "+driverToken)
 				resp, err := http.DefaultClient.Do(req)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
@@ -254,7 +257,7 @@ func TestTakeOrder(t *testing.T) {
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.Equal(t, "Order not found", m["error"])
+						assert.Equal(t, "order_not_found", m["code"])
 					}
 				}
 			})
@@ -262,12 +265,12 @@ func TestTakeOrder(t *testing.T) {
 
 		t.Run("invalid request param", func(t *testing.T) {
 
-			var orderId int
+			var orderId string
 
 			// pre create order
 			params := getPlaceOrderParams()
 			bs, _ := json.Marshal(params)
-			resp, err := http.Post(host+"/orders", "application/json", bytes.NewBuffer(bs))
+			resp, err := post(host+"/orders", customerToken, bytes.NewBuffer(bs))
 
 			if assert.Nil(t, err) {
 				defer resp.Body.Close()
@@ -276,9 +279,8 @@ func TestTakeOrder(t *testing.T) {
 				bs, _ := ioutil.ReadAll(resp.Body)
 				err = json.Unmarshal(bs, &m)
 				if assert.Nil(t, err) {
-					id, ok := m["id"].(float64)
-					assert.True(t, ok)
-					orderId = int(id)
+					orderId, _ = m["id"].(string)
+					assert.Len(t, orderId, 26, "orders are known by their public id")
 				}
 			}
 
@@ -288,7 +290,8 @@ func TestTakeOrder(t *testing.T) {
 
 				bs, _ := json.Marshal(params)
 
-				req, _ := http.NewRequest("PATCH", host+"/orders/"+fmt.Sprint(orderId), bytes.NewBuffer(bs))
+				req, _ := http.NewRequest("PATCH", host+"/orders/"+orderId, bytes.NewBuffer(bs))
+				req.Header.Set("Authorization", "Bearer "+driverToken)
 				resp, err := http.DefaultClient.Do(req)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
@@ -299,7 +302,7 @@ func TestTakeOrder(t *testing.T) {
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.IsType(t, "", m["error"], "it should contain error message")
+						assert.IsType(t, "", m["code"], "it should contain error code")
 					}
 				}
 			})
@@ -310,7 +313,8 @@ func TestTakeOrder(t *testing.T) {
 
 				bs, _ := json.Marshal(params)
 
-				req, _ := http.NewRequest("PATCH", host+"/orders/"+fmt.Sprint(orderId), bytes.NewBuffer(bs))
+				req, _ := http.NewRequest("PATCH", host+"/orders/"+orderId, bytes.NewBuffer(bs))
+				req.Header.Set("Authorization", "Bearer "+driverToken)
 				resp, err := http.DefaultClient.Do(req)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
@@ -321,7 +325,7 @@ func TestTakeOrder(t *testing.T) {
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.IsType(t, "", m["error"], "it should contain error message")
+						assert.IsType(t, "", m["code"], "it should contain error code")
 					}
 				}
 			})
@@ -330,12 +334,12 @@ func TestTakeOrder(t *testing.T) {
 		t.Run("take order with valid params", func(t *testing.T) {
 
 			// pre create order
-			var orderId int
+			var orderId string
 
 			// pre create order
 			params := getPlaceOrderParams()
 			bs, _ := json.Marshal(params)
-			resp, err := http.Post(host+"/orders", "application/json", bytes.NewBuffer(bs))
+			resp, err := post(host+"/orders", customerToken, bytes.NewBuffer(bs))
 
 			if assert.Nil(t, err) {
 				defer resp.Body.Close()
@@ -344,9 +348,8 @@ func TestTakeOrder(t *testing.T) {
 				bs, _ := ioutil.ReadAll(resp.Body)
 				err = json.Unmarshal(bs, &m)
 				if assert.Nil(t, err) {
-					id, ok := m["id"].(float64)
-					assert.True(t, ok)
-					orderId = int(id)
+					orderId, _ = m["id"].(string)
+					assert.Len(t, orderId, 26, "orders are known by their public id")
 				}
 			}
 
@@ -355,7 +358,8 @@ func TestTakeOrder(t *testing.T) {
 
 				bs, _ := json.Marshal(params)
 
-				req, _ := http.NewRequest("PATCH", host+"/orders/"+fmt.Sprint(orderId), bytes.NewBuffer(bs))
+				req, _ := http.NewRequest("PATCH", host+"/orders/"+orderId, bytes.NewBuffer(bs))
+				req.Header.Set("Authorization", "Bearer "+driverToken)
 				resp, err := http.DefaultClient.Do(req)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
@@ -375,12 +379,12 @@ func TestTakeOrder(t *testing.T) {
 		t.Run("race condition", func(t *testing.T) {
 
 			// pre create order
-			var orderId int
+			var orderId string
 
 			// pre create order
 			params := getPlaceOrderParams()
 			bs, _ := json.Marshal(params)
-			resp, err := http.Post(host+"/orders", "application/json", bytes.NewBuffer(bs))
+			resp, err := post(host+"/orders", customerToken, bytes.NewBuffer(bs))
 
 			if assert.Nil(t, err) {
 				defer resp.Body.Close()
@@ -389,9 +393,8 @@ func TestTakeOrder(t *testing.T) {
 				bs, _ := ioutil.ReadAll(resp.Body)
 				err = json.Unmarshal(bs, &m)
 				if assert.Nil(t, err) {
-					id, ok := m["id"].(float64)
-					assert.True(t, ok)
-					orderId = int(id)
+					orderId, _ = m["id"].(string)
+					assert.Len(t, orderId, 26, "orders are known by their public id")
 				}
 			}
 
@@ -400,8 +403,10 @@ func TestTakeOrder(t *testing.T) {
 
 				bs, _ := json.Marshal(params)
 
-				req1, _ := http.NewRequest("PATCH", host+"/orders/"+fmt.Sprint(orderId), bytes.NewBuffer(bs))
-				req2, _ := http.NewRequest("PATCH", host+"/orders/"+fmt.Sprint(orderId), bytes.NewBuffer(bs))
+				req1, _ := http.NewRequest("PATCH", host+"/orders/"+orderId, bytes.NewBuffer(bs))
+				req1.Header.Set("Authorization", "Bearer "+driverToken)
+				req2, _ := http.NewRequest("PATCH", host+"/orders/"+orderId, bytes.NewBuffer(bs))
+				req2.Header.Set("Authorization", "Bearer "+driverToken)
 
 				ch := make(chan *http.Response, 2)
 
@@ -443,86 +448,86 @@ func TestListOrders(t *testing.T) {
 		t.Run("invalid page", func(t *testing.T) {
 
 			t.Run("missing page", func(t *testing.T) {
-				resp, err := http.Get(host + "/orders?limit=1")
+				resp, err := get(host+"/orders?limit=1", opsToken)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 
 					assert.Equal(t, 400, resp.StatusCode, "status code should be 400")
-					assert.Equal(t, "application/json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/json in header")
+					assert.Equal(t, "application/problem+json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/problem+json in header")
 
 					var m map[string]interface{}
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.IsType(t, "", m["error"], "it should contain error message")
+						assert.IsType(t, "", m["code"], "it should contain error code")
 					}
 				}
 			})
 
 			t.Run("of type alphabetic character", func(t *testing.T) {
-				resp, err := http.Get(host + "/orders?page=a&limit=1")
+				resp, err := get(host+"/orders?page=a&limit=1", opsToken)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 
 					assert.Equal(t, 400, resp.StatusCode, "status code should be 400")
-					assert.Equal(t, "application/json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/json in header")
+					assert.Equal(t, "application/problem+json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/problem+json in header")
 
 					var m map[string]interface{}
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.IsType(t, "", m["error"], "it should contain error message")
+						assert.IsType(t, "", m["code"], "it should contain error code")
 					}
 				}
 			})
 
 			t.Run("of type float", func(t *testing.T) {
-				resp, err := http.Get(host + "/orders?page=1.1&limit=1")
+				resp, err := get(host+"/orders?page=1.1&limit=1", opsToken)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 
 					assert.Equal(t, 400, resp.StatusCode, "status code should be 400")
-					assert.Equal(t, "application/json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/json in header")
+					assert.Equal(t, "application/problem+json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/problem+json in header")
 
 					var m map[string]interface{}
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.IsType(t, "", m["error"], "it should contain error message")
+						assert.IsType(t, "", m["code"], "it should contain error code")
 					}
 				}
 			})
 
 			t.Run("negative page", func(t *testing.T) {
-				resp, err := http.Get(host + "/orders?page=-1&limit=1")
+				resp, err := get(host+"/orders?page=-1&limit=1", opsToken)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 
 					assert.Equal(t, 400, resp.StatusCode, "status code should be 400")
-					assert.Equal(t, "application/json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/json in header")
+					assert.Equal(t, "application/problem+json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/problem+json in header")
 
 					var m map[string]interface{}
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.IsType(t, "", m["error"], "it should contain error message")
+						assert.IsType(t, "", m["code"], "it should contain error code")
 					}
 				}
 			})
 
 			t.Run("zero", func(t *testing.T) {
-				resp, err := http.Get(host + "/orders?page=0&limit=1")
+				resp, err := get(host+"/orders?page=0&limit=1", opsToken)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 
 					assert.Equal(t, 400, resp.StatusCode, "status code should be 400")
-					assert.Equal(t, "application/json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/json in header")
+					assert.Equal(t, "application/problem+json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/problem+json in header")
 
 					var m map[string]interface{}
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.IsType(t, "", m["error"], "it should contain error message")
+						assert.IsType(t, "", m["code"], "it should contain error code")
 					}
 				}
 			})
@@ -530,69 +535,69 @@ func TestListOrders(t *testing.T) {
 
 		t.Run("invalid limit", func(t *testing.T) {
 			t.Run("missing limit", func(t *testing.T) {
-				resp, err := http.Get(host + "/orders?page=1")
+				resp, err := get(host+"/orders?page=1", opsToken)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 
 					assert.Equal(t, 400, resp.StatusCode, "status code should be 400")
-					assert.Equal(t, "application/json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/json in header")
+					assert.Equal(t, "application/problem+json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/problem+json in header")
 
 					var m map[string]interface{}
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.IsType(t, "", m["error"], "it should contain error message")
+						assert.IsType(t, "", m["code"], "it should contain error code")
 					}
 				}
 			})
 
 			t.Run("of type alphabetic character", func(t *testing.T) {
-				resp, err := http.Get(host + "/orders?page=1&limit=a")
+				resp, err := get(host+"/orders?page=1&limit=a", opsToken)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 
 					assert.Equal(t, 400, resp.StatusCode, "status code should be 400")
-					assert.Equal(t, "application/json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/json in header")
+					assert.Equal(t, "application/problem+json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/problem+json in header")
 
 					var m map[string]interface{}
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.IsType(t, "", m["error"], "it should contain error message")
+						assert.IsType(t, "", m["code"], "it should contain error code")
 					}
 				}
 			})
 
 			t.Run("of type float", func(t *testing.T) {
-				resp, err := http.Get(host + "/orders?page=1&limit=1.1")
+				resp, err := get(host+"/orders?page=1&limit=1.1", opsToken)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 
 					assert.Equal(t, 400, resp.StatusCode, "status code should be 400")
-					assert.Equal(t, "application/json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/json in header")
+					assert.Equal(t, "application/problem+json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/problem+json in header")
 
 					var m map[string]interface{}
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.IsType(t, "", m["error"], "it should contain error message")
+						assert.IsType(t, "", m["code"], "it should contain error code")
 					}
 				}
 			})
 
 			t.Run("negative limit", func(t *testing.T) {
-				resp, err := http.Get(host + "/orders?page=1&limit=-1")
+				resp, err := get(host+"/orders?page=1&limit=-1", opsToken)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 
 					assert.Equal(t, 400, resp.StatusCode, "status code should be 400")
-					assert.Equal(t, "application/json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/json in header")
+					assert.Equal(t, "application/problem+json; charset=UTF-8", resp.Header.Get("Content-Type"), "it should return application/problem+json in header")
 
 					var m map[string]interface{}
 					bs, _ := ioutil.ReadAll(resp.Body)
 					err = json.Unmarshal(bs, &m)
 					if assert.Nil(t, err, "it should return json response") {
-						assert.IsType(t, "", m["error"], "it should contain error message")
+						assert.IsType(t, "", m["code"], "it should contain error code")
 					}
 				}
 			})
@@ -600,7 +605,7 @@ func TestListOrders(t *testing.T) {
 
 		t.Run("it should return created orders with valid input", func(t *testing.T) {
 			t.Run("return empty list when there is no more orders at that page", func(t *testing.T) {
-				resp, err := http.Get(host + "/orders?page=9999999999&limit=10")
+				resp, err := get(host+"/orders?page=9999999999&limit=10", opsToken)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 
@@ -617,7 +622,7 @@ func TestListOrders(t *testing.T) {
 			})
 
 			t.Run("return orders given a large limit number", func(t *testing.T) {
-				resp, err := http.Get(host + "/orders?page=1&limit=999999999")
+				resp, err := get(host+"/orders?page=1&limit=999999999", opsToken)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 
@@ -628,7 +633,7 @@ func TestListOrders(t *testing.T) {
 
 			t.Run("number of order should less than limit", func(t *testing.T) {
 				limit := 1
-				resp, err := http.Get(host + "/orders?page=1&limit=" + fmt.Sprint(limit))
+				resp, err := get(host+"/orders?page=1&limit="+fmt.Sprint(limit), opsToken)
 				if assert.Nil(t, err) {
 					defer resp.Body.Close()
 
@@ -0,0 +1,69 @@
+package integration_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenancy(t *testing.T) {
+	var orderId string
+
+	// pre create order of merchant
+	bs, _ := json.Marshal(getPlaceOrderParams())
+	resp, err := post(host+"/orders", customerToken, bytes.NewBuffer(bs))
+	if assert.Nil(t, err) {
+		defer resp.Body.Close()
+
+		var m map[string]interface{}
+		bs, _ := ioutil.ReadAll(resp.Body)
+		err = json.Unmarshal(bs, &m)
+		if assert.Nil(t, err) {
+			orderId, _ = m["id"].(string)
+			assert.Len(t, orderId, 26, "orders are known by their public id")
+		}
+	}
+
+	t.Run("it should return 404 when another merchant takes the order", func(t *testing.T) {
+		bs, _ := json.Marshal(getTakeOrderParams())
+		req, _ := http.NewRequest("PATCH", host+"/orders/"+orderId, bytes.NewBuffer(bs))
+		req.Header.Set("Authorization", "Bearer "+otherDriverToken)
+		resp, err := http.DefaultClient.Do(req)
+		if assert.Nil(t, err) {
+			defer resp.Body.Close()
+			assert.Equal(t, 404, resp.StatusCode)
+		}
+	})
+
+	t.Run("it should not list the order to another merchant", func(t *testing.T) {
+		resp, err := get(host+"/orders?page=1&limit=100", otherOpsToken)
+		if assert.Nil(t, err) {
+			defer resp.Body.Close()
+			assert.Equal(t, 200, resp.StatusCode)
+
+			var orders []map[string]interface{}
+			bs, _ := ioutil.ReadAll(resp.Body)
+			err = json.Unmarshal(bs, &orders)
+			if assert.Nil(t, err) {
+				for _, order := range orders {
+					assert.NotEqual(t, orderId, order["id"])
+				}
+			}
+		}
+	})
+
+	t.Run("it should still let the merchant take the order", func(t *testing.T) {
+		bs, _ := json.Marshal(getTakeOrderParams())
+		req, _ := http.NewRequest("PATCH", host+"/orders/"+orderId, bytes.NewBuffer(bs))
+		req.Header.Set("Authorization", "Bearer "+driverToken)
+		resp, err := http.DefaultClient.Do(req)
+		if assert.Nil(t, err) {
+			defer resp.Body.Close()
+			assert.Equal(t, 200, resp.StatusCode)
+		}
+	})
+}
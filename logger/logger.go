@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ctxKey int
+
+const requestIdKey ctxKey = iota
+
+// WithRequestId returns a copy of ctx carrying the given request id
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey, requestId)
+}
+
+// RequestId returns the request id carried by ctx, or an empty string if there is none
+func RequestId(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey).(string)
+	return requestId
+}
+
+// FromContext returns a log entry tagged with the request id and trace id carried by ctx,
+// so log lines from handlers, services and repositories of one request can be correlated
+func FromContext(ctx context.Context) *logrus.Entry {
+	fields := logrus.Fields{}
+
+	if requestId := RequestId(ctx); requestId != "" {
+		fields["request_id"] = requestId
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields["trace_id"] = sc.TraceID().String()
+	}
+
+	return logrus.WithFields(fields)
+}
@@ -0,0 +1,24 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContext(t *testing.T) {
+	t.Run("with request id", func(t *testing.T) {
+		ctx := WithRequestId(context.Background(), "abc")
+
+		assert.Equal(t, "abc", RequestId(ctx))
+		assert.Equal(t, "abc", FromContext(ctx).Data["request_id"])
+	})
+
+	t.Run("without request id", func(t *testing.T) {
+		ctx := context.Background()
+
+		assert.Equal(t, "", RequestId(ctx))
+		assert.NotContains(t, FromContext(ctx).Data, "request_id")
+	})
+}
@@ -1,12 +1,28 @@
 package main
 
 import (
+	"context"
+	"net"
 	"os"
+	"strconv"
+	"time"
 
+	"order-service/grpcserver"
+	"order-service/handlers"
+	"order-service/metrics"
 	"order-service/repositories"
 	"order-service/routers"
+	"order-service/services/archival"
+	"order-service/services/auth"
 	"order-service/services/distance"
+	"order-service/services/event"
+	"order-service/services/hub"
+	"order-service/services/idempotency"
 	"order-service/services/order"
+	"order-service/services/outbox"
+	"order-service/services/publisher"
+	"order-service/services/ratelimit"
+	"order-service/services/webhook"
 	"order-service/startup"
 
 	"github.com/kataras/iris"
@@ -15,17 +31,215 @@ import (
 
 func main() {
 	startup.Init()
+	shutdownTracing := startup.InitTracing()
 
-	orderRepo := repositories.NewMysqlOrderRepo(startup.Db)
-	distanceCalculator, err := distance.NewDistanceService()
+	if err := registerDBMetrics(); err != nil {
+		log.WithError(err).Error("Failed to register database metrics")
+		os.Exit(1)
+	}
+
+	repositories.ReadRetries = startup.Config.Pool.ReadRetries
+
+	// orders and their outbox live in the database of the configured order driver
+	newOrderRepo, newOutboxRepo := repositories.NewMysqlOrderRepo, repositories.NewMysqlOutboxRepo
+	switch startup.Config.Database.OrderDriver {
+	case startup.DriverPostgres:
+		newOrderRepo, newOutboxRepo = repositories.NewPostgresOrderRepo, repositories.NewPostgresOutboxRepo
+	case startup.DriverSqlite:
+		newOrderRepo, newOutboxRepo = repositories.NewSqliteOrderRepo, repositories.NewSqliteOutboxRepo
+	}
+
+	// webhooks, idempotency keys and api keys live in the main database
+	newWebhookRepo, newIdempotencyRepo, newApiKeyRepo := repositories.NewMysqlWebhookRepo, repositories.NewMysqlIdempotencyRepo, repositories.NewMysqlApiKeyRepo
+	if startup.Config.Database.Driver == startup.DriverSqlite {
+		newWebhookRepo, newIdempotencyRepo, newApiKeyRepo = repositories.NewSqliteWebhookRepo, repositories.NewSqliteIdempotencyRepo, repositories.NewSqliteApiKeyRepo
+	}
+
+	sqlOrderRepo := newOrderRepo(startup.OrderDb, startup.Config.Timeout.Database)
+	sqlOrderRepo.Replicas = startup.OrderReplicas
+	if err := sqlOrderRepo.Prepare(context.Background()); err != nil {
+		// the statements are prepared again on first use
+		log.WithError(err).Warn("Failed to prepare order statements")
+	}
+	orderRepo := repositories.NewTracedOrderRepo(sqlOrderRepo, sqlOrderRepo.Dialect.Name)
+	distanceCalculator, err := distance.NewDistanceService(startup.Config.Timeout.Distance)
 	if err != nil {
 		log.WithError(err).Error("Failed to get distance service")
 		os.Exit(1)
 	}
 
+	webhookRepo := newWebhookRepo(startup.Db, startup.Config.Timeout.Database)
+	webhookService := webhook.NewWebhookService(webhookRepo)
+	webhookWorker := webhook.NewWorker(webhookRepo, webhook.NewClient(startup.Config.Webhook.Timeout), webhook.WorkerOptions{
+		PollInterval: startup.Config.Webhook.PollInterval,
+		BatchSize:    100,
+		MaxAttempts:  startup.Config.Webhook.MaxAttempts,
+		MinBackoff:   startup.Config.Webhook.MinBackoff,
+		MaxBackoff:   startup.Config.Webhook.MaxBackoff,
+		Lease:        startup.Config.Webhook.Lease,
+	})
+
 	orderService := order.NewOrderService(orderRepo, distanceCalculator)
 
+	idempotencyRepo := newIdempotencyRepo(startup.Db, startup.Config.Timeout.Database)
+	idempotencyService := idempotency.NewIdempotencyService(idempotencyRepo, startup.Config.IdempotencyKeyTTL)
+
+	apiKeyRepo := newApiKeyRepo(startup.Db, startup.Config.Timeout.Database)
+	authenticator, err := auth.NewAuthenticator(apiKeyRepo, auth.Options{
+		HS256Secret: startup.Config.Auth.HS256Secret,
+		JWKSFile:    startup.Config.Auth.JWKSFile,
+		Issuer:      startup.Config.Auth.Issuer,
+		Audience:    startup.Config.Auth.Audience,
+	})
+	if err != nil {
+		log.WithError(err).Error("Failed to create authenticator")
+		os.Exit(1)
+	}
+
+	eventPublisher, err := publisher.NewFilePublisher(startup.Config.Outbox.PublisherFile)
+	if err != nil {
+		log.WithError(err).Error("Failed to create event publisher")
+		os.Exit(1)
+	}
+
+	outboxRepo := newOutboxRepo(startup.OrderDb, startup.Config.Timeout.Database)
+	// webhook deliveries are queued from the relayed events, so only committed changes are notified
+	outboxRelay := outbox.NewRelay(outboxRepo, publisher.NewMultiPublisher(eventPublisher, webhookService), outbox.RelayOptions{
+		PollInterval: startup.Config.Outbox.PollInterval,
+		BatchSize:    100,
+	})
+
+	orderEventService := event.NewOrderEventService(outboxRepo)
+	eventHub := hub.NewHub(orderEventService, hub.Options{
+		PollInterval: startup.Config.Stream.PollInterval,
+		SettleWindow: startup.Config.Stream.SettleWindow,
+		BatchSize:    100,
+		BufferSize:   100,
+	})
+
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	go webhookWorker.Run(workerCtx)
+	go outboxRelay.Run(workerCtx)
+	if startup.Config.Archive.Interval > 0 {
+		archiver := archival.NewArchiver(sqlOrderRepo, archival.Options{
+			Interval:   startup.Config.Archive.Interval,
+			After:      startup.Config.Archive.After,
+			BatchSize:  startup.Config.Archive.BatchSize,
+			BatchPause: startup.Config.Archive.BatchPause,
+		})
+		go archiver.Run(workerCtx)
+	}
+	if startup.Config.PublicIdBackfill.BatchSize > 0 {
+		go assignPublicIds(workerCtx, sqlOrderRepo, startup.Config.PublicIdBackfill.BatchSize, startup.Config.PublicIdBackfill.BatchPause)
+	}
+
+	// both servers count against the same buckets
+	rateLimiter := ratelimit.NewMemoryRateLimiter()
+
+	grpcServer := grpcserver.NewServer(grpcserver.Options{
+		OrderService:       orderService,
+		OrderEventService:  orderEventService,
+		Authenticator:      authenticator,
+		RequestTimeout:     startup.Config.Timeout.Request,
+		WatchPollInterval:  startup.Config.Stream.PollInterval,
+		WatchSettleWindow:  startup.Config.Stream.SettleWindow,
+		LegacyOrderIds:     startup.Config.LegacyOrderIds,
+		RateLimiter:        rateLimiter,
+		RateLimits:         startup.Config.RateLimits,
+		IdempotencyService: idempotencyService,
+	})
+	grpcListener, err := net.Listen("tcp", startup.Config.GrpcAddr)
+	if err != nil {
+		log.WithError(err).Error("Failed to listen for gRPC")
+		os.Exit(1)
+	}
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			log.WithError(err).Error("gRPC server stopped")
+		}
+	}()
+
 	app := iris.New()
-	routers.Register(app, orderService)
+	routers.Register(app, routers.Options{
+		OrderService:       orderService,
+		IdempotencyService: idempotencyService,
+		Authenticator:      authenticator,
+		WebhookService:     webhookService,
+		OrderEventService:  orderEventService,
+		EventHub:           eventHub,
+		RateLimiter:        rateLimiter,
+		RateLimits:         startup.Config.RateLimits,
+		RequestTimeout:     startup.Config.Timeout.Request,
+		LegacyOrderIds:     startup.Config.LegacyOrderIds,
+		Stream: handlers.StreamOrdersOptions{
+			PollInterval:      startup.Config.Stream.PollInterval,
+			HeartbeatInterval: startup.Config.Stream.HeartbeatInterval,
+			SettleWindow:      startup.Config.Stream.SettleWindow,
+		},
+		DriverChannel: handlers.DriverChannelOptions{
+			PingPeriod:     startup.Config.DriverChannel.PingPeriod,
+			MessageTimeout: startup.Config.Timeout.Request,
+		},
+	})
 	app.Run(iris.Addr(":8080"), iris.WithoutStartupLog)
+	stopWorker()
+	grpcserver.Shutdown(grpcServer, 5*time.Second)
+	if err := sqlOrderRepo.Close(); err != nil {
+		log.WithError(err).Error("Failed to close order statements")
+	}
+
+	// flush spans still buffered by the exporter before exiting
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		log.WithError(err).Error("Failed to flush traces")
+	}
+}
+
+// registerDBMetrics exports the pool stats of the main database, of the order database if it is a different one,
+// and of its read replicas
+func registerDBMetrics() error {
+	if err := metrics.RegisterDB(startup.Db, "main", metrics.RolePrimary); err != nil {
+		return err
+	}
+	if startup.OrderDb != startup.Db {
+		if err := metrics.RegisterDB(startup.OrderDb, "orders", metrics.RolePrimary); err != nil {
+			return err
+		}
+	}
+	for i, replica := range startup.OrderReplicas {
+		if err := metrics.RegisterDB(replica, "orders_replica_"+strconv.Itoa(i+1), metrics.RoleReplica); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// assignPublicIds gives a public id to the orders placed before public ids, batchSize orders at a time
+// pausing between batches, until every order has one. batchSize must be positive
+func assignPublicIds(ctx context.Context, repo *repositories.OrderRepo, batchSize int, pause time.Duration) {
+	assigned := 0
+	for {
+		n, err := repo.AssignPublicIds(ctx, batchSize)
+		assigned += n
+		if err != nil {
+			log.WithError(err).WithField("assigned", assigned).Error("Failed to assign public ids to orders")
+			return
+		}
+
+		if n < batchSize {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pause):
+		}
+	}
+
+	if assigned > 0 {
+		log.WithField("assigned", assigned).Info("Assigned public ids to orders")
+	}
 }
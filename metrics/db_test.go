@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDBStatsCollector(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	db.SetMaxOpenConns(7)
+
+	replica, _, err := sqlmock.New()
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer replica.Close()
+
+	replica.SetMaxOpenConns(3)
+
+	// the pools of several databases are registered side by side
+	registry := prometheus.NewPedanticRegistry()
+	assert.NoError(t, registry.Register(NewDBStatsCollector(db, "orders", RolePrimary)))
+	assert.NoError(t, registry.Register(NewDBStatsCollector(replica, "orders_replica_1", RoleReplica)))
+
+	expected := `
+# HELP order_service_db_max_open_connections Maximum number of open connections to the database.
+# TYPE order_service_db_max_open_connections gauge
+order_service_db_max_open_connections{db="orders",role="primary"} 7
+order_service_db_max_open_connections{db="orders_replica_1",role="replica"} 3
+`
+	err = testutil.GatherAndCompare(registry, strings.NewReader(expected), "order_service_db_max_open_connections")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "order_service"
+
+// outcome label values shared by the order counters
+const (
+	OutcomeSuccess         = "success"
+	OutcomeConflict        = "conflict"
+	OutcomeInvalidLocation = "invalid_location"
+	OutcomeError           = "error"
+	OutcomeRetry           = "retry"
+	OutcomeDead            = "dead"
+)
+
+var (
+	HttpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	HttpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	RateLimitedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_requests_total",
+		Help:      "Number of requests rejected by the rate limiter by route.",
+	}, []string{"route"})
+
+	PlaceOrders = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "place_order_total",
+		Help:      "Number of PlaceOrder calls by outcome.",
+	}, []string{"outcome"})
+
+	TakeOrders = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "take_order_total",
+		Help:      "Number of TakeOrder calls by outcome, conflict means the order was already taken.",
+	}, []string{"outcome"})
+
+	WebhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Number of webhook delivery attempts by outcome, dead means the delivery ran out of attempts.",
+	}, []string{"outcome"})
+
+	DriverConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "driver_connections",
+		Help:      "Number of open driver channel connections.",
+	})
+
+	DistanceRequestDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "distance_request_duration_seconds",
+		Help:      "Latency of distance provider requests.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	DistanceErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "distance_errors_total",
+		Help:      "Number of failed distance provider requests by reason.",
+	}, []string{"reason"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		HttpRequests,
+		HttpRequestDuration,
+		RateLimitedRequests,
+		PlaceOrders,
+		TakeOrders,
+		WebhookDeliveries,
+		DriverConnections,
+		DistanceRequestDuration,
+		DistanceErrors,
+	)
+}
@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"time"
+
+	"order-service/logger"
+
+	"github.com/kataras/iris"
+	"github.com/sirupsen/logrus"
+)
+
+// AccessLog emits one structured log line per request with its status, latency and response size
+func AccessLog(ctx iris.Context) {
+	start := time.Now()
+
+	ctx.Next()
+
+	route := ""
+	if r := ctx.GetCurrentRoute(); r != nil {
+		route = r.Path()
+	}
+
+	bytes := ctx.ResponseWriter().Written()
+	if bytes < 0 {
+		bytes = 0
+	}
+
+	logger.FromContext(ctx.Request().Context()).WithFields(logrus.Fields{
+		"module":     "access",
+		"method":     ctx.Method(),
+		"path":       ctx.Path(),
+		"route":      route,
+		"status":     ctx.GetStatusCode(),
+		"latency_ms": float64(time.Since(start).Nanoseconds()) / float64(time.Millisecond),
+		"bytes":      bytes,
+		"remote_ip":  ctx.RemoteAddr(),
+		"user_agent": ctx.GetHeader("User-Agent"),
+	}).Info("Request handled")
+}
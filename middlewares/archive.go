@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"strconv"
+
+	"order-service/archive"
+	"order-service/problem"
+
+	"github.com/kataras/iris"
+)
+
+// IncludeArchived makes the lookups of the request also find archived orders when include_archived is true
+func IncludeArchived(ctx iris.Context) {
+	included, err := strconv.ParseBool(ctx.URLParamDefault("include_archived", "false"))
+	if err != nil {
+		problem.Write(ctx, iris.StatusBadRequest, problem.CodeInvalidParameter, "include_archived must be true or false")
+		return
+	}
+
+	if included {
+		setRequestContext(ctx, archive.WithArchived(ctx.Request().Context()))
+	}
+
+	ctx.Next()
+}
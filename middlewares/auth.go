@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"order-service/models"
+	"order-service/problem"
+	srvorder "order-service/services"
+	srvauth "order-service/services/auth"
+	"order-service/tenant"
+
+	"github.com/kataras/iris"
+	"github.com/kataras/iris/context"
+)
+
+const ApiKeyHeader = "X-API-Key"
+
+// Authenticate resolves the caller from an X-API-Key header or an "Authorization: Bearer" token,
+// requests without valid credentials are rejected with 401. The request context is scoped to the
+// merchant of the caller, so the repositories only ever see that merchant's data
+func Authenticate(auth srvorder.Authenticator) context.Handler {
+	return func(ctx iris.Context) {
+		var principal *models.Principal
+		var err error
+
+		if key := ctx.GetHeader(ApiKeyHeader); key != "" {
+			principal, err = auth.AuthenticateApiKey(ctx.Request().Context(), key)
+		} else if token := srvauth.BearerToken(ctx.GetHeader("Authorization")); token != "" {
+			principal, err = auth.AuthenticateToken(ctx.Request().Context(), token)
+		} else {
+			ctx.Header("WWW-Authenticate", "Bearer")
+			problem.Write(ctx, iris.StatusUnauthorized, problem.CodeUnauthenticated, "Missing credentials")
+			return
+		}
+
+		if err == srvorder.ErrUnauthenticated {
+			ctx.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			problem.Write(ctx, iris.StatusUnauthorized, problem.CodeUnauthenticated, "Invalid credentials")
+			return
+		}
+		if srvorder.IsTimeout(err) {
+			problem.Write(ctx, iris.StatusGatewayTimeout, problem.CodeTimeout, "Request timed out")
+			return
+		}
+		if err != nil {
+			problem.Write(ctx, iris.StatusInternalServerError, problem.CodeInternal, "Failed to authenticate")
+			return
+		}
+
+		ctx.Values().Set("_principal", principal)
+		setRequestContext(ctx, tenant.WithMerchantId(ctx.Request().Context(), principal.MerchantId))
+		ctx.Next()
+	}
+}
+
+// RequireRole only lets callers with one of roles through, it must run after Authenticate
+func RequireRole(roles ...string) context.Handler {
+	return func(ctx iris.Context) {
+		principal, _ := ctx.Values().Get("_principal").(*models.Principal)
+
+		if principal == nil || !srvauth.HasRole(principal, roles) {
+			problem.Write(ctx, iris.StatusForbidden, problem.CodePermissionDenied, "Permission denied")
+			return
+		}
+
+		ctx.Next()
+	}
+}
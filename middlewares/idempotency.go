@@ -0,0 +1,106 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+
+	"order-service/logger"
+	"order-service/problem"
+	srvorder "order-service/services"
+	"order-service/services/idempotency"
+	"order-service/tenant"
+
+	"github.com/kataras/iris"
+	irisctx "github.com/kataras/iris/context"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// Idempotency makes a request sent with an Idempotency-Key header safe to retry, the response of the
+// first request is stored and returned again for retries with the same key and the same body
+func Idempotency(service srvorder.IdempotencyService) irisctx.Handler {
+	return func(ctx iris.Context) {
+		key := ctx.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+
+		log := logger.FromContext(ctx.Request().Context()).WithFields(logrus.Fields{"module": "middleware", "method": "Idempotency", "idempotency_key": key})
+
+		if len(key) > idempotency.MaxKeyLength {
+			problem.Write(ctx, iris.StatusBadRequest, problem.CodeInvalidParameter, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		body, err := ioutil.ReadAll(ctx.Request().Body)
+		if err != nil {
+			problem.Write(ctx, iris.StatusBadRequest, problem.CodeInvalidParameter, "Failed to read request body")
+			return
+		}
+		ctx.Request().Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.Sum256(body)
+
+		record, err := service.Begin(ctx.Request().Context(), key, hex.EncodeToString(hash[:]))
+		if err == srvorder.ErrIdempotencyKeyReused {
+			problem.Write(ctx, iris.StatusUnprocessableEntity, problem.CodeIdempotencyKeyReused, "Idempotency-Key already used for a different request")
+			return
+		}
+		if err == srvorder.ErrRequestInProgress {
+			problem.Write(ctx, iris.StatusConflict, problem.CodeRequestInProgress, "Request with the same Idempotency-Key is in progress")
+			return
+		}
+		if srvorder.IsTimeout(err) {
+			problem.Write(ctx, iris.StatusGatewayTimeout, problem.CodeTimeout, "Request timed out")
+			return
+		}
+		if err != nil {
+			problem.Write(ctx, iris.StatusInternalServerError, problem.CodeInternal, "Service unavailable")
+			return
+		}
+
+		if record.Completed() {
+			log.Debug("Replaying stored response")
+			ctx.Header(IdempotentReplayedHeader, "true")
+			// errors were stored as problems
+			if record.StatusCode >= iris.StatusBadRequest {
+				ctx.ContentType(problem.ContentType)
+			} else {
+				ctx.ContentType(irisctx.ContentJSONHeaderValue)
+			}
+			ctx.StatusCode(record.StatusCode)
+			ctx.Write(record.Response)
+			return
+		}
+
+		ctx.Record()
+		ctx.Next()
+
+		// the request context may already be done, store the outcome on a fresh one
+		c := logger.WithRequestId(context.Background(), logger.RequestId(ctx.Request().Context()))
+		if merchantId, ok := tenant.MerchantId(ctx.Request().Context()); ok {
+			c = tenant.WithMerchantId(c, merchantId)
+		}
+
+		status := ctx.GetStatusCode()
+		if status >= iris.StatusInternalServerError {
+			// the request failed, let the client retry it with the same key
+			if err := service.Release(c, key); err != nil {
+				log.WithError(err).Error("Failed to release idempotency key")
+			}
+			return
+		}
+
+		if err := service.Complete(c, key, status, ctx.Recorder().Body()); err != nil {
+			log.WithError(err).Error("Failed to store response for idempotency key")
+		}
+	}
+}
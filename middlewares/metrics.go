@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"strconv"
+	"time"
+
+	"order-service/metrics"
+
+	"github.com/kataras/iris"
+)
+
+// Metrics records the count and latency of every request by route and status
+func Metrics(ctx iris.Context) {
+	start := time.Now()
+
+	ctx.Next()
+
+	route := "unknown"
+	if r := ctx.GetCurrentRoute(); r != nil {
+		route = r.Path()
+	}
+
+	method := ctx.Method()
+	status := strconv.Itoa(ctx.GetStatusCode())
+
+	metrics.HttpRequests.WithLabelValues(method, route, status).Inc()
+	metrics.HttpRequestDuration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+}
@@ -1,41 +1,44 @@
 package middlewares
 
 import (
-	"strconv"
+	"strings"
 
 	"order-service/models"
+	"order-service/problem"
+	"order-service/replica"
 	srvorder "order-service/services"
 
 	"github.com/kataras/iris"
 	"github.com/kataras/iris/context"
 )
 
-func FetchOrder(service srvorder.OrderService) context.Handler {
+// FetchOrder loads the order of the id param. Changes and reads with If-Match load it from the primary
+// database, so an order just placed or taken is never missed by a lagging replica and its version is the one
+// the condition checks, other reads are served by the replicas.
+// The param is the public id of the order, or its sequential id while legacyIds accepts them
+func FetchOrder(service srvorder.OrderService, legacyIds bool) context.Handler {
 	return func(ctx iris.Context) {
 		idStr := ctx.Params().Get("id")
+		reqCtx := ctx.Request().Context()
+		if (ctx.Method() != iris.MethodGet && ctx.Method() != iris.MethodHead) || ctx.GetHeader("If-Match") != "" {
+			reqCtx = replica.WithPrimary(reqCtx)
+		}
 
-		id, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil || id < 0 {
-			ctx.StatusCode(iris.StatusBadRequest)
-			ctx.JSON(iris.Map{
-				"error": "order_id must be an integer",
-			})
+		order, err := srvorder.FindOrder(reqCtx, service, idStr, legacyIds)
+		if err == srvorder.ErrInvalidOrderId {
+			problem.Write(ctx, iris.StatusBadRequest, problem.CodeInvalidParameter, "order_id is not an order id")
 			return
 		}
-
-		order, err := service.GetById(id)
 		if err == models.ErrNotFound {
-			ctx.StatusCode(iris.StatusNotFound)
-			ctx.JSON(iris.Map{
-				"error": "Order not found",
-			})
+			problem.Write(ctx, iris.StatusNotFound, problem.CodeOrderNotFound, "Order not found")
+			return
+		}
+		if srvorder.IsTimeout(err) {
+			problem.Write(ctx, iris.StatusGatewayTimeout, problem.CodeTimeout, "Request timed out")
 			return
 		}
 		if err != nil {
-			ctx.StatusCode(iris.StatusInternalServerError)
-			ctx.JSON(iris.Map{
-				"error": "Failed to find order",
-			})
+			problem.Write(ctx, iris.StatusInternalServerError, problem.CodeInternal, "Failed to find order")
 			return
 		}
 
@@ -43,3 +46,47 @@ func FetchOrder(service srvorder.OrderService) context.Handler {
 		ctx.Next()
 	}
 }
+
+// OrderPreconditions evaluates the If-Match and If-None-Match headers against the version of the order
+// loaded by FetchOrder. A request whose condition fails is answered with 412, or 304 for a read
+func OrderPreconditions(ctx iris.Context) {
+	order := ctx.Values().Get("_order").(*models.Order)
+	etag := order.ETag()
+
+	if header := ctx.GetHeader("If-Match"); header != "" && !matchETag(header, etag, false) {
+		problem.Write(ctx, iris.StatusPreconditionFailed, problem.CodePreconditionFailed, "Order was changed since it was read")
+		return
+	}
+
+	if header := ctx.GetHeader("If-None-Match"); header != "" && matchETag(header, etag, true) {
+		if ctx.Method() == iris.MethodGet || ctx.Method() == iris.MethodHead {
+			ctx.Header("ETag", etag)
+			ctx.StatusCode(iris.StatusNotModified)
+			return
+		}
+
+		problem.Write(ctx, iris.StatusPreconditionFailed, problem.CodePreconditionFailed, "Order already has this version")
+		return
+	}
+
+	ctx.Next()
+}
+
+// matchETag tells if the comma separated entity tags of header match etag, or header is *.
+// If-Match compares tags strongly, so weak tags never match, If-None-Match ignores the weakness
+func matchETag(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,44 +1,39 @@
 package middlewares
 
 import (
+	"order-service/problem"
+	srvorder "order-service/services"
+
 	"github.com/kataras/iris"
 )
 
 func Paginate(ctx iris.Context) {
 	page, err := ctx.URLParamInt("page")
 	if err != nil {
-		ctx.StatusCode(iris.StatusBadRequest)
-		ctx.JSON(iris.Map{
-			"error": "Invalid page provided",
-		})
+		problem.Write(ctx, iris.StatusBadRequest, problem.CodeInvalidParameter, "Invalid page provided")
 		return
 	}
 
 	limit, err := ctx.URLParamInt("limit")
 	if err != nil {
-		ctx.StatusCode(iris.StatusBadRequest)
-		ctx.JSON(iris.Map{
-			"error": "Invalid limit provided",
-		})
+		problem.Write(ctx, iris.StatusBadRequest, problem.CodeInvalidParameter, "Invalid limit provided")
 		return
 	}
 
 	if page < 1 {
-		ctx.StatusCode(iris.StatusBadRequest)
-		ctx.JSON(iris.Map{
-			"error": "limit should be greater or equal to 1",
-		})
+		problem.Write(ctx, iris.StatusBadRequest, problem.CodeInvalidParameter, "page should be greater or equal to 1")
 		return
 	}
 
 	if limit < 0 {
-		ctx.StatusCode(iris.StatusBadRequest)
-		ctx.JSON(iris.Map{
-			"error": "limit should be greater or equal to 0",
-		})
+		problem.Write(ctx, iris.StatusBadRequest, problem.CodeInvalidParameter, "limit should be greater or equal to 0")
 		return
 	}
 
+	if limit > srvorder.MaxPageLimit {
+		limit = srvorder.MaxPageLimit
+	}
+
 	offset := (page - 1) * limit
 
 	ctx.Values().SetImmutable("_page", page)
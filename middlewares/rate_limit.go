@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"strconv"
+
+	"order-service/metrics"
+	"order-service/models"
+	"order-service/problem"
+	srvorder "order-service/services"
+	"order-service/services/ratelimit"
+
+	"github.com/kataras/iris"
+	"github.com/kataras/iris/context"
+)
+
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
+// RateLimit limits the requests each client sends to route. Every request counts against the bucket
+// of its IP, and requests with an api key also against the bucket of that key. It runs before
+// Authenticate, so the key is not verified yet and must not be the only bucket, otherwise a flood
+// sending a new random key with every request would never be limited.
+func RateLimit(limiter srvorder.RateLimiter, route string, limit models.RateLimit) context.Handler {
+	return func(ctx iris.Context) {
+		keys := ratelimit.ClientKeys(ctx.RemoteAddr(), ctx.GetHeader(ApiKeyHeader))
+		tightest := ratelimit.Allow(ctx.Request().Context(), limiter, route, keys, limit)
+		if tightest == nil {
+			ctx.Next()
+			return
+		}
+
+		ctx.Header(RateLimitLimitHeader, strconv.Itoa(tightest.Limit))
+		ctx.Header(RateLimitRemainingHeader, strconv.Itoa(tightest.Remaining))
+		ctx.Header(RateLimitResetHeader, strconv.Itoa(ratelimit.CeilSeconds(tightest.ResetAfter)))
+
+		if !tightest.Allowed {
+			metrics.RateLimitedRequests.WithLabelValues(route).Inc()
+
+			ctx.Header("Retry-After", strconv.Itoa(ratelimit.CeilSeconds(tightest.RetryAfter)))
+			problem.Write(ctx, iris.StatusTooManyRequests, problem.CodeRateLimited, "Too many requests")
+			return
+		}
+
+		ctx.Next()
+	}
+}
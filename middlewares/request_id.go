@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"context"
+
+	"order-service/logger"
+	srvauth "order-service/services/auth"
+
+	"github.com/google/uuid"
+	"github.com/kataras/iris"
+)
+
+const RequestIdHeader = "X-Request-ID"
+
+// RequestId accepts the X-Request-ID provided by the client or generates a new one,
+// stores it in the request context and echoes it back in the response header
+func RequestId(ctx iris.Context) {
+	if ctx.Values().GetString("_request_id") != "" {
+		ctx.Next()
+		return
+	}
+
+	requestId := ctx.GetHeader(RequestIdHeader)
+	if !srvauth.ValidRequestId(requestId) {
+		requestId = uuid.New().String()
+	}
+
+	ctx.Values().Set("_request_id", requestId)
+	ctx.Header(RequestIdHeader, requestId)
+	setRequestContext(ctx, logger.WithRequestId(ctx.Request().Context(), requestId))
+
+	ctx.Next()
+}
+
+// setRequestContext replaces the context of the request in place,
+// since the iris context keeps a reference to the original *http.Request
+func setRequestContext(ctx iris.Context, c context.Context) {
+	r := ctx.Request()
+	*r = *r.WithContext(c)
+}
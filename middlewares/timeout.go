@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"context"
+	"time"
+
+	"github.com/kataras/iris"
+	irisctx "github.com/kataras/iris/context"
+)
+
+// Timeout bounds the request context by d, so downstream database and distance calls
+// are cancelled once the deadline passes or the client disconnects. Routes in exempt serve
+// long-lived responses like event streams, they are only cancelled when the client disconnects.
+func Timeout(d time.Duration, exempt ...string) irisctx.Handler {
+	return func(ctx iris.Context) {
+		if d <= 0 || isExempt(ctx, exempt) {
+			ctx.Next()
+			return
+		}
+
+		c, cancel := context.WithTimeout(ctx.Request().Context(), d)
+		defer cancel()
+
+		setRequestContext(ctx, c)
+
+		ctx.Next()
+	}
+}
+
+func isExempt(ctx iris.Context, exempt []string) bool {
+	r := ctx.GetCurrentRoute()
+	if r == nil {
+		return false
+	}
+
+	for _, path := range exempt {
+		if r.Path() == path {
+			return true
+		}
+	}
+
+	return false
+}
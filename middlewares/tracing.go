@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"fmt"
+
+	"github.com/kataras/iris"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const serverName = "order-service"
+
+var tracer = otel.Tracer("order-service/middlewares")
+
+// Tracing starts a server span for every request, continuing the trace
+// from the incoming traceparent header if there is one
+func Tracing(ctx iris.Context) {
+	r := ctx.Request()
+	parent := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+	route := ""
+	if cr := ctx.GetCurrentRoute(); cr != nil {
+		route = cr.Path()
+	}
+
+	spanName := fmt.Sprintf("HTTP %s %s", ctx.Method(), route)
+	if route == "" {
+		spanName = fmt.Sprintf("HTTP %s", ctx.Method())
+	}
+
+	c, span := tracer.Start(parent, spanName,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(serverName, route, r)...))
+	defer span.End()
+
+	setRequestContext(ctx, c)
+
+	ctx.Next()
+
+	status := ctx.GetStatusCode()
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(status))
+}
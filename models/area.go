@@ -0,0 +1,26 @@
+package models
+
+import "math"
+
+// earthRadius is the mean radius of the earth in meters
+const earthRadius = 6371000
+
+// Area is a circle of Radius meters around Lat, Lng
+type Area struct {
+	Lat    float64
+	Lng    float64
+	Radius float64
+}
+
+// Contains reports whether the location lat, lng lies within the area, by great-circle distance
+func (a Area) Contains(lat, lng float64) bool {
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := rad(lat - a.Lat)
+	dLng := rad(lng - a.Lng)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(a.Lat))*math.Cos(rad(lat))*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2*earthRadius*math.Asin(math.Sqrt(h)) <= a.Radius
+}
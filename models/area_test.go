@@ -0,0 +1,18 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArea_Contains(t *testing.T) {
+	// Central, Hong Kong
+	area := Area{Lat: 22.2819, Lng: 114.1582, Radius: 2000}
+
+	// Admiralty, about 1 km away
+	assert.True(t, area.Contains(22.2793, 114.1652))
+	// Causeway Bay, about 4 km away
+	assert.False(t, area.Contains(22.2802, 114.1838))
+	assert.True(t, area.Contains(area.Lat, area.Lng))
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+var (
+	RoleCustomer = "customer"
+	RoleDriver   = "driver"
+	RoleOps      = "ops"
+	RoleAdmin    = "admin"
+)
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role string) bool {
+	switch role {
+	case RoleCustomer, RoleDriver, RoleOps, RoleAdmin:
+		return true
+	}
+
+	return false
+}
+
+// Principal is the authenticated caller of a request, acting on behalf of a merchant
+type Principal struct {
+	Subject    string
+	Role       string
+	MerchantId string
+}
+
+// ApiKey is an api key issued to a merchant integration, only the sha256 hash of the key is stored
+type ApiKey struct {
+	Id         int64     `json:"id"`
+	Name       string    `json:"name"`
+	MerchantId string    `json:"merchant_id"`
+	KeyHash    string    `json:"-"`
+	Role       string    `json:"role"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -3,6 +3,9 @@ package models
 import "errors"
 
 var (
-	ErrNotFound     = errors.New("not found")
-	ErrCannotUpdate = errors.New("cannot update due to conflict")
+	ErrNotFound      = errors.New("not found")
+	ErrCannotUpdate  = errors.New("cannot update due to conflict")
+	ErrAlreadyExists = errors.New("already exists")
+	ErrNoTenant      = errors.New("no merchant in context")
+	ErrNestedTx      = errors.New("transaction already in progress")
 )
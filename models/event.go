@@ -0,0 +1,67 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// types of the order domain events
+var (
+	EventTypeOrderPlaced = "OrderPlaced"
+	EventTypeOrderTaken  = "OrderTaken"
+)
+
+// Event is a domain event recorded in the outbox in the same transaction as the change it describes.
+// The sequential id of the order and the merchant stay internal, clients know the order by its public id
+type Event struct {
+	Id         int64           `json:"id"`
+	Type       string          `json:"type"`
+	OrderId    int64           `json:"-"`
+	MerchantId string          `json:"-"`
+	Payload    json.RawMessage `json:"payload"`
+	CreatedAt  time.Time       `json:"created_at"`
+
+	// Origins of the order, only loaded for the events of a stream
+	Origins []float64 `json:"-"`
+}
+
+// OrderPublicId is the id the order of the event is known by in the REST API, the id of the payload.
+// It is empty for events written before public ids, which carry the sequential id there
+func (e Event) OrderPublicId() string {
+	var order struct {
+		Id json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(e.Payload, &order); err != nil {
+		return ""
+	}
+
+	var publicId string
+	// fails for the numeric ids of older events
+	json.Unmarshal(order.Id, &publicId)
+	return publicId
+}
+
+// MarshalJSON encodes the event for clients, with the public id of its order as order_id
+func (e Event) MarshalJSON() ([]byte, error) {
+	// event has the fields of Event but not this method
+	type event Event
+	return json.Marshal(struct {
+		event
+		OrderId string `json:"order_id"`
+	}{event(e), e.OrderPublicId()})
+}
+
+// NewOrderEvent returns an event of eventType carrying the current state of order
+func NewOrderEvent(eventType string, order *Order) (*Event, error) {
+	payload, err := json.Marshal(order)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Event{
+		Type:       eventType,
+		OrderId:    order.Id,
+		MerchantId: order.MerchantId,
+		Payload:    payload,
+	}, nil
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvent_MarshalJSON(t *testing.T) {
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	event := Event{Id: 42, Type: EventTypeOrderPlaced, OrderId: 7, MerchantId: "merchant-1", Payload: []byte(`{"id":"01KDZ3J0K8A7Z4W9X2M5N6P7Q8"}`), CreatedAt: createdAt}
+
+	data, err := json.Marshal(&event)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":42,"type":"OrderPlaced","order_id":"01KDZ3J0K8A7Z4W9X2M5N6P7Q8","payload":{"id":"01KDZ3J0K8A7Z4W9X2M5N6P7Q8"},"created_at":"2026-01-02T03:04:05Z"}`, string(data))
+
+	// events written before public ids carry the sequential id, which is not sent as order_id
+	event.Payload = []byte(`{"id":7}`)
+	assert.Empty(t, event.OrderPublicId())
+	data, err = json.Marshal(event)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":42,"type":"OrderPlaced","order_id":"","payload":{"id":7},"created_at":"2026-01-02T03:04:05Z"}`, string(data))
+}
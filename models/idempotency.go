@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// IdempotencyRecord keeps the response of a request sent with an Idempotency-Key,
+// so a retry of the same request can be answered without executing it again
+type IdempotencyRecord struct {
+	Key         string
+	RequestHash string
+	StatusCode  int
+	Response    []byte
+	CreatedAt   time.Time
+}
+
+// Completed reports whether the response of the original request has been stored
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
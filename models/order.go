@@ -1,14 +1,49 @@
 package models
 
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
 var (
 	StatusUnassigned = "UNASSIGNED"
 	StatusTaken      = "TAKEN"
+
+	// terminal statuses, an order does not change anymore once delivered or cancelled
+	StatusDelivered = "DELIVERED"
+	StatusCancelled = "CANCELLED"
 )
 
+// Order is a delivery order. Clients know it by PublicId, a ULID that cannot be guessed, while Id is
+// the sequential key of the database. Version starts at 1 and grows with every update of the order,
+// so it tells apart the states of an order for optimistic concurrency
 type Order struct {
-	Id           int64     `json:"id"`
+	Id           int64     `json:"-"`
+	PublicId     string    `json:"id"`
+	MerchantId   string    `json:"-"`
 	Origins      []float64 `json:"-"`
 	Destinations []float64 `json:"-"`
 	Distance     int       `json:"distance"`
 	Status       string    `json:"status"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Version      int64     `json:"version"`
+}
+
+// MarshalJSON encodes the order, an order placed before public ids which was not given one yet
+// is known by its sequential id until then, like /orders/:id accepts it while legacy ids are on
+func (o Order) MarshalJSON() ([]byte, error) {
+	// order has the fields of Order but not this method
+	type order Order
+	if o.PublicId == "" {
+		o.PublicId = strconv.FormatInt(o.Id, 10)
+	}
+
+	return json.Marshal(order(o))
+}
+
+// ETag is the entity tag of the version of the order, for the conditional requests of clients
+func (o *Order) ETag() string {
+	return `"` + strconv.FormatInt(o.Version, 10) + `"`
 }
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrder_MarshalJSON(t *testing.T) {
+	placedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	order := Order{Id: 42, PublicId: "01KDZ3J0K8A7Z4W9X2M5N6P7Q8", Distance: 1200, Status: StatusUnassigned, CreatedAt: placedAt, UpdatedAt: placedAt, Version: 1}
+
+	data, err := json.Marshal(&order)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":"01KDZ3J0K8A7Z4W9X2M5N6P7Q8","distance":1200,"status":"UNASSIGNED","created_at":"2026-01-02T03:04:05Z","updated_at":"2026-01-02T03:04:05Z","version":1}`, string(data))
+
+	// an order not given a public id yet is known by its sequential id
+	order.PublicId = ""
+	data, err = json.Marshal([]Order{order})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"id":"42","distance":1200,"status":"UNASSIGNED","created_at":"2026-01-02T03:04:05Z","updated_at":"2026-01-02T03:04:05Z","version":1}]`, string(data))
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// RateLimit allows bursts of up to Requests requests, refilled evenly over Per
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Enabled reports whether the limit is configured, a zero limit lets every request through
+func (l RateLimit) Enabled() bool {
+	return l.Requests > 0 && l.Per > 0
+}
+
+// RateLimitResult is the state of a client's bucket after a request was counted
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long a rejected client has to wait for the next request to be allowed
+	RetryAfter time.Duration
+	// ResetAfter is how long it takes until the bucket is full again
+	ResetAfter time.Duration
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// events a webhook can subscribe to, one for every order status change
+var (
+	EventOrderPlaced = "order.placed"
+	EventOrderTaken  = "order.taken"
+)
+
+// ValidEvent reports whether event is one of the known events
+func ValidEvent(event string) bool {
+	switch event {
+	case EventOrderPlaced, EventOrderTaken:
+		return true
+	}
+
+	return false
+}
+
+// delivery states, a delivery is retried while pending and dead once it ran out of attempts
+var (
+	DeliveryPending   = "PENDING"
+	DeliverySucceeded = "SUCCEEDED"
+	DeliveryDead      = "DEAD"
+)
+
+// Webhook is a merchant's subscription to events, deliveries are signed with Secret
+type Webhook struct {
+	Id         int64     `json:"id"`
+	MerchantId string    `json:"-"`
+	Url        string    `json:"url"`
+	Events     []string  `json:"events"`
+	Secret     string    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Subscribed reports whether the webhook subscribed to event
+func (w *Webhook) Subscribed(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WebhookDelivery is one event queued for a webhook, together with the outcome of its last attempt
+type WebhookDelivery struct {
+	Id             int64           `json:"id"`
+	WebhookId      int64           `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+
+	// EventId is the outbox event the delivery was queued for, a webhook gets one delivery
+	// per event however often the event is relayed
+	EventId int64 `json:"-"`
+
+	// Webhook is the webhook the delivery is sent to, only loaded for due deliveries
+	Webhook *Webhook `json:"-"`
+}
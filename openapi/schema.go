@@ -0,0 +1,200 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON schema, as far as OpenAPI 3.0 supports it
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []interface{}      `json:"enum,omitempty"`
+	MinItems    *int               `json:"minItems,omitempty"`
+	MaxItems    *int               `json:"maxItems,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	MaxLength   *int               `json:"maxLength,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+
+	AdditionalProperties *Schema `json:"additionalProperties,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// Schema returns a reference to the schema of the type of v, which is added to the components.
+// The schema follows the json tags of the type, and the validate tags of the validator package
+// for required fields and the len, min, max, eq and oneof rules.
+func (d *Document) Schema(v interface{}) *Schema {
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		// any JSON value
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Struct:
+		return d.structSchema(t)
+	default:
+		return &Schema{}
+	}
+}
+
+// structSchema adds the schema of the struct t to the components, named after the type
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	ref := &Schema{Ref: "#/components/schemas/" + t.Name()}
+	if _, ok := d.Components.Schemas[t.Name()]; ok {
+		return ref
+	}
+
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	// added before the fields, so recursive types refer to themselves
+	d.Components.Schemas[t.Name()] = schema
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := jsonName(field)
+		if name == "-" {
+			continue
+		}
+
+		property := d.schemaOf(field.Type)
+		if applyRules(property, field.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+
+	return ref
+}
+
+func jsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
+
+// applyRules narrows schema by the validate rules of its field and reports whether the field is required.
+// The rules after dive apply to the items of an array.
+func applyRules(schema *Schema, tag string) bool {
+	required := false
+
+	for i, rule := range strings.Split(tag, ",") {
+		name, param := rule, ""
+		if j := strings.Index(rule, "="); j >= 0 {
+			name, param = rule[:j], rule[j+1:]
+		}
+
+		switch name {
+		case "required":
+			required = true
+		case "dive":
+			if schema.Items != nil {
+				applyRules(schema.Items, strings.Join(strings.Split(tag, ",")[i+1:], ","))
+			}
+			return required
+		case "len":
+			setLimit(schema, param, true, true)
+		case "min":
+			setLimit(schema, param, true, false)
+		case "max":
+			setLimit(schema, param, false, true)
+		case "eq":
+			schema.Enum = []interface{}{param}
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, value)
+			}
+		case "url":
+			schema.Format = "uri"
+		case "location":
+			// the custom rule of the handlers package
+			schema.Description = "Latitude and longitude in decimal degrees, e.g. [\"22.3193\", \"114.1694\"]"
+		case "latitude":
+			setLimit(schema, "-90", true, false)
+			setLimit(schema, "90", false, true)
+		case "longitude":
+			setLimit(schema, "-180", true, false)
+			setLimit(schema, "180", false, true)
+		}
+	}
+
+	return required
+}
+
+// setLimit sets the lower and/or upper limit of schema, on its items, length or value depending on its type
+func setLimit(schema *Schema, param string, lower, upper bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	i := int(n)
+
+	switch schema.Type {
+	case "array":
+		if lower {
+			schema.MinItems = &i
+		}
+		if upper {
+			schema.MaxItems = &i
+		}
+	case "string":
+		if lower {
+			schema.MinLength = &i
+		}
+		if upper {
+			schema.MaxLength = &i
+		}
+	default:
+		if lower {
+			schema.Minimum = &n
+		}
+		if upper {
+			schema.Maximum = &n
+		}
+	}
+}
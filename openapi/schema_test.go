@@ -0,0 +1,63 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type address struct {
+	Street string `json:"street" validate:"required,max=100"`
+}
+
+type customer struct {
+	Id        int64           `json:"id"`
+	Name      string          `json:"name" validate:"required,min=1,max=255"`
+	Role      string          `json:"role" validate:"required,oneof=customer admin"`
+	Tags      []string        `json:"tags,omitempty" validate:"min=1,dive,oneof=a b"`
+	Location  []string        `json:"location" validate:"required,len=2"`
+	Address   *address        `json:"address"`
+	Extra     json.RawMessage `json:"extra"`
+	CreatedAt time.Time       `json:"created_at"`
+	Secret    string          `json:"-"`
+	internal  string
+}
+
+func TestSchema(t *testing.T) {
+	doc := New(Info{Title: "test", Version: "1"})
+
+	ref := doc.Schema(customer{})
+	assert.Equal(t, "#/components/schemas/customer", ref.Ref)
+
+	schema := doc.Components.Schemas["customer"]
+	if !assert.NotNil(t, schema) {
+		return
+	}
+
+	assert.Equal(t, []string{"name", "role", "location"}, schema.Required)
+	assert.NotContains(t, schema.Properties, "Secret")
+	assert.NotContains(t, schema.Properties, "-")
+	assert.NotContains(t, schema.Properties, "internal")
+
+	assert.Equal(t, &Schema{Type: "integer", Format: "int64"}, schema.Properties["id"])
+	assert.Equal(t, 1, *schema.Properties["name"].MinLength)
+	assert.Equal(t, 255, *schema.Properties["name"].MaxLength)
+	assert.Equal(t, []interface{}{"customer", "admin"}, schema.Properties["role"].Enum)
+	assert.Equal(t, 1, *schema.Properties["tags"].MinItems)
+	assert.Equal(t, []interface{}{"a", "b"}, schema.Properties["tags"].Items.Enum)
+	assert.Equal(t, 2, *schema.Properties["location"].MinItems)
+	assert.Equal(t, 2, *schema.Properties["location"].MaxItems)
+	assert.Equal(t, "#/components/schemas/address", schema.Properties["address"].Ref)
+	assert.Equal(t, &Schema{}, schema.Properties["extra"])
+	assert.Equal(t, "date-time", schema.Properties["created_at"].Format)
+
+	assert.Equal(t, []string{"street"}, doc.Components.Schemas["address"].Required)
+}
+
+func TestPath(t *testing.T) {
+	assert.Equal(t, "/orders", Path("/orders"))
+	assert.Equal(t, "/orders/{id}", Path("/orders/:id"))
+	assert.Equal(t, "/webhooks/{id}/deliveries", Path("/webhooks/:id{uint64}/deliveries"))
+}
@@ -0,0 +1,93 @@
+// Package problem writes error responses as RFC 7807 problem details. Every problem carries
+// a stable code clients can switch on, the detail and the title are for humans and may change.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"order-service/logger"
+
+	"github.com/kataras/iris"
+)
+
+// ContentType is the media type of problem responses
+const ContentType = "application/problem+json"
+
+// codes of the problems, they are part of the API and must not change
+const (
+	CodeInvalidJSON             = "invalid_json"
+	CodeValidationFailed        = "validation_failed"
+	CodeInvalidParameter        = "invalid_parameter"
+	CodeUnauthenticated         = "unauthenticated"
+	CodePermissionDenied        = "permission_denied"
+	CodeNotFound                = "not_found"
+	CodeOrderNotFound           = "order_not_found"
+	CodeWebhookNotFound         = "webhook_not_found"
+	CodeOrderAlreadyTaken       = "order_already_taken"
+	CodePreconditionFailed      = "precondition_failed"
+	CodeCannotCalculateDistance = "cannot_calculate_distance"
+	CodeIdempotencyKeyReused    = "idempotency_key_reused"
+	CodeRequestInProgress       = "request_in_progress"
+	CodeRateLimited             = "rate_limited"
+	CodeStreamingUnsupported    = "streaming_unsupported"
+	CodeTimeout                 = "timeout"
+	CodeInternal                = "internal_error"
+)
+
+// Problem is the body of error responses. Type is always "about:blank", so Title is the
+// HTTP status text and Code tells problems with the same status apart
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestId string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes why a field of the request was rejected, Field is the json path of the field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// New returns the problem of status identified by code
+func New(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Write responds to ctx with a problem of status identified by code
+func Write(ctx iris.Context, status int, code, detail string) {
+	Send(ctx, New(status, code, detail))
+}
+
+// Send responds to ctx with p, the instance and the request id are filled in from the request
+func Send(ctx iris.Context, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = ctx.Path()
+	}
+	if p.RequestId == "" {
+		p.RequestId = logger.RequestId(ctx.Request().Context())
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		// a problem only holds strings and ints, this never happens
+		ctx.StatusCode(p.Status)
+		return
+	}
+
+	ctx.ContentType(ContentType)
+	ctx.StatusCode(p.Status)
+	ctx.Write(body)
+}
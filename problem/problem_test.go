@@ -0,0 +1,42 @@
+package problem
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+func TestValidation(t *testing.T) {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	})
+
+	type req struct {
+		Url    string   `json:"url" validate:"required,url"`
+		Events []string `json:"events" validate:"required,min=1,dive,oneof=order.placed order.taken"`
+		Secret string   `json:"secret" validate:"min=16"`
+	}
+
+	p := Validation(validate.Struct(req{Url: "not a url", Events: []string{"order.placed", "order.lost"}, Secret: "short"}))
+
+	assert.Equal(t, 400, p.Status)
+	assert.Equal(t, "Bad Request", p.Title)
+	assert.Equal(t, CodeValidationFailed, p.Code)
+	assert.Equal(t, []FieldError{
+		{Field: "url", Code: "url", Message: "must be a valid URL"},
+		{Field: "events[1]", Code: "oneof", Message: "must be one of order.placed, order.taken"},
+		{Field: "secret", Code: "min", Message: "must have at least 16 characters"},
+	}, p.Errors)
+}
+
+func TestValidation_OtherError(t *testing.T) {
+	p := Validation(errors.New("boom"))
+
+	assert.Equal(t, CodeValidationFailed, p.Code)
+	assert.Empty(t, p.Errors)
+}
@@ -0,0 +1,83 @@
+package problem
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// Validation returns the problem of a request body rejected by a validator, each failed rule
+// becomes a field error whose code is the name of the rule, e.g. "required" or "oneof"
+func Validation(err error) *Problem {
+	errs, _ := err.(validator.ValidationErrors)
+
+	fields := make([]FieldError, 0, len(errs))
+	for _, e := range errs {
+		fields = append(fields, FieldError{
+			Field:   fieldPath(e),
+			Code:    e.Tag(),
+			Message: message(e),
+		})
+	}
+
+	return Invalid(fields...)
+}
+
+// Invalid returns the problem of a request body with the invalid fields errs
+func Invalid(errs ...FieldError) *Problem {
+	p := New(http.StatusBadRequest, CodeValidationFailed, "The request body is invalid")
+	p.Errors = errs
+
+	return p
+}
+
+// fieldPath drops the name of the validated struct from the namespace of e, with field names
+// registered from json tags it is the json path of the field, e.g. "events[0]"
+func fieldPath(e validator.FieldError) string {
+	ns := e.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+
+	return ns
+}
+
+func message(e validator.FieldError) string {
+	switch e.Tag() {
+	case "required":
+		return "is required"
+	case "len":
+		return "must have exactly " + e.Param() + " " + unit(e)
+	case "min":
+		return "must have at least " + e.Param() + " " + unit(e)
+	case "max":
+		return "must have at most " + e.Param() + " " + unit(e)
+	case "eq":
+		return "must be " + e.Param()
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(e.Param()), ", ")
+	case "url":
+		return "must be a valid URL"
+	case "latitude":
+		return "must be a latitude between -90 and 90"
+	case "longitude":
+		return "must be a longitude between -180 and 180"
+	case "location":
+		return "must be a latitude and a longitude between -90 and 90, and -180 and 180"
+	default:
+		return fmt.Sprintf("failed the %s rule", e.Tag())
+	}
+}
+
+// unit names what the length rules of e count
+func unit(e validator.FieldError) string {
+	switch e.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return "items"
+	default:
+		return "characters"
+	}
+}
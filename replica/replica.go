@@ -0,0 +1,19 @@
+package replica
+
+import "context"
+
+type ctxKey int
+
+const primaryKey ctxKey = iota
+
+// WithPrimary returns a copy of ctx whose reads are served by the primary database instead of a replica,
+// so they see every write committed before them
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey, true)
+}
+
+// Primary reports whether the reads of ctx must be served by the primary database
+func Primary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey).(bool)
+	return primary
+}
@@ -0,0 +1,13 @@
+package replica
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrimary(t *testing.T) {
+	assert.True(t, Primary(WithPrimary(context.Background())))
+	assert.False(t, Primary(context.Background()))
+}
@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"order-service/logger"
+	"order-service/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+type ApiKeyRepo struct {
+	Conn    *sql.DB
+	Timeout time.Duration
+}
+
+// NewMysqlApiKeyRepo will create an ApiKeyRepo on conn, every query is cancelled after timeout unless timeout is zero
+func NewMysqlApiKeyRepo(conn *sql.DB, timeout time.Duration) *ApiKeyRepo {
+	return &ApiKeyRepo{conn, timeout}
+}
+
+// NewSqliteApiKeyRepo will create an ApiKeyRepo on a SQLite conn, the queries are the same as on mysql
+func NewSqliteApiKeyRepo(conn *sql.DB, timeout time.Duration) *ApiKeyRepo {
+	return &ApiKeyRepo{conn, timeout}
+}
+
+func (rp *ApiKeyRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if rp.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, rp.Timeout)
+}
+
+// GetByHash returns the api key with the given hash, revoked keys are never returned
+func (rp *ApiKeyRepo) GetByHash(ctx context.Context, keyHash string) (*models.ApiKey, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/api_key", "method": "GetByHash"})
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT id, name, merchant_id, key_hash, role, created_at FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL"
+
+	apiKey := &models.ApiKey{}
+	err := retryRead(ctx, func() error {
+		return rp.Conn.QueryRowContext(ctx, query, keyHash).Scan(
+			&apiKey.Id,
+			&apiKey.Name,
+			&apiKey.MerchantId,
+			&apiKey.KeyHash,
+			&apiKey.Role,
+			&apiKey.CreatedAt)
+	})
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to query api key")
+		return nil, err
+	}
+
+	return apiKey, nil
+}
+
+func (rp *ApiKeyRepo) Create(ctx context.Context, apiKey *models.ApiKey) (*models.ApiKey, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/api_key", "method": "Create", "name": apiKey.Name})
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	query := "INSERT INTO api_keys (name, merchant_id, key_hash, role) VALUES (?, ?, ?, ?)"
+
+	result, err := rp.Conn.ExecContext(ctx, query, apiKey.Name, apiKey.MerchantId, apiKey.KeyHash, apiKey.Role)
+	if err != nil {
+		log.WithError(err).Error("Failed to insert api key")
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.WithError(err).Error("Failed to get inserted id")
+		return nil, err
+	}
+
+	apiKey.Id = id
+	apiKey.CreatedAt = time.Now()
+	return apiKey, nil
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"order-service/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiKeyRepo_GetByHash(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	query := "SELECT id, name, merchant_id, key_hash, role, created_at FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL"
+
+	mock.ExpectQuery(query).
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "merchant_id", "key_hash", "role", "created_at"}).
+			AddRow(1, "merchant", "merchant-1", "hash", "customer", time.Now()))
+
+	mock.ExpectQuery(query).
+		WithArgs("revoked").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "merchant_id", "key_hash", "role", "created_at"}))
+
+	repo := NewMysqlApiKeyRepo(db, time.Second)
+
+	apiKey, err := repo.GetByHash(context.Background(), "hash")
+	assert.NoError(t, err)
+	assert.Equal(t, "customer", apiKey.Role)
+	assert.Equal(t, "merchant-1", apiKey.MerchantId)
+
+	apiKey, err = repo.GetByHash(context.Background(), "revoked")
+	assert.Equal(t, models.ErrNotFound, err)
+	assert.Nil(t, apiKey)
+}
+
+func TestApiKeyRepo_Create(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	query := "INSERT INTO api_keys (name, merchant_id, key_hash, role) VALUES (?, ?, ?, ?)"
+
+	mock.ExpectExec(query).
+		WithArgs("merchant", "merchant-1", "hash", "customer").
+		WillReturnResult(sqlmock.NewResult(7, 1))
+
+	repo := NewMysqlApiKeyRepo(db, time.Second)
+
+	apiKey, err := repo.Create(context.Background(), &models.ApiKey{Name: "merchant", MerchantId: "merchant-1", KeyHash: "hash", Role: "customer"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), apiKey.Id)
+}
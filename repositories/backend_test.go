@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// backend is a database the order and outbox repositories support, their tests run against each
+type backend struct {
+	name       string
+	orderRepo  func(conn *sql.DB, timeout time.Duration) *OrderRepo
+	outboxRepo func(conn *sql.DB, timeout time.Duration) *OutboxRepo
+	postgres   bool
+}
+
+var backends = []backend{
+	{name: "mysql", orderRepo: NewMysqlOrderRepo, outboxRepo: NewMysqlOutboxRepo},
+	{name: "postgres", orderRepo: NewPostgresOrderRepo, outboxRepo: NewPostgresOutboxRepo, postgres: true},
+	// sqlite runs the queries of mysql
+	{name: "sqlite", orderRepo: NewSqliteOrderRepo, outboxRepo: NewSqliteOutboxRepo},
+}
+
+// q returns the query the backend is expected to run
+func (b backend) q(mysql, postgres string) string {
+	if b.postgres {
+		return postgres
+	}
+
+	return mysql
+}
+
+func forEachBackend(t *testing.T, test func(t *testing.T, b backend)) {
+	for _, b := range backends {
+		b := b
+		t.Run(b.name, func(t *testing.T) {
+			test(t, b)
+		})
+	}
+}
@@ -0,0 +1,690 @@
+package repositories
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// orderExpecter is told the outcome of each call of the contract before it is made, so repositories
+// on sqlmock can expect the queries and answer with the rows a database would return
+type orderExpecter interface {
+	Create(merchantId string, order *models.Order)
+	GetById(merchantId string, id int64, found *models.Order)
+	GetByPublicId(merchantId string, publicId string, found *models.Order)
+	Update(merchantId string, id int64, status, withStatus string, updated bool)
+	Delete(merchantId string, id int64, deleted bool)
+	List(merchantId string, offset, limit int, orders []models.Order)
+}
+
+// noExpecter is the orderExpecter of repositories storing the orders for real
+type noExpecter struct{}
+
+func (noExpecter) Create(string, *models.Order)                {}
+func (noExpecter) GetById(string, int64, *models.Order)        {}
+func (noExpecter) GetByPublicId(string, string, *models.Order) {}
+func (noExpecter) Update(string, int64, string, string, bool)  {}
+func (noExpecter) Delete(string, int64, bool)                  {}
+func (noExpecter) List(string, int, int, []models.Order)       {}
+
+// orderStore is an OrderRepository under contract
+type orderStore struct {
+	repo   OrderRepository
+	expect orderExpecter
+	// concurrent stores are raced by concurrent takes, sqlmock expects queries in order
+	concurrent bool
+	// transactional stores run the transactions of the contract, sqlmock has its own tests of WithTx
+	transactional bool
+	// versioned stores keep the versions of the contract, sqlmock has its own tests of the versioned update
+	versioned bool
+	// events lists the events recorded by the writes of the store, the sqlmock expecters expect them instead
+	events func(t *testing.T) []models.Event
+}
+
+// orderEvents returns the types of the events recorded for the order id, or nil if the store cannot list them
+func (s orderStore) orderEvents(t *testing.T, id int64) []string {
+	if s.events == nil {
+		return nil
+	}
+
+	types := make([]string, 0)
+	for _, event := range s.events(t) {
+		if event.OrderId == id {
+			types = append(types, event.Type)
+		}
+	}
+	return types
+}
+
+// sqlmockOrderExpecter expects the queries of the OrderRepo of backend b
+type sqlmockOrderExpecter struct {
+	b      backend
+	mock   sqlmock.Sqlmock
+	lastId int64
+
+	prepared map[string]*sqlmock.ExpectedPrepare
+}
+
+// prepare expects query to be prepared on its first use only, since the repository caches its statements
+func (e *sqlmockOrderExpecter) prepare(query string) *sqlmock.ExpectedPrepare {
+	if prep, ok := e.prepared[query]; ok {
+		return prep
+	}
+
+	if e.prepared == nil {
+		e.prepared = make(map[string]*sqlmock.ExpectedPrepare)
+	}
+	e.prepared[query] = e.mock.ExpectPrepare(query)
+
+	return e.prepared[query]
+}
+
+func (e *sqlmockOrderExpecter) Create(merchantId string, order *models.Order) {
+	e.lastId++
+	args := []driver.Value{sqlmock.AnyArg(), merchantId, order.Origins[0], order.Origins[1], order.Destinations[0], order.Destinations[1], order.Distance, order.Status, sqlmock.AnyArg(), sqlmock.AnyArg()}
+
+	if e.b.postgres {
+		prep := e.prepare("INSERT INTO orders (public_id, merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, distance, status, created_at, updated_at, version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 1) RETURNING id")
+		e.mock.ExpectBegin()
+		prep.ExpectQuery().
+			WithArgs(args...).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(e.lastId))
+	} else {
+		prep := e.prepare("INSERT INTO orders (public_id, merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, distance, status, created_at, updated_at, version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)")
+		e.mock.ExpectBegin()
+		prep.ExpectExec().
+			WithArgs(args...).
+			WillReturnResult(sqlmock.NewResult(e.lastId, 1))
+	}
+	e.expectEvent(models.EventTypeOrderPlaced, e.lastId, merchantId)
+	e.mock.ExpectCommit()
+}
+
+func (e *sqlmockOrderExpecter) expectEvent(eventType string, id int64, merchantId string) {
+	e.mock.ExpectExec(e.b.q(
+		"INSERT INTO outbox (event_type, order_id, merchant_id, payload) VALUES (?, ?, ?, ?)",
+		"INSERT INTO outbox (event_type, order_id, merchant_id, payload) VALUES ($1, $2, $3, $4)")).
+		WithArgs(eventType, id, merchantId, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func orderRows(orders ...models.Order) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "public_id", "merchant_id", "distance", "status", "created_at", "updated_at", "version"})
+	for _, o := range orders {
+		rows.AddRow(o.Id, o.PublicId, o.MerchantId, o.Distance, o.Status, o.CreatedAt, o.UpdatedAt, o.Version)
+	}
+
+	return rows
+}
+
+func (e *sqlmockOrderExpecter) GetById(merchantId string, id int64, found *models.Order) {
+	rows := orderRows()
+	if found != nil {
+		rows = orderRows(*found)
+	}
+
+	e.mock.ExpectQuery(e.b.q(
+		"SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL",
+		"SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE id = $1 AND merchant_id = $2 AND deleted_at IS NULL")).
+		WithArgs(id, merchantId).
+		WillReturnRows(rows)
+}
+
+func (e *sqlmockOrderExpecter) GetByPublicId(merchantId string, publicId string, found *models.Order) {
+	rows := orderRows()
+	if found != nil {
+		rows = orderRows(*found)
+	}
+
+	e.mock.ExpectQuery(e.b.q(
+		"SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE public_id = ? AND merchant_id = ? AND deleted_at IS NULL",
+		"SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE public_id = $1 AND merchant_id = $2 AND deleted_at IS NULL")).
+		WithArgs(publicId, merchantId).
+		WillReturnRows(rows)
+}
+
+func (e *sqlmockOrderExpecter) Update(merchantId string, id int64, status, withStatus string, updated bool) {
+	var rowsAffected int64
+	if updated {
+		rowsAffected = 1
+	}
+
+	prep := e.prepare(e.b.q(
+		"UPDATE orders SET status = ?, updated_at = ?, version = version + 1 where id = ? AND merchant_id = ? AND status = ? AND deleted_at IS NULL",
+		"UPDATE orders SET status = $1, updated_at = $2, version = version + 1 where id = $3 AND merchant_id = $4 AND status = $5 AND deleted_at IS NULL"))
+	e.mock.ExpectBegin()
+	prep.ExpectExec().
+		WithArgs(status, sqlmock.AnyArg(), id, merchantId, withStatus).
+		WillReturnResult(sqlmock.NewResult(0, rowsAffected))
+
+	if !updated {
+		e.mock.ExpectRollback()
+		return
+	}
+
+	if eventType, ok := statusEvents[status]; ok {
+		e.expectEvent(eventType, id, merchantId)
+	}
+	e.mock.ExpectCommit()
+}
+
+func (e *sqlmockOrderExpecter) Delete(merchantId string, id int64, deleted bool) {
+	var rowsAffected int64
+	if deleted {
+		rowsAffected = 1
+	}
+
+	e.prepare(e.b.q(
+		"UPDATE orders SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL",
+		"UPDATE orders SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND merchant_id = $2 AND deleted_at IS NULL")).
+		ExpectExec().
+		WithArgs(id, merchantId).
+		WillReturnResult(sqlmock.NewResult(0, rowsAffected))
+}
+
+func (e *sqlmockOrderExpecter) List(merchantId string, offset, limit int, orders []models.Order) {
+	if e.b.postgres {
+		e.mock.ExpectQuery("SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE merchant_id = $1 AND deleted_at IS NULL ORDER BY id ASC LIMIT $2 OFFSET $3").
+			WithArgs(merchantId, limit, offset).
+			WillReturnRows(orderRows(orders...))
+		return
+	}
+
+	e.mock.ExpectQuery("SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE merchant_id = ? AND deleted_at IS NULL ORDER BY id ASC LIMIT ?, ?").
+		WithArgs(merchantId, offset, limit).
+		WillReturnRows(orderRows(orders...))
+}
+
+// namedOrderStore creates a new OrderRepository implementation for each test of the contract
+type namedOrderStore struct {
+	name     string
+	newStore func(t *testing.T) orderStore
+}
+
+// orderStores returns every OrderRepository implementation
+func orderStores() []namedOrderStore {
+	stores := []namedOrderStore{
+		{"memory", func(t *testing.T) orderStore {
+			rp := NewMemoryOrderRepo()
+			events := func(*testing.T) []models.Event { return rp.Events() }
+			return orderStore{repo: rp, expect: noExpecter{}, concurrent: true, transactional: true, versioned: true, events: events}
+		}},
+		{"sqlite file", func(t *testing.T) orderStore {
+			db := openSqlite(t)
+			events := func(t *testing.T) []models.Event {
+				events, err := NewSqliteOutboxRepo(db, time.Second).ListPending(context.Background(), 100)
+				assert.NoError(t, err)
+				return events
+			}
+			return orderStore{repo: NewSqliteOrderRepo(db, time.Second), expect: noExpecter{}, concurrent: true, transactional: true, versioned: true, events: events}
+		}},
+	}
+
+	for _, b := range backends {
+		b := b
+		stores = append(stores, namedOrderStore{b.name + " sqlmock", func(t *testing.T) orderStore {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				assert.NoError(t, mock.ExpectationsWereMet())
+				db.Close()
+			})
+
+			return orderStore{repo: b.orderRepo(db, time.Second), expect: &sqlmockOrderExpecter{b: b, mock: mock}}
+		}})
+	}
+
+	return stores
+}
+
+func newContractOrder(distance int) *models.Order {
+	return &models.Order{
+		Origins:      []float64{22.3193, 114.1694},
+		Destinations: []float64{22.2783, 114.1747},
+		Distance:     distance,
+		Status:       models.StatusUnassigned,
+	}
+}
+
+// create places an order of distance for merchantId, as the contract expects it to be stored
+func (s orderStore) create(t *testing.T, merchantId string, distance int) models.Order {
+	t.Helper()
+
+	order := newContractOrder(distance)
+	s.expect.Create(merchantId, order)
+
+	created, err := s.repo.Create(tenantCtx(merchantId), order)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, merchantId, created.MerchantId)
+	assert.NotZero(t, created.Id)
+	_, err = ulid.ParseStrict(created.PublicId)
+	assert.NoError(t, err, "public ids are ULIDs")
+
+	return models.Order{Id: created.Id, PublicId: created.PublicId, MerchantId: merchantId, Distance: distance, Status: models.StatusUnassigned}
+}
+
+// TestOrderRepository_Contract is the behaviour every OrderRepository must have
+func TestOrderRepository_Contract(t *testing.T) {
+	for _, store := range orderStores() {
+		newStore := store.newStore
+		t.Run(store.name, func(t *testing.T) {
+			t.Run("create assigns distinct ids", func(t *testing.T) {
+				s := newStore(t)
+
+				first := s.create(t, "merchant-1", 100)
+				second := s.create(t, "merchant-1", 200)
+				assert.True(t, second.Id > first.Id)
+				assert.NotEqual(t, first.PublicId, second.PublicId)
+			})
+
+			t.Run("get is scoped to the merchant", func(t *testing.T) {
+				s := newStore(t)
+				created := s.create(t, "merchant-1", 100)
+
+				s.expect.GetById("merchant-1", created.Id, &created)
+				order, err := s.repo.GetById(tenantCtx("merchant-1"), created.Id)
+				assert.NoError(t, err)
+				if assert.NotNil(t, order) {
+					assert.Equal(t, created.Id, order.Id)
+					assert.Equal(t, "merchant-1", order.MerchantId)
+					assert.Equal(t, 100, order.Distance)
+					assert.Equal(t, models.StatusUnassigned, order.Status)
+				}
+
+				s.expect.GetById("merchant-2", created.Id, nil)
+				order, err = s.repo.GetById(tenantCtx("merchant-2"), created.Id)
+				assert.Equal(t, models.ErrNotFound, err)
+				assert.Nil(t, order)
+
+				s.expect.GetById("merchant-1", created.Id+100, nil)
+				_, err = s.repo.GetById(tenantCtx("merchant-1"), created.Id+100)
+				assert.Equal(t, models.ErrNotFound, err)
+			})
+
+			t.Run("get by public id is scoped to the merchant", func(t *testing.T) {
+				s := newStore(t)
+				created := s.create(t, "merchant-1", 100)
+
+				s.expect.GetByPublicId("merchant-1", created.PublicId, &created)
+				order, err := s.repo.GetByPublicId(tenantCtx("merchant-1"), created.PublicId)
+				assert.NoError(t, err)
+				if assert.NotNil(t, order) {
+					assert.Equal(t, created.Id, order.Id)
+					assert.Equal(t, created.PublicId, order.PublicId)
+					assert.Equal(t, "merchant-1", order.MerchantId)
+				}
+
+				s.expect.GetByPublicId("merchant-2", created.PublicId, nil)
+				order, err = s.repo.GetByPublicId(tenantCtx("merchant-2"), created.PublicId)
+				assert.Equal(t, models.ErrNotFound, err)
+				assert.Nil(t, order)
+
+				s.expect.GetByPublicId("merchant-1", "01ARZ3NDEKTSV4RRFFQ69G5FAV", nil)
+				_, err = s.repo.GetByPublicId(tenantCtx("merchant-1"), "01ARZ3NDEKTSV4RRFFQ69G5FAV")
+				assert.Equal(t, models.ErrNotFound, err)
+			})
+
+			t.Run("update compares and sets the status", func(t *testing.T) {
+				s := newStore(t)
+				created := s.create(t, "merchant-1", 100)
+				take := func(merchantId, withStatus string) (*models.Order, error) {
+					return s.repo.Update(tenantCtx(merchantId), &models.Order{Id: created.Id, Status: models.StatusTaken}, withStatus)
+				}
+
+				s.expect.Update("merchant-2", created.Id, models.StatusTaken, models.StatusUnassigned, false)
+				order, err := take("merchant-2", models.StatusUnassigned)
+				assert.Equal(t, models.ErrCannotUpdate, err)
+				assert.Nil(t, order)
+
+				s.expect.Update("merchant-1", created.Id, models.StatusTaken, models.StatusTaken, false)
+				_, err = take("merchant-1", models.StatusTaken)
+				assert.Equal(t, models.ErrCannotUpdate, err)
+
+				s.expect.Update("merchant-1", created.Id, models.StatusTaken, models.StatusUnassigned, true)
+				order, err = take("merchant-1", models.StatusUnassigned)
+				assert.NoError(t, err)
+				if assert.NotNil(t, order) {
+					assert.Equal(t, created.Id, order.Id)
+					assert.Equal(t, "merchant-1", order.MerchantId)
+					assert.Equal(t, models.StatusTaken, order.Status)
+				}
+
+				s.expect.Update("merchant-1", created.Id, models.StatusTaken, models.StatusUnassigned, false)
+				_, err = take("merchant-1", models.StatusUnassigned)
+				assert.Equal(t, models.ErrCannotUpdate, err)
+
+				taken := created
+				taken.Status = models.StatusTaken
+				s.expect.GetById("merchant-1", created.Id, &taken)
+				order, err = s.repo.GetById(tenantCtx("merchant-1"), created.Id)
+				assert.NoError(t, err)
+				assert.Equal(t, models.StatusTaken, order.Status)
+
+				s.expect.Update("merchant-1", created.Id+100, models.StatusTaken, models.StatusUnassigned, false)
+				_, err = s.repo.Update(tenantCtx("merchant-1"), &models.Order{Id: created.Id + 100, Status: models.StatusTaken}, models.StatusUnassigned)
+				assert.Equal(t, models.ErrCannotUpdate, err)
+			})
+
+			t.Run("updates count versions", func(t *testing.T) {
+				s := newStore(t)
+				if !s.versioned {
+					t.Skip("versions are not stored")
+				}
+				ctx := tenantCtx("merchant-1")
+
+				created, err := s.repo.Create(ctx, newContractOrder(100))
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				assert.Equal(t, int64(1), created.Version)
+				assert.False(t, created.CreatedAt.IsZero())
+				assert.True(t, created.UpdatedAt.Equal(created.CreatedAt))
+
+				order, err := s.repo.GetById(ctx, created.Id)
+				assert.NoError(t, err)
+				assert.Equal(t, int64(1), order.Version)
+				assert.True(t, order.CreatedAt.Equal(created.CreatedAt))
+				assert.True(t, order.UpdatedAt.Equal(created.UpdatedAt))
+
+				order.Status = models.StatusTaken
+				order, err = s.repo.Update(ctx, order, models.StatusUnassigned)
+				assert.NoError(t, err)
+				assert.Equal(t, int64(2), order.Version)
+				assert.False(t, order.UpdatedAt.Before(created.CreatedAt))
+
+				// an update of the order read at version 1 is rejected, even if the status still matches
+				stale := &models.Order{Id: created.Id, Status: models.StatusTaken, Version: 1}
+				_, err = s.repo.Update(ctx, stale, models.StatusTaken)
+				assert.Equal(t, models.ErrCannotUpdate, err)
+
+				order, err = s.repo.Update(ctx, &models.Order{Id: created.Id, Status: models.StatusTaken, Version: 2}, models.StatusTaken)
+				assert.NoError(t, err)
+				assert.Equal(t, int64(3), order.Version)
+
+				order, err = s.repo.GetById(ctx, created.Id)
+				assert.NoError(t, err)
+				assert.Equal(t, int64(3), order.Version)
+				assert.True(t, order.CreatedAt.Equal(created.CreatedAt))
+			})
+
+			t.Run("delete is scoped to the merchant", func(t *testing.T) {
+				s := newStore(t)
+				created := s.create(t, "merchant-1", 100)
+
+				s.expect.Delete("merchant-2", created.Id, false)
+				deleted, err := s.repo.Delete(tenantCtx("merchant-2"), created.Id)
+				assert.NoError(t, err)
+				assert.False(t, deleted)
+
+				s.expect.Delete("merchant-1", created.Id, true)
+				deleted, err = s.repo.Delete(tenantCtx("merchant-1"), created.Id)
+				assert.NoError(t, err)
+				assert.True(t, deleted)
+
+				s.expect.Delete("merchant-1", created.Id, false)
+				deleted, err = s.repo.Delete(tenantCtx("merchant-1"), created.Id)
+				assert.NoError(t, err)
+				assert.False(t, deleted)
+
+				s.expect.GetById("merchant-1", created.Id, nil)
+				_, err = s.repo.GetById(tenantCtx("merchant-1"), created.Id)
+				assert.Equal(t, models.ErrNotFound, err)
+			})
+
+			t.Run("deleted orders are neither found, listed nor updated", func(t *testing.T) {
+				s := newStore(t)
+				deleted := s.create(t, "merchant-1", 100)
+				kept := s.create(t, "merchant-1", 200)
+				ctx := tenantCtx("merchant-1")
+
+				s.expect.Delete("merchant-1", deleted.Id, true)
+				ok, err := s.repo.Delete(ctx, deleted.Id)
+				assert.NoError(t, err)
+				assert.True(t, ok)
+
+				s.expect.GetById("merchant-1", deleted.Id, nil)
+				_, err = s.repo.GetById(ctx, deleted.Id)
+				assert.Equal(t, models.ErrNotFound, err)
+
+				s.expect.GetByPublicId("merchant-1", deleted.PublicId, nil)
+				_, err = s.repo.GetByPublicId(ctx, deleted.PublicId)
+				assert.Equal(t, models.ErrNotFound, err)
+
+				s.expect.List("merchant-1", 0, 10, []models.Order{kept})
+				orders, err := s.repo.List(ctx, 0, 10)
+				assert.NoError(t, err)
+				if assert.Len(t, orders, 1) {
+					assert.Equal(t, kept.Id, orders[0].Id)
+				}
+
+				s.expect.Update("merchant-1", deleted.Id, models.StatusTaken, models.StatusUnassigned, false)
+				_, err = s.repo.Update(ctx, &models.Order{Id: deleted.Id, Status: models.StatusTaken}, models.StatusUnassigned)
+				assert.Equal(t, models.ErrCannotUpdate, err)
+			})
+
+			t.Run("writes record the events of the order", func(t *testing.T) {
+				s := newStore(t)
+				created := s.create(t, "merchant-1", 100)
+				// the order is taken as read, so the event carries its public id
+				take := func() error {
+					order := created
+					order.Status = models.StatusTaken
+					_, err := s.repo.Update(tenantCtx("merchant-1"), &order, models.StatusUnassigned)
+					return err
+				}
+
+				s.expect.Update("merchant-1", created.Id, models.StatusTaken, models.StatusUnassigned, true)
+				assert.NoError(t, take())
+
+				// a take which fails records nothing
+				s.expect.Update("merchant-1", created.Id, models.StatusTaken, models.StatusUnassigned, false)
+				assert.Equal(t, models.ErrCannotUpdate, take())
+
+				if s.events == nil {
+					return
+				}
+				assert.Equal(t, []string{models.EventTypeOrderPlaced, models.EventTypeOrderTaken}, s.orderEvents(t, created.Id))
+				for _, event := range s.events(t) {
+					assert.Equal(t, "merchant-1", event.MerchantId)
+					assert.Equal(t, created.PublicId, event.OrderPublicId())
+				}
+			})
+
+			t.Run("list pages the merchant's orders by id", func(t *testing.T) {
+				s := newStore(t)
+				first := s.create(t, "merchant-1", 100)
+				s.create(t, "merchant-2", 200)
+				second := s.create(t, "merchant-1", 300)
+				third := s.create(t, "merchant-1", 400)
+
+				pages := []struct {
+					offset, limit int
+					orders        []models.Order
+				}{
+					{0, 2, []models.Order{first, second}},
+					{2, 2, []models.Order{third}},
+					{3, 2, []models.Order{}},
+				}
+
+				for _, page := range pages {
+					s.expect.List("merchant-1", page.offset, page.limit, page.orders)
+					orders, err := s.repo.List(tenantCtx("merchant-1"), page.offset, page.limit)
+					assert.NoError(t, err)
+					// an empty page is an empty list, not null
+					if assert.NotNil(t, orders) && assert.Len(t, orders, len(page.orders)) {
+						for i, order := range orders {
+							assert.Equal(t, page.orders[i].Id, order.Id)
+							assert.Equal(t, page.orders[i].Distance, order.Distance)
+							assert.Equal(t, "merchant-1", order.MerchantId)
+						}
+					}
+				}
+			})
+
+			t.Run("calls without a merchant fail", func(t *testing.T) {
+				s := newStore(t)
+				ctx := context.Background()
+
+				_, err := s.repo.Create(ctx, newContractOrder(100))
+				assert.Equal(t, models.ErrNoTenant, err)
+				_, err = s.repo.GetById(ctx, 1)
+				assert.Equal(t, models.ErrNoTenant, err)
+				_, err = s.repo.GetByPublicId(ctx, "01ARZ3NDEKTSV4RRFFQ69G5FAV")
+				assert.Equal(t, models.ErrNoTenant, err)
+				_, err = s.repo.Update(ctx, &models.Order{Id: 1, Status: models.StatusTaken}, models.StatusUnassigned)
+				assert.Equal(t, models.ErrNoTenant, err)
+				_, err = s.repo.Delete(ctx, 1)
+				assert.Equal(t, models.ErrNoTenant, err)
+				_, err = s.repo.List(ctx, 0, 10)
+				assert.Equal(t, models.ErrNoTenant, err)
+			})
+
+			t.Run("only one of concurrent takes succeeds", func(t *testing.T) {
+				s := newStore(t)
+				if !s.concurrent {
+					t.Skip("the store cannot be raced")
+				}
+				created := s.create(t, "merchant-1", 100)
+
+				var wg sync.WaitGroup
+				errs := make(chan error, 20)
+				for i := 0; i < 20; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						_, err := s.repo.Update(tenantCtx("merchant-1"), &models.Order{Id: created.Id, Status: models.StatusTaken}, models.StatusUnassigned)
+						errs <- err
+					}()
+				}
+				wg.Wait()
+				close(errs)
+
+				taken := 0
+				for err := range errs {
+					if err == nil {
+						taken++
+						continue
+					}
+					assert.Equal(t, models.ErrCannotUpdate, err)
+				}
+				assert.Equal(t, 1, taken)
+			})
+
+			t.Run("a transaction commits its calls together", func(t *testing.T) {
+				s := newStore(t)
+				if !s.transactional {
+					t.Skip("the store does not run transactions")
+				}
+				created := s.create(t, "merchant-1", 100)
+
+				var placed *models.Order
+				err := s.repo.WithTx(tenantCtx("merchant-1"), func(ctx context.Context, repo OrderRepository) error {
+					if _, err := repo.Update(ctx, &models.Order{Id: created.Id, Status: models.StatusTaken}, models.StatusUnassigned); err != nil {
+						return err
+					}
+
+					order, err := repo.GetById(ctx, created.Id)
+					if err != nil {
+						return err
+					}
+					assert.Equal(t, models.StatusTaken, order.Status, "the transaction sees its own writes")
+
+					placed, err = repo.Create(ctx, newContractOrder(200))
+					return err
+				})
+				assert.NoError(t, err)
+
+				order, err := s.repo.GetById(tenantCtx("merchant-1"), created.Id)
+				assert.NoError(t, err)
+				assert.Equal(t, models.StatusTaken, order.Status)
+				_, err = s.repo.GetById(tenantCtx("merchant-1"), placed.Id)
+				assert.NoError(t, err)
+			})
+
+			t.Run("a failed transaction is rolled back", func(t *testing.T) {
+				s := newStore(t)
+				if !s.transactional {
+					t.Skip("the store does not run transactions")
+				}
+				created := s.create(t, "merchant-1", 100)
+
+				var placed *models.Order
+				err := s.repo.WithTx(tenantCtx("merchant-1"), func(ctx context.Context, repo OrderRepository) error {
+					var err error
+					if placed, err = repo.Create(ctx, newContractOrder(200)); err != nil {
+						return err
+					}
+					if _, err := repo.Update(ctx, &models.Order{Id: created.Id, Status: models.StatusTaken}, models.StatusUnassigned); err != nil {
+						return err
+					}
+
+					// the order was taken twice, the first take must be undone with the order placed before it
+					_, err = repo.Update(ctx, &models.Order{Id: created.Id, Status: models.StatusTaken}, models.StatusUnassigned)
+					return err
+				})
+				assert.Equal(t, models.ErrCannotUpdate, err)
+
+				order, err := s.repo.GetById(tenantCtx("merchant-1"), created.Id)
+				assert.NoError(t, err)
+				assert.Equal(t, models.StatusUnassigned, order.Status)
+				_, err = s.repo.GetById(tenantCtx("merchant-1"), placed.Id)
+				assert.Equal(t, models.ErrNotFound, err)
+
+				// so are the events of its writes
+				if s.events != nil {
+					assert.Equal(t, []string{models.EventTypeOrderPlaced}, s.orderEvents(t, created.Id))
+					assert.Empty(t, s.orderEvents(t, placed.Id))
+				}
+			})
+
+			t.Run("a panicking transaction is rolled back", func(t *testing.T) {
+				s := newStore(t)
+				if !s.transactional {
+					t.Skip("the store does not run transactions")
+				}
+				created := s.create(t, "merchant-1", 100)
+
+				assert.Panics(t, func() {
+					s.repo.WithTx(tenantCtx("merchant-1"), func(ctx context.Context, repo OrderRepository) error {
+						if _, err := repo.Update(ctx, &models.Order{Id: created.Id, Status: models.StatusTaken}, models.StatusUnassigned); err != nil {
+							return err
+						}
+						panic("exception")
+					})
+				})
+
+				order, err := s.repo.GetById(tenantCtx("merchant-1"), created.Id)
+				assert.NoError(t, err)
+				assert.Equal(t, models.StatusUnassigned, order.Status)
+			})
+
+			t.Run("nested transactions fail", func(t *testing.T) {
+				s := newStore(t)
+				if !s.transactional {
+					t.Skip("the store does not run transactions")
+				}
+
+				err := s.repo.WithTx(tenantCtx("merchant-1"), func(ctx context.Context, repo OrderRepository) error {
+					assert.Equal(t, models.ErrNestedTx, repo.WithTx(ctx, func(context.Context, OrderRepository) error { return nil }))
+					assert.Equal(t, models.ErrNestedTx, s.repo.WithTx(ctx, func(context.Context, OrderRepository) error { return nil }))
+					// the repository of the transaction knows it is in one, whatever the context
+					assert.Equal(t, models.ErrNestedTx, repo.WithTx(tenantCtx("merchant-1"), func(context.Context, OrderRepository) error { return nil }))
+					return nil
+				})
+				assert.NoError(t, err)
+			})
+		})
+	}
+}
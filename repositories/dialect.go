@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Dialect is the SQL flavour of a database. Queries are written with ? placeholders and
+// rewritten by the repositories for the dialect they were created with
+type Dialect struct {
+	// Name is the database kind, as reported in the db.system attribute of traces
+	Name string
+	// numbered dialects use $1, $2, ... placeholders
+	numbered bool
+	// returning dialects read the id of inserted rows from a RETURNING clause, the others from LastInsertId
+	returning bool
+}
+
+var (
+	MySQL    = Dialect{Name: "mysql"}
+	Postgres = Dialect{Name: "postgresql", numbered: true, returning: true}
+	// SQLite shares the placeholders, pagination and LastInsertId of MySQL
+	SQLite = Dialect{Name: "sqlite"}
+)
+
+// Rebind rewrites the ? placeholders of query to the placeholders of the dialect, queries must not
+// contain a literal question mark
+func (d Dialect) Rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+
+		n++
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+	}
+
+	return b.String()
+}
+
+// limit returns the clause selecting limit rows after offset, with its arguments in order
+func (d Dialect) limit(offset, limit int) (string, []interface{}) {
+	if d.numbered {
+		return "LIMIT ? OFFSET ?", []interface{}{limit, offset}
+	}
+
+	return "LIMIT ?, ?", []interface{}{offset, limit}
+}
+
+// insertQuery returns the insert query to prepare for insert
+func (d Dialect) insertQuery(query string) string {
+	if d.returning {
+		query += " RETURNING id"
+	}
+
+	return d.Rebind(query)
+}
+
+// insert runs stmt, prepared from insertQuery, and returns the id of the inserted row
+func (d Dialect) insert(ctx context.Context, stmt *sql.Stmt, args ...interface{}) (int64, error) {
+	var id int64
+
+	if d.returning {
+		err := stmt.QueryRowContext(ctx, args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// forUpdate returns the clause locking the selected rows until the end of the transaction,
+// SQLite has none since it locks the whole database for the writes of a transaction
+func (d Dialect) forUpdate() string {
+	if d == SQLite {
+		return ""
+	}
+
+	return " FOR UPDATE"
+}
+
+// placeholders returns n comma separated ? placeholders, for a list of n values
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// findInSet returns the condition matching rows whose comma separated column contains the ? argument
+func (d Dialect) findInSet(column string) string {
+	switch d {
+	case MySQL:
+		return "FIND_IN_SET(?, " + column + ") > 0"
+	case Postgres:
+		return "position(',' || ? || ',' in ',' || " + column + " || ',') > 0"
+	default:
+		return "instr(',' || " + column + " || ',', ',' || ? || ',') > 0"
+	}
+}
+
+// mysqlErrUnknownStmtHandler is returned by mysql when a statement was deallocated on the server
+const mysqlErrUnknownStmtHandler = 1243
+
+// isUnknownStatement tells if err is the failure of a prepared statement the server no longer knows,
+// like after a failover behind a proxy keeping the connection open
+func isUnknownStatement(err error) bool {
+	switch err := err.(type) {
+	case *mysql.MySQLError:
+		return err.Number == mysqlErrUnknownStmtHandler
+	case *pq.Error:
+		// invalid_sql_statement_name
+		return err.Code == "26000"
+	default:
+		return false
+	}
+}
+
+// mysqlErrDuplicateEntry is returned by mysql when a unique constraint is violated
+const mysqlErrDuplicateEntry = 1062
+
+// isDuplicate tells if err is the violation of a primary key or a unique key, on any of the databases
+func isDuplicate(err error) bool {
+	switch err := err.(type) {
+	case *mysql.MySQLError:
+		return err.Number == mysqlErrDuplicateEntry
+	case *pq.Error:
+		return err.Code == "23505"
+	case *sqlite.Error:
+		return err.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY || err.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+	default:
+		return false
+	}
+}
@@ -0,0 +1,180 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"order-service/logger"
+	"order-service/models"
+	"order-service/tenant"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IdempotencyRepo stores idempotency keys per merchant, so merchants can never replay each other's responses
+type IdempotencyRepo struct {
+	Conn    *sql.DB
+	Timeout time.Duration
+}
+
+// NewMysqlIdempotencyRepo will create an IdempotencyRepo on conn, every query is cancelled after timeout unless timeout is zero
+func NewMysqlIdempotencyRepo(conn *sql.DB, timeout time.Duration) *IdempotencyRepo {
+	return &IdempotencyRepo{conn, timeout}
+}
+
+// NewSqliteIdempotencyRepo will create an IdempotencyRepo on a SQLite conn, the queries are the same as on mysql
+func NewSqliteIdempotencyRepo(conn *sql.DB, timeout time.Duration) *IdempotencyRepo {
+	return &IdempotencyRepo{conn, timeout}
+}
+
+func (rp *IdempotencyRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if rp.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, rp.Timeout)
+}
+
+func (rp *IdempotencyRepo) GetByKey(ctx context.Context, key string) (*models.IdempotencyRecord, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/idempotency", "method": "GetByKey", "idempotency_key": key})
+
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return nil, models.ErrNoTenant
+	}
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT idempotency_key, request_hash, status_code, response, created_at FROM idempotency_keys WHERE merchant_id = ? AND idempotency_key = ?"
+
+	record := &models.IdempotencyRecord{}
+	err := retryRead(ctx, func() error {
+		return rp.Conn.QueryRowContext(ctx, query, merchantId, key).Scan(
+			&record.Key,
+			&record.RequestHash,
+			&record.StatusCode,
+			&record.Response,
+			&record.CreatedAt)
+	})
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to query idempotency key")
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// Create reserves the key of record, it returns models.ErrAlreadyExists if the key was reserved before,
+// relying on the primary key so only one of many concurrent requests can win
+func (rp *IdempotencyRepo) Create(ctx context.Context, record *models.IdempotencyRecord) error {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/idempotency", "method": "Create", "idempotency_key": record.Key})
+
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return models.ErrNoTenant
+	}
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	query := "INSERT INTO idempotency_keys (merchant_id, idempotency_key, request_hash) VALUES (?, ?, ?)"
+
+	_, err := rp.Conn.ExecContext(ctx, query, merchantId, record.Key, record.RequestHash)
+	if isDuplicate(err) {
+		return models.ErrAlreadyExists
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to insert idempotency key")
+		return err
+	}
+
+	return nil
+}
+
+// Update stores the response of the request that reserved the key
+func (rp *IdempotencyRepo) Update(ctx context.Context, record *models.IdempotencyRecord) error {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/idempotency", "method": "Update", "idempotency_key": record.Key})
+
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return models.ErrNoTenant
+	}
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE idempotency_keys SET status_code = ?, response = ? WHERE merchant_id = ? AND idempotency_key = ?"
+
+	result, err := rp.Conn.ExecContext(ctx, query, record.StatusCode, record.Response, merchantId, record.Key)
+	if err != nil {
+		log.WithError(err).Error("Failed to update idempotency key")
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.WithError(err).Error("Failed to get affected rows")
+		return err
+	}
+
+	if rowsAffected != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+func (rp *IdempotencyRepo) Delete(ctx context.Context, key string) error {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/idempotency", "method": "Delete", "idempotency_key": key})
+
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return models.ErrNoTenant
+	}
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	query := "DELETE FROM idempotency_keys WHERE merchant_id = ? AND idempotency_key = ?"
+
+	_, err := rp.Conn.ExecContext(ctx, query, merchantId, key)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete idempotency key")
+		return err
+	}
+
+	return nil
+}
+
+func (rp *IdempotencyRepo) DeleteExpired(ctx context.Context, key string, before time.Time) (bool, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/idempotency", "method": "DeleteExpired", "idempotency_key": key})
+
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return false, models.ErrNoTenant
+	}
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	query := "DELETE FROM idempotency_keys WHERE merchant_id = ? AND idempotency_key = ? AND created_at < ?"
+
+	result, err := rp.Conn.ExecContext(ctx, query, merchantId, key, before.UTC())
+	if err != nil {
+		log.WithError(err).Error("Failed to delete expired idempotency key")
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.WithError(err).Error("Failed to get affected rows")
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
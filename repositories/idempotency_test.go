@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"order-service/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyRepo_GetByKey(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	query := "SELECT idempotency_key, request_hash, status_code, response, created_at FROM idempotency_keys WHERE merchant_id = ? AND idempotency_key = ?"
+
+	rows := sqlmock.NewRows([]string{"idempotency_key", "request_hash", "status_code", "response", "created_at"}).
+		AddRow("key", "hash", 200, []byte(`{"id":1}`), time.Now())
+
+	mock.ExpectQuery(query).
+		WithArgs("merchant-1", "key").
+		WillReturnRows(rows)
+
+	mock.ExpectQuery(query).
+		WithArgs("merchant-1", "missing").
+		WillReturnRows(sqlmock.NewRows([]string{"idempotency_key", "request_hash", "status_code", "response", "created_at"}))
+
+	repo := NewMysqlIdempotencyRepo(db, time.Second)
+
+	record, err := repo.GetByKey(merchantCtx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, record.StatusCode)
+
+	record, err = repo.GetByKey(merchantCtx, "missing")
+	assert.Equal(t, models.ErrNotFound, err)
+	assert.Nil(t, record)
+}
+
+func TestIdempotencyRepo_Create(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	query := "INSERT INTO idempotency_keys (merchant_id, idempotency_key, request_hash) VALUES (?, ?, ?)"
+
+	mock.ExpectExec(query).
+		WithArgs("merchant-1", "key", "hash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectExec(query).
+		WithArgs("merchant-1", "key", "hash").
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'key' for key 'PRIMARY'"})
+
+	repo := NewMysqlIdempotencyRepo(db, time.Second)
+	record := &models.IdempotencyRecord{Key: "key", RequestHash: "hash"}
+
+	err = repo.Create(merchantCtx, record)
+	assert.NoError(t, err)
+
+	err = repo.Create(merchantCtx, record)
+	assert.Equal(t, models.ErrAlreadyExists, err)
+}
+
+func TestIdempotencyRepo_Update(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	query := "UPDATE idempotency_keys SET status_code = ?, response = ? WHERE merchant_id = ? AND idempotency_key = ?"
+
+	mock.ExpectExec(query).
+		WithArgs(200, []byte(`{"id":1}`), "merchant-1", "key").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	repo := NewMysqlIdempotencyRepo(db, time.Second)
+
+	err = repo.Update(merchantCtx, &models.IdempotencyRecord{Key: "key", StatusCode: 200, Response: []byte(`{"id":1}`)})
+	assert.NoError(t, err)
+}
+
+func TestIdempotencyRepo_DeleteExpired(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	query := "DELETE FROM idempotency_keys WHERE merchant_id = ? AND idempotency_key = ? AND created_at < ?"
+	before := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mock.ExpectExec(query).
+		WithArgs("merchant-1", "key", before).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// reserved again since it expired
+	mock.ExpectExec(query).
+		WithArgs("merchant-1", "key", before).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	repo := NewMysqlIdempotencyRepo(db, time.Second)
+
+	deleted, err := repo.DeleteExpired(merchantCtx, "key", before)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	deleted, err = repo.DeleteExpired(merchantCtx, "key", before)
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
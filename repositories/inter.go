@@ -1,11 +1,68 @@
 package repositories
 
-import "order-service/models"
+import (
+	"context"
+	"time"
+
+	"order-service/models"
+)
 
 type OrderRepository interface {
-	GetById(id int64) (*models.Order, error)
-	Update(order *models.Order, withStatus string) (*models.Order, error)
-	Create(o *models.Order) (*models.Order, error)
-	Delete(id int64) (bool, error)
-	List(offset, limit int) ([]models.Order, error)
+	GetById(ctx context.Context, id int64) (*models.Order, error)
+	// GetByPublicId returns the order with the public id clients know it by
+	GetByPublicId(ctx context.Context, publicId string) (*models.Order, error)
+	Update(ctx context.Context, order *models.Order, withStatus string) (*models.Order, error)
+	Create(ctx context.Context, o *models.Order) (*models.Order, error)
+	Delete(ctx context.Context, id int64) (bool, error)
+	List(ctx context.Context, offset, limit int) ([]models.Order, error)
+	// WithTx runs fn in a transaction, the calls of fn on repo are committed together if fn returns nil and
+	// rolled back if it fails or panics. fn may run again if the transaction deadlocks, and fails with
+	// models.ErrNestedTx if it starts another transaction
+	WithTx(ctx context.Context, fn func(ctx context.Context, repo OrderRepository) error) error
+}
+
+// OrderArchiveRepository moves the orders of all merchants that are done or deleted to the archive
+type OrderArchiveRepository interface {
+	// Archive moves up to limit orders placed before before and returns how many were moved
+	Archive(ctx context.Context, before time.Time, limit int) (int, error)
+}
+
+type IdempotencyRepository interface {
+	GetByKey(ctx context.Context, key string) (*models.IdempotencyRecord, error)
+	Create(ctx context.Context, record *models.IdempotencyRecord) error
+	Update(ctx context.Context, record *models.IdempotencyRecord) error
+	Delete(ctx context.Context, key string) error
+	// DeleteExpired deletes the key only if it was reserved before before, and reports whether it did,
+	// so a reservation made again concurrently is never deleted
+	DeleteExpired(ctx context.Context, key string, before time.Time) (bool, error)
+}
+
+type ApiKeyRepository interface {
+	GetByHash(ctx context.Context, keyHash string) (*models.ApiKey, error)
+	Create(ctx context.Context, apiKey *models.ApiKey) (*models.ApiKey, error)
+}
+
+// WebhookRepository stores webhooks and their deliveries, all methods but ListDueDeliveries, ClaimDelivery and
+// UpdateDelivery are scoped to the merchant carried by the context
+type WebhookRepository interface {
+	GetById(ctx context.Context, id int64) (*models.Webhook, error)
+	Create(ctx context.Context, webhook *models.Webhook) (*models.Webhook, error)
+	ListByEvent(ctx context.Context, event string) ([]models.Webhook, error)
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) (*models.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, webhookId int64, offset, limit int) ([]models.WebhookDelivery, error)
+	// ListDueDeliveries returns the pending deliveries of all merchants due at now, with their webhook loaded
+	ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error)
+	// ClaimDelivery leases the pending delivery due at now until until, so no other worker sends it meanwhile.
+	// It returns models.ErrCannotUpdate when the delivery is not due anymore, e.g. claimed by another worker
+	ClaimDelivery(ctx context.Context, id int64, now, until time.Time) error
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+}
+
+// OutboxRepository reads the domain events recorded together with the changes of orders
+type OutboxRepository interface {
+	ListPending(ctx context.Context, limit int) ([]models.Event, error)
+	MarkSent(ctx context.Context, id int64) error
+	// ListAfter and LatestId are scoped to the merchant carried by the context
+	ListAfter(ctx context.Context, afterId int64, limit int) ([]models.Event, error)
+	LatestId(ctx context.Context) (int64, error)
 }
@@ -0,0 +1,356 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"order-service/models"
+	"order-service/tenant"
+)
+
+// MemoryOrderRepo keeps orders in memory, it is safe for concurrent use and applies Update as a
+// compare-and-set of the status like the SQL repositories, so races of takes can be tested without a database.
+// Like them it only marks deleted orders as deleted and records the events of its writes, which Events returns,
+// but it has no archive. Transactions are run one at a time and undone by restoring the orders they wrote and
+// dropping their events, calls made outside of a transaction while it runs are not isolated from it
+type MemoryOrderRepo struct {
+	mu     sync.Mutex
+	orders map[int64]memoryOrder
+	lastId int64
+
+	events      []models.Event
+	lastEventId int64
+
+	// txMu is held by the running transaction
+	txMu sync.Mutex
+}
+
+// memoryOrder is an order as MemoryOrderRepo stores it, a deleted order is kept with the time it was deleted
+type memoryOrder struct {
+	models.Order
+	DeletedAt time.Time
+}
+
+func (o memoryOrder) deleted() bool {
+	return !o.DeletedAt.IsZero()
+}
+
+// NewMemoryOrderRepo will create an empty MemoryOrderRepo
+func NewMemoryOrderRepo() *MemoryOrderRepo {
+	return &MemoryOrderRepo{orders: make(map[int64]memoryOrder)}
+}
+
+// Events returns the events recorded by the writes of every merchant, oldest first
+func (rp *MemoryOrderRepo) Events() []models.Event {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	return append([]models.Event(nil), rp.events...)
+}
+
+// recordEvent records an event of eventType carrying order and returns its id, it is called with mu held
+func (rp *MemoryOrderRepo) recordEvent(eventType string, order *models.Order) (int64, error) {
+	event, err := models.NewOrderEvent(eventType, order)
+	if err != nil {
+		return 0, err
+	}
+
+	rp.lastEventId++
+	event.Id = rp.lastEventId
+	event.CreatedAt = now()
+	rp.events = append(rp.events, *event)
+
+	return event.Id, nil
+}
+
+// copyOrder returns a copy of order sharing no slice with it, so callers cannot change the stored orders
+func copyOrder(order models.Order) models.Order {
+	order.Origins = append([]float64(nil), order.Origins...)
+	order.Destinations = append([]float64(nil), order.Destinations...)
+	return order
+}
+
+func (rp *MemoryOrderRepo) GetById(ctx context.Context, id int64) (*models.Order, error) {
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return nil, models.ErrNoTenant
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	stored, ok := rp.orders[id]
+	if !ok || stored.MerchantId != merchantId || stored.deleted() {
+		return nil, models.ErrNotFound
+	}
+
+	order := copyOrder(stored.Order)
+	return &order, nil
+}
+
+func (rp *MemoryOrderRepo) GetByPublicId(ctx context.Context, publicId string) (*models.Order, error) {
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return nil, models.ErrNoTenant
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	for _, stored := range rp.orders {
+		if stored.PublicId == publicId && stored.MerchantId == merchantId && !stored.deleted() {
+			order := copyOrder(stored.Order)
+			return &order, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+// Update changes the status of the order if it still has withStatus, and its version when the order carries one,
+// and records the matching event
+func (rp *MemoryOrderRepo) Update(ctx context.Context, order *models.Order, withStatus string) (*models.Order, error) {
+	if inTx(ctx) {
+		return nil, models.ErrNestedTx
+	}
+
+	return rp.update(ctx, order, withStatus, nil, nil)
+}
+
+// update runs Update, calling touch with the id of the order before it is written and record with the id
+// of the event it records
+func (rp *MemoryOrderRepo) update(ctx context.Context, order *models.Order, withStatus string, touch, record func(id int64)) (*models.Order, error) {
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return nil, models.ErrNoTenant
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	stored, ok := rp.orders[order.Id]
+	if !ok || stored.MerchantId != merchantId || stored.Status != withStatus || stored.deleted() {
+		return nil, models.ErrCannotUpdate
+	}
+	if order.Version > 0 && stored.Version != order.Version {
+		return nil, models.ErrCannotUpdate
+	}
+
+	if touch != nil {
+		touch(order.Id)
+	}
+	stored.Status = order.Status
+	stored.UpdatedAt = now()
+	stored.Version++
+	rp.orders[order.Id] = stored
+
+	order.MerchantId = merchantId
+	order.UpdatedAt = stored.UpdatedAt
+	if order.Version > 0 {
+		order.Version = stored.Version
+	}
+
+	if eventType, ok := statusEvents[order.Status]; ok {
+		id, err := rp.recordEvent(eventType, order)
+		if err != nil {
+			return nil, err
+		}
+		if record != nil {
+			record(id)
+		}
+	}
+	return order, nil
+}
+
+func (rp *MemoryOrderRepo) Create(ctx context.Context, order *models.Order) (*models.Order, error) {
+	if inTx(ctx) {
+		return nil, models.ErrNestedTx
+	}
+
+	return rp.create(ctx, order, nil, nil)
+}
+
+// create runs Create, calling touch with the id of the order before it is written and record with the id
+// of its OrderPlaced event
+func (rp *MemoryOrderRepo) create(ctx context.Context, order *models.Order, touch, record func(id int64)) (*models.Order, error) {
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return nil, models.ErrNoTenant
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	// like an auto increment, ids are not given back by a rollback
+	rp.lastId++
+	order.Id = rp.lastId
+	if touch != nil {
+		touch(order.Id)
+	}
+	order.MerchantId = merchantId
+	order.CreatedAt = now()
+	order.UpdatedAt = order.CreatedAt
+	order.PublicId = newPublicId(order.CreatedAt)
+	order.Version = 1
+	rp.orders[order.Id] = memoryOrder{Order: copyOrder(*order)}
+
+	id, err := rp.recordEvent(models.EventTypeOrderPlaced, order)
+	if err != nil {
+		return nil, err
+	}
+	if record != nil {
+		record(id)
+	}
+
+	return order, nil
+}
+
+func (rp *MemoryOrderRepo) Delete(ctx context.Context, id int64) (bool, error) {
+	if inTx(ctx) {
+		return false, models.ErrNestedTx
+	}
+
+	return rp.delete(ctx, id, nil)
+}
+
+// delete runs Delete, calling touch with the id of the order before it is marked as deleted
+func (rp *MemoryOrderRepo) delete(ctx context.Context, id int64, touch func(id int64)) (bool, error) {
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return false, models.ErrNoTenant
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	stored, ok := rp.orders[id]
+	if !ok || stored.MerchantId != merchantId || stored.deleted() {
+		return false, nil
+	}
+
+	if touch != nil {
+		touch(id)
+	}
+	stored.DeletedAt = now()
+	rp.orders[id] = stored
+	return true, nil
+}
+
+// List returns the orders of the merchant ordered by id, leaving out the deleted ones
+func (rp *MemoryOrderRepo) List(ctx context.Context, offset, limit int) ([]models.Order, error) {
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return nil, models.ErrNoTenant
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	ids := make([]int64, 0, len(rp.orders))
+	for id, stored := range rp.orders {
+		if stored.MerchantId == merchantId && !stored.deleted() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	orders := make([]models.Order, 0)
+	for i := offset; i < len(ids) && len(orders) < limit; i++ {
+		orders = append(orders, copyOrder(rp.orders[ids[i]].Order))
+	}
+
+	return orders, nil
+}
+
+// WithTx runs fn on a repository of the transaction, the orders it wrote are restored and its events dropped
+// if fn fails or panics
+func (rp *MemoryOrderRepo) WithTx(ctx context.Context, fn func(ctx context.Context, repo OrderRepository) error) error {
+	if inTx(ctx) {
+		return models.ErrNestedTx
+	}
+
+	rp.txMu.Lock()
+	defer rp.txMu.Unlock()
+
+	tx := &memoryTx{MemoryOrderRepo: rp, undo: make(map[int64]*memoryOrder), recorded: make(map[int64]bool)}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.rollback()
+		}
+	}()
+
+	if err := fn(withinTx(ctx), tx); err != nil {
+		return err
+	}
+
+	committed = true
+	return nil
+}
+
+// memoryTx is the repository of a transaction of MemoryOrderRepo, it keeps the orders stored before its
+// first write of each of them, nil for an order it created, and the ids of the events it recorded,
+// so a rollback undoes only what it wrote
+type memoryTx struct {
+	*MemoryOrderRepo
+	undo     map[int64]*memoryOrder
+	recorded map[int64]bool
+}
+
+// touch records the order stored before the first write of id, it is called with mu held
+func (tx *memoryTx) touch(id int64) {
+	if _, ok := tx.undo[id]; ok {
+		return
+	}
+
+	if order, ok := tx.orders[id]; ok {
+		tx.undo[id] = &order
+	} else {
+		tx.undo[id] = nil
+	}
+}
+
+// record keeps the id of an event recorded by the transaction, it is called with mu held
+func (tx *memoryTx) record(id int64) {
+	tx.recorded[id] = true
+}
+
+func (tx *memoryTx) rollback() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	for id, order := range tx.undo {
+		if order == nil {
+			delete(tx.orders, id)
+		} else {
+			tx.orders[id] = *order
+		}
+	}
+
+	events := tx.events[:0]
+	for _, event := range tx.events {
+		if !tx.recorded[event.Id] {
+			events = append(events, event)
+		}
+	}
+	tx.events = events
+}
+
+func (tx *memoryTx) Update(ctx context.Context, order *models.Order, withStatus string) (*models.Order, error) {
+	return tx.update(ctx, order, withStatus, tx.touch, tx.record)
+}
+
+func (tx *memoryTx) Create(ctx context.Context, order *models.Order) (*models.Order, error) {
+	return tx.create(ctx, order, tx.touch, tx.record)
+}
+
+func (tx *memoryTx) Delete(ctx context.Context, id int64) (bool, error) {
+	return tx.delete(ctx, id, tx.touch)
+}
+
+// WithTx fails, the repository already runs in a transaction
+func (tx *memoryTx) WithTx(context.Context, func(ctx context.Context, repo OrderRepository) error) error {
+	return models.ErrNestedTx
+}
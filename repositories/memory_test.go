@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"order-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryOrderRepo_WithTx(t *testing.T) {
+	rp := NewMemoryOrderRepo()
+	ctx := tenantCtx("merchant-1")
+
+	untouched, err := rp.Create(ctx, newContractOrder(100))
+	assert.NoError(t, err)
+	taken, err := rp.Create(ctx, newContractOrder(100))
+	assert.NoError(t, err)
+
+	err = rp.WithTx(ctx, func(txCtx context.Context, repo OrderRepository) error {
+		if _, err := repo.Update(txCtx, &models.Order{Id: taken.Id, Status: models.StatusTaken}, models.StatusUnassigned); err != nil {
+			return err
+		}
+
+		// a write outside of the transaction is not undone with it
+		if _, err := rp.Update(ctx, &models.Order{Id: untouched.Id, Status: models.StatusTaken}, models.StatusUnassigned); err != nil {
+			return err
+		}
+
+		return errors.New("exception")
+	})
+	assert.EqualError(t, err, "exception")
+
+	order, err := rp.GetById(ctx, taken.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusUnassigned, order.Status)
+	order, err = rp.GetById(ctx, untouched.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusTaken, order.Status)
+}
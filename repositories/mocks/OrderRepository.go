@@ -2,21 +2,23 @@
 
 package mocks
 
+import context "context"
 import mock "github.com/stretchr/testify/mock"
 import models "order-service/models"
+import repositories "order-service/repositories"
 
 // OrderRepository is an autogenerated mock type for the OrderRepository type
 type OrderRepository struct {
 	mock.Mock
 }
 
-// Create provides a mock function with given fields: o
-func (_m *OrderRepository) Create(o *models.Order) (*models.Order, error) {
-	ret := _m.Called(o)
+// Create provides a mock function with given fields: ctx, o
+func (_m *OrderRepository) Create(ctx context.Context, o *models.Order) (*models.Order, error) {
+	ret := _m.Called(ctx, o)
 
 	var r0 *models.Order
-	if rf, ok := ret.Get(0).(func(*models.Order) *models.Order); ok {
-		r0 = rf(o)
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Order) *models.Order); ok {
+		r0 = rf(ctx, o)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.Order)
@@ -24,8 +26,8 @@ func (_m *OrderRepository) Create(o *models.Order) (*models.Order, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(*models.Order) error); ok {
-		r1 = rf(o)
+	if rf, ok := ret.Get(1).(func(context.Context, *models.Order) error); ok {
+		r1 = rf(ctx, o)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -33,20 +35,20 @@ func (_m *OrderRepository) Create(o *models.Order) (*models.Order, error) {
 	return r0, r1
 }
 
-// Delete provides a mock function with given fields: id
-func (_m *OrderRepository) Delete(id int64) (bool, error) {
-	ret := _m.Called(id)
+// Delete provides a mock function with given fields: ctx, id
+func (_m *OrderRepository) Delete(ctx context.Context, id int64) (bool, error) {
+	ret := _m.Called(ctx, id)
 
 	var r0 bool
-	if rf, ok := ret.Get(0).(func(int64) bool); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, int64) bool); ok {
+		r0 = rf(ctx, id)
 	} else {
 		r0 = ret.Get(0).(bool)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(int64) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -54,13 +56,13 @@ func (_m *OrderRepository) Delete(id int64) (bool, error) {
 	return r0, r1
 }
 
-// GetById provides a mock function with given fields: id
-func (_m *OrderRepository) GetById(id int64) (*models.Order, error) {
-	ret := _m.Called(id)
+// GetById provides a mock function with given fields: ctx, id
+func (_m *OrderRepository) GetById(ctx context.Context, id int64) (*models.Order, error) {
+	ret := _m.Called(ctx, id)
 
 	var r0 *models.Order
-	if rf, ok := ret.Get(0).(func(int64) *models.Order); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, int64) *models.Order); ok {
+		r0 = rf(ctx, id)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.Order)
@@ -68,8 +70,8 @@ func (_m *OrderRepository) GetById(id int64) (*models.Order, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(int64) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -77,13 +79,36 @@ func (_m *OrderRepository) GetById(id int64) (*models.Order, error) {
 	return r0, r1
 }
 
-// List provides a mock function with given fields: offset, limit
-func (_m *OrderRepository) List(offset int, limit int) ([]models.Order, error) {
-	ret := _m.Called(offset, limit)
+// GetByPublicId provides a mock function with given fields: ctx, publicId
+func (_m *OrderRepository) GetByPublicId(ctx context.Context, publicId string) (*models.Order, error) {
+	ret := _m.Called(ctx, publicId)
+
+	var r0 *models.Order
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.Order); ok {
+		r0 = rf(ctx, publicId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Order)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, publicId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx, offset, limit
+func (_m *OrderRepository) List(ctx context.Context, offset int, limit int) ([]models.Order, error) {
+	ret := _m.Called(ctx, offset, limit)
 
 	var r0 []models.Order
-	if rf, ok := ret.Get(0).(func(int, int) []models.Order); ok {
-		r0 = rf(offset, limit)
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) []models.Order); ok {
+		r0 = rf(ctx, offset, limit)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.Order)
@@ -91,8 +116,8 @@ func (_m *OrderRepository) List(offset int, limit int) ([]models.Order, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(int, int) error); ok {
-		r1 = rf(offset, limit)
+	if rf, ok := ret.Get(1).(func(context.Context, int, int) error); ok {
+		r1 = rf(ctx, offset, limit)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -100,13 +125,13 @@ func (_m *OrderRepository) List(offset int, limit int) ([]models.Order, error) {
 	return r0, r1
 }
 
-// Update provides a mock function with given fields: order, withStatus
-func (_m *OrderRepository) Update(order *models.Order, withStatus string) (*models.Order, error) {
-	ret := _m.Called(order, withStatus)
+// Update provides a mock function with given fields: ctx, order, withStatus
+func (_m *OrderRepository) Update(ctx context.Context, order *models.Order, withStatus string) (*models.Order, error) {
+	ret := _m.Called(ctx, order, withStatus)
 
 	var r0 *models.Order
-	if rf, ok := ret.Get(0).(func(*models.Order, string) *models.Order); ok {
-		r0 = rf(order, withStatus)
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Order, string) *models.Order); ok {
+		r0 = rf(ctx, order, withStatus)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.Order)
@@ -114,11 +139,25 @@ func (_m *OrderRepository) Update(order *models.Order, withStatus string) (*mode
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(*models.Order, string) error); ok {
-		r1 = rf(order, withStatus)
+	if rf, ok := ret.Get(1).(func(context.Context, *models.Order, string) error); ok {
+		r1 = rf(ctx, order, withStatus)
 	} else {
 		r1 = ret.Error(1)
 	}
 
 	return r0, r1
 }
+
+// WithTx provides a mock function with given fields: ctx, fn
+func (_m *OrderRepository) WithTx(ctx context.Context, fn func(context.Context, repositories.OrderRepository) error) error {
+	ret := _m.Called(ctx, fn)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, func(context.Context, repositories.OrderRepository) error) error); ok {
+		r0 = rf(ctx, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -1,45 +1,252 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"order-service/archive"
+	"order-service/logger"
 	"order-service/models"
+	"order-service/replica"
+	"order-service/tenant"
+
+	"github.com/sirupsen/logrus"
 )
 
+// OrderRepo stores orders in MySQL, Postgres or SQLite, every query is scoped to the merchant carried by the context
+// and fails with models.ErrNoTenant if there is none, so orders never leak across merchants.
+// Deleted orders keep their row with deleted_at set until Archive moves them to orders_archive, and are
+// never read again. Lookups of a context of archive.WithArchived also read the archive.
+// Reads are spread over Replicas when there are some, writes always go to Conn.
+// The statements of the writes are prepared once and reused until Close.
+// WithTx runs several calls in one transaction of Conn
 type OrderRepo struct {
-	Conn *sql.DB
+	Conn     *sql.DB
+	Timeout  time.Duration
+	Dialect  Dialect
+	Replicas []*sql.DB
+
+	// next is the index of the replica serving the next read
+	next uint32
+
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+
+	// tx is set on the repository given to the function of WithTx, it runs every query in tx
+	// with the statements prepared by root
+	tx   *sql.Tx
+	root *OrderRepo
+}
+
+// the statements of the writes, with ? placeholders
+const (
+	updateOrderQuery        = "UPDATE orders SET status = ?, updated_at = ?, version = version + 1 where id = ? AND merchant_id = ? AND status = ? AND deleted_at IS NULL"
+	updateOrderVersionQuery = updateOrderQuery + " AND version = ?"
+	insertOrderQuery        = "INSERT INTO orders (public_id, merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, distance, status, created_at, updated_at, version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)"
+	deleteOrderQuery        = "UPDATE orders SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL"
+)
+
+// orderColumns are the columns of the orders read by the repository, in the order fetch scans them
+const orderColumns = "id, public_id, merchant_id, distance, status, created_at, updated_at, version"
+
+// archivedColumns are the columns copied from orders to orders_archive
+const archivedColumns = "id, public_id, merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, status, distance, created_at, updated_at, version, deleted_at"
+
+// ArchivedStatuses are the terminal statuses, after which an order does not change anymore, so it can be
+// archived. A taken order is still on its way and stays
+var ArchivedStatuses = []string{models.StatusDelivered, models.StatusCancelled}
+
+// now is the time of the writes, in UTC and truncated to the seconds kept by every database,
+// so an order returned by a write has the times later reads return
+var now = func() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
 }
 
-func NewMysqlOrderRepo(conn *sql.DB) *OrderRepo {
-	return &OrderRepo{conn}
+// Prepare prepares the statements of the writes, so the first calls do not pay for it.
+// Statements not prepared yet, or dropped by the server, are prepared on first use
+func (rp *OrderRepo) Prepare(ctx context.Context) error {
+	queries := []string{rp.Dialect.Rebind(updateOrderQuery), rp.Dialect.Rebind(updateOrderVersionQuery), rp.Dialect.insertQuery(insertOrderQuery), rp.Dialect.Rebind(deleteOrderQuery)}
+	for _, query := range queries {
+		if _, err := rp.stmt(ctx, query); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (rp *OrderRepo) fetch(query string, args ...interface{}) ([]models.Order, error) {
-	rows, err := rp.Conn.Query(query, args...)
+// stmt returns the prepared statement of query, preparing it if needed. database/sql prepares the
+// statement again on every connection it runs on, so it keeps working when its first connection is lost
+func (rp *OrderRepo) stmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	if rp.root != nil {
+		return rp.root.stmt(ctx, query)
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	if stmt, ok := rp.stmts[query]; ok {
+		return stmt, nil
+	}
+
+	stmt, err := rp.Conn.PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
+	if rp.stmts == nil {
+		rp.stmts = make(map[string]*sql.Stmt)
+	}
+	rp.stmts[query] = stmt
+
+	return stmt, nil
+}
+
+// checkStmt drops the statement of query if err tells the server no longer knows it, so the next call prepares it again
+func (rp *OrderRepo) checkStmt(query string, err error) {
+	if !isUnknownStatement(err) {
+		return
+	}
+	if rp.root != nil {
+		rp.root.checkStmt(query, err)
+		return
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	if stmt, ok := rp.stmts[query]; ok {
+		stmt.Close()
+		delete(rp.stmts, query)
+	}
+}
+
+// Close closes the prepared statements, the repository prepares them again if it is used afterwards
+func (rp *OrderRepo) Close() error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	var firstErr error
+	for query, stmt := range rp.stmts {
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(rp.stmts, query)
+	}
+
+	return firstErr
+}
+
+// NewMysqlOrderRepo will create an OrderRepo on conn, every query is cancelled after timeout unless timeout is zero
+func NewMysqlOrderRepo(conn *sql.DB, timeout time.Duration) *OrderRepo {
+	return &OrderRepo{Conn: conn, Timeout: timeout, Dialect: MySQL}
+}
+
+// NewSqliteOrderRepo will create an OrderRepo on a SQLite conn, every query is cancelled after timeout unless
+// timeout is zero. SQLite serializes writes, so the conditional status update is as safe as on the other databases
+func NewSqliteOrderRepo(conn *sql.DB, timeout time.Duration) *OrderRepo {
+	return &OrderRepo{Conn: conn, Timeout: timeout, Dialect: SQLite}
+}
+
+// NewPostgresOrderRepo will create an OrderRepo on a Postgres conn, every query is cancelled after timeout
+// unless timeout is zero
+func NewPostgresOrderRepo(conn *sql.DB, timeout time.Duration) *OrderRepo {
+	return &OrderRepo{Conn: conn, Timeout: timeout, Dialect: Postgres}
+}
+
+// withTimeout bounds ctx by the repository timeout, so a slow query cannot hold a connection forever
+func (rp *OrderRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if rp.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, rp.Timeout)
+}
+
+// reader returns the database serving a read of ctx, the replicas take turns unless ctx requires the primary
+func (rp *OrderRepo) reader(ctx context.Context) *sql.DB {
+	if len(rp.Replicas) == 0 || replica.Primary(ctx) {
+		return rp.Conn
+	}
+
+	next := atomic.AddUint32(&rp.next, 1)
+	return rp.Replicas[int(next-1)%len(rp.Replicas)]
+}
+
+func (rp *OrderRepo) fetch(ctx context.Context, query string, args ...interface{}) ([]models.Order, error) {
+	conn := rp.reader(ctx)
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	var rows *sql.Rows
+	var err error
+	if rp.tx != nil {
+		// the connection of a transaction cannot be replaced, so its reads are not retried
+		rows, err = rp.tx.QueryContext(ctx, rp.Dialect.Rebind(query), args...)
+	} else {
+		rows, err = queryContext(ctx, conn, rp.Dialect.Rebind(query), args...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
 	orders := make([]models.Order, 0)
 
 	for rows.Next() {
 		order := models.Order{}
+		// orders placed before public ids have none until AssignPublicIds reaches them
+		var publicId sql.NullString
 
-		err := rows.Scan(&order.Id, &order.Distance, &order.Status)
+		err := rows.Scan(&order.Id, &publicId, &order.MerchantId, &order.Distance, &order.Status, &order.CreatedAt, &order.UpdatedAt, &order.Version)
 		if err != nil {
 			return nil, err
 		}
+		order.PublicId = publicId.String
 
 		orders = append(orders, order)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return orders, nil
 }
 
-func (rp *OrderRepo) GetById(id int64) (*models.Order, error) {
-	query := "SELECT id, distance, status FROM orders WHERE id = ?"
-	orders, err := rp.fetch(query, id)
+func (rp *OrderRepo) GetById(ctx context.Context, id int64) (*models.Order, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/order", "method": "GetById", "order_id": id})
+
+	return rp.getBy(ctx, log, "id", id)
+}
+
+func (rp *OrderRepo) GetByPublicId(ctx context.Context, publicId string) (*models.Order, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/order", "method": "GetByPublicId", "public_id": publicId})
+
+	return rp.getBy(ctx, log, "public_id", publicId)
+}
+
+// getBy returns the order of the merchant of ctx whose column has value
+func (rp *OrderRepo) getBy(ctx context.Context, log *logrus.Entry, column string, value interface{}) (*models.Order, error) {
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return nil, models.ErrNoTenant
+	}
+
+	query := "SELECT " + orderColumns + " FROM orders WHERE " + column + " = ? AND merchant_id = ? AND deleted_at IS NULL"
+	args := []interface{}{value, merchantId}
+	if archive.Included(ctx) {
+		query += " UNION ALL SELECT " + orderColumns + " FROM orders_archive WHERE " + column + " = ? AND merchant_id = ? AND deleted_at IS NULL"
+		args = append(args, value, merchantId)
+	}
+
+	orders, err := rp.fetch(ctx, query, args...)
 	if err != nil {
+		log.WithError(err).Error("Failed to query order")
 		return nil, err
 	}
 
@@ -50,92 +257,442 @@ func (rp *OrderRepo) GetById(id int64) (*models.Order, error) {
 	return &orders[0], nil
 }
 
-func (rp *OrderRepo) Update(order *models.Order, withStatus string) (*models.Order, error) {
-	query := "UPDATE orders SET status = ? where id = ? AND status = ?"
+// WithTx runs fn in a transaction of Conn, retried from the start up to TxRetries times if it deadlocks
+func (rp *OrderRepo) WithTx(ctx context.Context, fn func(ctx context.Context, repo OrderRepository) error) error {
+	if rp.tx != nil || inTx(ctx) {
+		return models.ErrNestedTx
+	}
 
-	stmt, err := rp.Conn.Prepare(query)
+	ctx = withinTx(ctx)
+	return retryTx(ctx, func() error {
+		return rp.runTx(ctx, fn)
+	})
+}
+
+// runTx runs fn once in a new transaction, rolled back if fn fails or panics
+func (rp *OrderRepo) runTx(ctx context.Context, fn func(ctx context.Context, repo OrderRepository) error) error {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/order", "method": "WithTx"})
+
+	// prepared before the transaction holds a connection, a pool of one connection would wait for itself
+	if err := rp.Prepare(ctx); err != nil {
+		log.WithError(err).Error("Failed to prepare statements")
+		return err
+	}
+
+	tx, err := rp.Conn.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		log.WithError(err).Error("Failed to begin transaction")
+		return err
+	}
+	// no-op once committed, also rolls back when fn panics
+	defer tx.Rollback()
+
+	txRepo := &OrderRepo{Conn: rp.Conn, Timeout: rp.Timeout, Dialect: rp.Dialect, tx: tx, root: rp}
+	if err := fn(ctx, txRepo); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithError(err).Error("Failed to commit transaction")
+		return err
 	}
 
-	result, err := stmt.Exec(
-		order.Status,
-		order.Id,
-		withStatus)
+	return nil
+}
 
+// write runs fn in the transaction of the repository, or in a new transaction committed if fn succeeds and
+// retried like WithTx. A write of a repository outside of the transaction of ctx fails, it could wait for the
+// locks of that transaction
+func (rp *OrderRepo) write(ctx context.Context, log *logrus.Entry, fn func(tx *sql.Tx) error) error {
+	if rp.tx != nil {
+		return fn(rp.tx)
+	}
+	if inTx(ctx) {
+		return models.ErrNestedTx
+	}
+
+	return retryTx(ctx, func() error {
+		return rp.writeTx(ctx, log, fn)
+	})
+}
+
+// writeTx runs fn once in a new transaction committed if fn succeeds
+func (rp *OrderRepo) writeTx(ctx context.Context, log *logrus.Entry, fn func(tx *sql.Tx) error) error {
+	tx, err := rp.Conn.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		log.WithError(err).Error("Failed to begin transaction")
+		return err
 	}
+	// no-op once committed
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithError(err).Error("Failed to commit transaction")
+		return err
+	}
+
+	return nil
+}
+
+// statusEvents maps the status an order is updated to, to the type of the event recording the change
+var statusEvents = map[string]string{
+	models.StatusTaken: models.EventTypeOrderTaken,
+}
+
+// Update changes the status of the order if it still has withStatus, and its version when the order
+// carries one, the matching event is written to the outbox in the same transaction.
+// The version of the updated order is incremented, an order without version keeps none
+func (rp *OrderRepo) Update(ctx context.Context, order *models.Order, withStatus string) (*models.Order, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/order", "method": "Update", "order_id": order.Id, "status": order.Status, "with_status": withStatus})
+
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return nil, models.ErrNoTenant
+	}
+
+	// the order is changed once written, so a transaction run again updates the version it was given
+	updated := *order
+	updated.MerchantId = merchantId
+	updated.UpdatedAt = now()
+	if updated.Version > 0 {
+		updated.Version++
+	}
+
+	query, args := updateOrderQuery, []interface{}{order.Status, updated.UpdatedAt, order.Id, merchantId, withStatus}
+	if order.Version > 0 {
+		query, args = updateOrderVersionQuery, append(args, order.Version)
+	}
+	query = rp.Dialect.Rebind(query)
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	stmt, err := rp.stmt(ctx, query)
 	if err != nil {
+		log.WithError(err).Error("Failed to prepare statement")
 		return nil, err
 	}
 
-	if rowsAffected != 1 {
-		return nil, models.ErrCannotUpdate
+	err = rp.write(ctx, log, func(tx *sql.Tx) error {
+		result, err := tx.StmtContext(ctx, stmt).ExecContext(ctx, args...)
+
+		if err != nil {
+			rp.checkStmt(query, err)
+			log.WithError(err).Error("Failed to update order")
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			log.WithError(err).Error("Failed to get affected rows")
+			return err
+		}
+
+		if rowsAffected != 1 {
+			log.Debug("No order updated, since status or version does not match")
+			return models.ErrCannotUpdate
+		}
+
+		if eventType, ok := statusEvents[order.Status]; ok {
+			if err := insertEvent(ctx, tx, rp.Dialect, eventType, &updated); err != nil {
+				log.WithError(err).Error("Failed to insert outbox event")
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	*order = updated
 	return order, nil
 }
 
-func (rp *OrderRepo) Create(order *models.Order) (*models.Order, error) {
-	query := "INSERT INTO orders (origin_lat, origin_lng, destination_lat, destination_lng, distance, status) VALUES (?, ?, ?, ?, ?, ?)"
+// Create inserts the order at version 1 with a new public id, together with its OrderPlaced event in the outbox
+// in one transaction
+func (rp *OrderRepo) Create(ctx context.Context, order *models.Order) (*models.Order, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/order", "method": "Create"})
 
-	stmt, err := rp.Conn.Prepare(query)
-	if err != nil {
-		return nil, err
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return nil, models.ErrNoTenant
 	}
 
-	result, err := stmt.Exec(
-		order.Origins[0],
-		order.Origins[1],
-		order.Destinations[0],
-		order.Destinations[1],
-		order.Distance,
-		order.Status)
+	query := rp.Dialect.insertQuery(insertOrderQuery)
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
 
+	stmt, err := rp.stmt(ctx, query)
 	if err != nil {
+		log.WithError(err).Error("Failed to prepare statement")
 		return nil, err
 	}
 
-	id, err := result.LastInsertId()
+	createdAt := now()
+	publicId := newPublicId(createdAt)
+
+	err = rp.write(ctx, log, func(tx *sql.Tx) error {
+		id, err := rp.Dialect.insert(
+			ctx,
+			tx.StmtContext(ctx, stmt),
+			publicId,
+			merchantId,
+			order.Origins[0],
+			order.Origins[1],
+			order.Destinations[0],
+			order.Destinations[1],
+			order.Distance,
+			order.Status,
+			createdAt,
+			createdAt)
+
+		if err != nil {
+			rp.checkStmt(query, err)
+			log.WithError(err).Error("Failed to insert order")
+			return err
+		}
+
+		order.Id = id
+		order.PublicId = publicId
+		order.MerchantId = merchantId
+		order.CreatedAt = createdAt
+		order.UpdatedAt = createdAt
+		order.Version = 1
+
+		if err := insertEvent(ctx, tx, rp.Dialect, models.EventTypeOrderPlaced, order); err != nil {
+			log.WithError(err).Error("Failed to insert outbox event")
+			return err
+		}
+
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	order.Id = id
 	return order, nil
 }
 
-func (rp *OrderRepo) Delete(id int64) (bool, error) {
-	query := "DELETE FROM orders WHERE id = ?"
+func (rp *OrderRepo) Delete(ctx context.Context, id int64) (bool, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/order", "method": "Delete", "order_id": id})
+
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return false, models.ErrNoTenant
+	}
+
+	query := rp.Dialect.Rebind(deleteOrderQuery)
 
-	stmt, err := rp.Conn.Prepare(query)
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	stmt, err := rp.stmt(ctx, query)
 	if err != nil {
+		log.WithError(err).Error("Failed to prepare statement")
 		return false, err
 	}
 
-	result, err := stmt.Exec(id)
+	var result sql.Result
+	if rp.tx != nil {
+		result, err = rp.tx.StmtContext(ctx, stmt).ExecContext(ctx, id, merchantId)
+	} else if inTx(ctx) {
+		return false, models.ErrNestedTx
+	} else {
+		result, err = stmt.ExecContext(ctx, id, merchantId)
+	}
 	if err != nil {
+		rp.checkStmt(query, err)
+		log.WithError(err).Error("Failed to delete order")
 		return false, err
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
+		log.WithError(err).Error("Failed to get affected rows")
 		return false, err
 	}
 
 	return rowsAffected == 1, nil
 }
 
-func (rp *OrderRepo) List(offset, limit int) ([]models.Order, error) {
-	query := "SELECT id, distance, status FROM orders ORDER BY id ASC LIMIT ?, ?"
+func (rp *OrderRepo) List(ctx context.Context, offset, limit int) ([]models.Order, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/order", "method": "List", "offset": offset, "limit": limit})
+
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return nil, models.ErrNoTenant
+	}
+
+	from, args := "orders WHERE merchant_id = ? AND deleted_at IS NULL", []interface{}{merchantId}
+	if archive.Included(ctx) {
+		from = "(SELECT " + orderColumns + " FROM orders WHERE merchant_id = ? AND deleted_at IS NULL" +
+			" UNION ALL SELECT " + orderColumns + " FROM orders_archive WHERE merchant_id = ? AND deleted_at IS NULL) orders"
+		args = append(args, merchantId)
+	}
 
-	orders, err := rp.fetch(query, offset, limit)
+	limitClause, limitArgs := rp.Dialect.limit(offset, limit)
+	query := "SELECT " + orderColumns + " FROM " + from + " ORDER BY id ASC " + limitClause
+
+	orders, err := rp.fetch(ctx, query, append(args, limitArgs...)...)
 	if err != nil {
+		log.WithError(err).Error("Failed to query orders")
 		return nil, err
 	}
 
 	return orders, nil
 }
+
+// Archive moves up to limit orders of all merchants placed before before, that are deleted or have one of
+// ArchivedStatuses, from orders to orders_archive and returns how many were moved. The batch is picked
+// without locks, then moved in a transaction locking only its rows by primary key, callers archive more
+// orders by calling Archive again
+func (rp *OrderRepo) Archive(ctx context.Context, before time.Time, limit int) (int, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/order", "method": "Archive", "before": before, "limit": limit})
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	statuses := make([]interface{}, 0, len(ArchivedStatuses))
+	for _, status := range ArchivedStatuses {
+		statuses = append(statuses, status)
+	}
+	archivable := "(status IN (" + placeholders(len(ArchivedStatuses)) + ") OR deleted_at IS NOT NULL)"
+
+	// a locking read of the created_at range would lock every row it scans, orders being placed included
+	query := "SELECT id FROM orders WHERE " + archivable + " AND created_at < ? ORDER BY created_at LIMIT ?"
+	rows, err := queryContext(ctx, rp.Conn, rp.Dialect.Rebind(query), append(statuses, before.UTC(), limit)...)
+	if err != nil {
+		log.WithError(err).Error("Failed to select orders to archive")
+		return 0, err
+	}
+	candidates, err := scanIds(rows)
+	if err != nil {
+		log.WithError(err).Error("Failed to select orders to archive")
+		return 0, err
+	}
+
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+
+	tx, err := rp.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		log.WithError(err).Error("Failed to begin transaction")
+		return 0, err
+	}
+	// no-op once committed
+	defer tx.Rollback()
+
+	// the orders are checked again as they may have been deleted or archived since
+	query = "SELECT id FROM orders WHERE id IN (" + placeholders(len(candidates)) + ") AND " + archivable + rp.Dialect.forUpdate()
+	rows, err = tx.QueryContext(ctx, rp.Dialect.Rebind(query), append(candidates, statuses...)...)
+	if err != nil {
+		log.WithError(err).Error("Failed to lock orders to archive")
+		return 0, err
+	}
+	ids, err := scanIds(rows)
+	if err != nil {
+		log.WithError(err).Error("Failed to lock orders to archive")
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	in := "id IN (" + placeholders(len(ids)) + ")"
+
+	query = "INSERT INTO orders_archive (" + archivedColumns + ") SELECT " + archivedColumns + " FROM orders WHERE " + in
+	if _, err := tx.ExecContext(ctx, rp.Dialect.Rebind(query), ids...); err != nil {
+		log.WithError(err).Error("Failed to copy orders to the archive")
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, rp.Dialect.Rebind("DELETE FROM orders WHERE "+in), ids...); err != nil {
+		log.WithError(err).Error("Failed to delete archived orders")
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithError(err).Error("Failed to commit transaction")
+		return 0, err
+	}
+
+	return len(ids), nil
+}
+
+// scanIds reads the ids rows returns, as arguments of a following query, and closes rows
+func scanIds(rows *sql.Rows) ([]interface{}, error) {
+	defer rows.Close()
+
+	var ids []interface{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// AssignPublicIds gives a public id to up to limit orders of each of the orders and orders_archive tables
+// placed before public ids existed, and returns how many got one. The ids are generated from the time the
+// orders were placed so they sort like the orders placed since
+func (rp *OrderRepo) AssignPublicIds(ctx context.Context, limit int) (int, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/order", "method": "AssignPublicIds", "limit": limit})
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	assigned := 0
+	for _, table := range []string{"orders", "orders_archive"} {
+		rows, err := rp.Conn.QueryContext(ctx, rp.Dialect.Rebind("SELECT id, created_at FROM "+table+" WHERE public_id IS NULL ORDER BY id LIMIT ?"), limit)
+		if err != nil {
+			log.WithError(err).Error("Failed to select orders without public id")
+			return assigned, err
+		}
+
+		var ids []int64
+		var createdAts []time.Time
+		for rows.Next() {
+			var id int64
+			var createdAt time.Time
+			if err := rows.Scan(&id, &createdAt); err != nil {
+				rows.Close()
+				log.WithError(err).Error("Failed to select orders without public id")
+				return assigned, err
+			}
+			ids = append(ids, id)
+			createdAts = append(createdAts, createdAt)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			log.WithError(err).Error("Failed to select orders without public id")
+			return assigned, err
+		}
+
+		query := rp.Dialect.Rebind("UPDATE " + table + " SET public_id = ? WHERE id = ? AND public_id IS NULL")
+		for i, id := range ids {
+			res, err := rp.Conn.ExecContext(ctx, query, newPublicId(createdAts[i]), id)
+			if err != nil {
+				log.WithError(err).WithField("order_id", id).Error("Failed to assign public id")
+				return assigned, err
+			}
+			// the order was given one concurrently otherwise
+			if n, err := res.RowsAffected(); err == nil && n == 1 {
+				assigned++
+			}
+		}
+	}
+
+	return assigned, nil
+}
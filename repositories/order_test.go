@@ -1,136 +1,630 @@
 package repositories
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"testing"
+	"time"
 
+	"order-service/archive"
 	"order-service/models"
+	"order-service/replica"
+	"order-service/tenant"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
+// merchantCtx is scoped to the merchant owning the records of the tests
+var merchantCtx = tenantCtx("merchant-1")
+
+// placedAt is the time of the orders of the tests
+var placedAt = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// fixNow makes the writes of the test happen at placedAt
+func fixNow(t *testing.T) {
+	previous := now
+	now = func() time.Time { return placedAt }
+	t.Cleanup(func() { now = previous })
+}
+
+// publicId is the public id given to the orders created by the tests
+const publicId = "01KDZ3J0K8A7Z4W9X2M5N6P7Q8"
+
+// fixPublicId makes the orders created by the test get publicId
+func fixPublicId(t *testing.T) {
+	previous := newPublicId
+	newPublicId = func(time.Time) string { return publicId }
+	t.Cleanup(func() { newPublicId = previous })
+}
+
+func tenantCtx(merchantId string) context.Context {
+	return tenant.WithMerchantId(context.Background(), merchantId)
+}
+
 func TestOrderRepo_List(t *testing.T) {
-	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-	if err != nil {
-		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "distance", "status"}).
-		AddRow(1, 100, "UNASSIGNED").
-		AddRow(2, 200, "UNASSIGNED")
+		rows := sqlmock.NewRows([]string{"id", "public_id", "merchant_id", "distance", "status", "created_at", "updated_at", "version"}).
+			AddRow(1, publicId, "merchant-1", 100, "UNASSIGNED", placedAt, placedAt, 1).
+			AddRow(2, nil, "merchant-1", 200, "UNASSIGNED", placedAt, placedAt, 1)
 
-	query := "SELECT id, distance, status FROM orders ORDER BY id ASC LIMIT ?, ?"
+		if b.postgres {
+			mock.ExpectQuery("SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE merchant_id = $1 AND deleted_at IS NULL ORDER BY id ASC LIMIT $2 OFFSET $3").
+				WithArgs("merchant-1", 10, 0).
+				WillReturnRows(rows)
+		} else {
+			mock.ExpectQuery("SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE merchant_id = ? AND deleted_at IS NULL ORDER BY id ASC LIMIT ?, ?").
+				WithArgs("merchant-1", 0, 10).
+				WillReturnRows(rows)
+		}
 
-	mock.ExpectQuery(query).
-		WithArgs(0, 10).
-		WillReturnRows(rows)
+		orderRepo := b.orderRepo(db, time.Second)
+		orders, err := orderRepo.List(merchantCtx, 0, 10)
+		assert.NoError(t, err)
+		assert.NotNil(t, orders)
+		if assert.Equal(t, 2, len(orders)) {
+			assert.Equal(t, publicId, orders[0].PublicId)
+			// the order has no public id until it is assigned one
+			assert.Equal(t, "", orders[1].PublicId)
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
 
-	orderRepo := NewMysqlOrderRepo(db)
-	orders, err := orderRepo.List(0, 10)
-	assert.NoError(t, err)
-	assert.NotNil(t, orders)
-	assert.Equal(t, 2, len(orders))
+func TestOrderRepo_List_Timeout(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		rows := sqlmock.NewRows([]string{"id", "public_id", "merchant_id", "distance", "status", "created_at", "updated_at", "version"}).
+			AddRow(1, publicId, "merchant-1", 100, "UNASSIGNED", placedAt, placedAt, 1)
+
+		if b.postgres {
+			mock.ExpectQuery("SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE merchant_id = $1 AND deleted_at IS NULL ORDER BY id ASC LIMIT $2 OFFSET $3").
+				WithArgs("merchant-1", 10, 0).
+				WillDelayFor(time.Second).
+				WillReturnRows(rows)
+		} else {
+			mock.ExpectQuery("SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE merchant_id = ? AND deleted_at IS NULL ORDER BY id ASC LIMIT ?, ?").
+				WithArgs("merchant-1", 0, 10).
+				WillDelayFor(time.Second).
+				WillReturnRows(rows)
+		}
+
+		orderRepo := b.orderRepo(db, 10*time.Millisecond)
+		orders, err := orderRepo.List(merchantCtx, 0, 10)
+		assert.Error(t, err)
+		assert.Nil(t, orders)
+	})
 }
 
 func TestOrderRepo_GetById(t *testing.T) {
-	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-	if err != nil {
-		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
 
-	rows := sqlmock.NewRows([]string{
-		"id",
-		"distance",
-		"status"}).AddRow(
-		1,
-		100,
-		"UNASSIGNED")
+		rows := sqlmock.NewRows([]string{
+			"id",
+			"public_id",
+			"merchant_id",
+			"distance",
+			"status",
+			"created_at",
+			"updated_at",
+			"version"}).AddRow(
+			1,
+			publicId,
+			"merchant-1",
+			100,
+			"UNASSIGNED",
+			placedAt,
+			placedAt,
+			1)
 
-	query := "SELECT id, distance, status FROM orders WHERE id = ?"
+		query := b.q(
+			"SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL",
+			"SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE id = $1 AND merchant_id = $2 AND deleted_at IS NULL")
 
-	mock.ExpectQuery(query).
-		WithArgs(1).
-		WillReturnRows(rows)
+		mock.ExpectQuery(query).
+			WithArgs(1, "merchant-1").
+			WillReturnRows(rows)
 
-	orderRepo := NewMysqlOrderRepo(db)
-	order, err := orderRepo.GetById(1)
-	assert.NoError(t, err)
-	assert.NotNil(t, order)
+		orderRepo := b.orderRepo(db, time.Second)
+		order, err := orderRepo.GetById(merchantCtx, 1)
+		assert.NoError(t, err)
+		assert.NotNil(t, order)
+		assert.Equal(t, publicId, order.PublicId)
+		assert.Equal(t, "merchant-1", order.MerchantId)
+		assert.Equal(t, placedAt, order.CreatedAt)
+		assert.Equal(t, placedAt, order.UpdatedAt)
+		assert.Equal(t, int64(1), order.Version)
+	})
+}
+
+func TestOrderRepo_GetById_OtherMerchant(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		query := b.q(
+			"SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL",
+			"SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE id = $1 AND merchant_id = $2 AND deleted_at IS NULL")
+
+		// order 1 belongs to merchant-1, so it is not found for merchant-2
+		mock.ExpectQuery(query).
+			WithArgs(1, "merchant-2").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "public_id", "merchant_id", "distance", "status", "created_at", "updated_at", "version"}))
+
+		orderRepo := b.orderRepo(db, time.Second)
+		order, err := orderRepo.GetById(tenantCtx("merchant-2"), 1)
+		assert.Equal(t, models.ErrNotFound, err)
+		assert.Nil(t, order)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestOrderRepo_NoMerchant(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		orderRepo := b.orderRepo(db, time.Second)
+
+		_, err = orderRepo.GetById(context.Background(), 1)
+		assert.Equal(t, models.ErrNoTenant, err)
+
+		_, err = orderRepo.List(context.Background(), 0, 10)
+		assert.Equal(t, models.ErrNoTenant, err)
+
+		_, err = orderRepo.Create(context.Background(), &models.Order{})
+		assert.Equal(t, models.ErrNoTenant, err)
+
+		_, err = orderRepo.Update(context.Background(), &models.Order{Id: 1, Status: models.StatusTaken}, models.StatusUnassigned)
+		assert.Equal(t, models.ErrNoTenant, err)
+
+		_, err = orderRepo.Delete(context.Background(), 1)
+		assert.Equal(t, models.ErrNoTenant, err)
+
+		// no query must reach the database without a merchant
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
 
 func TestOrderRepo_Create(t *testing.T) {
-	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-	if err != nil {
-		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
+	fixNow(t)
+	fixPublicId(t)
 
-	o := &models.Order{
-		Origins:      []float64{22.780247, 113.687473},
-		Destinations: []float64{22.217851, 114.207989},
-		Distance:     100,
-		Status:       models.StatusUnassigned,
-	}
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
 
-	query := `INSERT INTO orders (origin_lat, origin_lng, destination_lat, destination_lng, distance, status) VALUES (?, ?, ?, ?, ?, ?)`
+		o := &models.Order{
+			Origins:      []float64{22.780247, 113.687473},
+			Destinations: []float64{22.217851, 114.207989},
+			Distance:     100,
+			Status:       models.StatusUnassigned,
+		}
 
-	prep := mock.ExpectPrepare(query)
-	prep.ExpectExec().
-		WithArgs(o.Origins[0], o.Origins[1], o.Destinations[0], o.Destinations[1], o.Distance, o.Status).
-		WillReturnResult(sqlmock.NewResult(123, 1))
+		if b.postgres {
+			prep := mock.ExpectPrepare(`INSERT INTO orders (public_id, merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, distance, status, created_at, updated_at, version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 1) RETURNING id`)
+			mock.ExpectBegin()
+			prep.ExpectQuery().
+				WithArgs(publicId, "merchant-1", o.Origins[0], o.Origins[1], o.Destinations[0], o.Destinations[1], o.Distance, o.Status, placedAt, placedAt).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(123))
+		} else {
+			prep := mock.ExpectPrepare(`INSERT INTO orders (public_id, merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, distance, status, created_at, updated_at, version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)`)
+			mock.ExpectBegin()
+			prep.ExpectExec().
+				WithArgs(publicId, "merchant-1", o.Origins[0], o.Origins[1], o.Destinations[0], o.Destinations[1], o.Distance, o.Status, placedAt, placedAt).
+				WillReturnResult(sqlmock.NewResult(123, 1))
+		}
+		mock.ExpectExec(b.q(
+			"INSERT INTO outbox (event_type, order_id, merchant_id, payload) VALUES (?, ?, ?, ?)",
+			"INSERT INTO outbox (event_type, order_id, merchant_id, payload) VALUES ($1, $2, $3, $4)")).
+			WithArgs(models.EventTypeOrderPlaced, 123, "merchant-1", []byte(`{"id":"`+publicId+`","distance":100,"status":"UNASSIGNED","created_at":"2026-01-02T03:04:05Z","updated_at":"2026-01-02T03:04:05Z","version":1}`)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 
-	orderRepo := NewMysqlOrderRepo(db)
-	order, err := orderRepo.Create(o)
-	assert.NoError(t, err)
-	assert.NotNil(t, order)
-	assert.Equal(t, int64(123), order.Id)
+		orderRepo := b.orderRepo(db, time.Second)
+		order, err := orderRepo.Create(merchantCtx, o)
+		assert.NoError(t, err)
+		assert.NotNil(t, order)
+		assert.Equal(t, int64(123), order.Id)
+		assert.Equal(t, publicId, order.PublicId)
+		assert.Equal(t, "merchant-1", order.MerchantId)
+		assert.Equal(t, placedAt, order.CreatedAt)
+		assert.Equal(t, placedAt, order.UpdatedAt)
+		assert.Equal(t, int64(1), order.Version)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestOrderRepo_Create_OutboxFailed(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		o := &models.Order{
+			Origins:      []float64{22.780247, 113.687473},
+			Destinations: []float64{22.217851, 114.207989},
+			Distance:     100,
+			Status:       models.StatusUnassigned,
+		}
+
+		if b.postgres {
+			prep := mock.ExpectPrepare(`INSERT INTO orders (public_id, merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, distance, status, created_at, updated_at, version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 1) RETURNING id`)
+			mock.ExpectBegin()
+			prep.ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(123))
+		} else {
+			prep := mock.ExpectPrepare(`INSERT INTO orders (public_id, merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, distance, status, created_at, updated_at, version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)`)
+			mock.ExpectBegin()
+			prep.ExpectExec().WillReturnResult(sqlmock.NewResult(123, 1))
+		}
+		mock.ExpectExec(b.q(
+			"INSERT INTO outbox (event_type, order_id, merchant_id, payload) VALUES (?, ?, ?, ?)",
+			"INSERT INTO outbox (event_type, order_id, merchant_id, payload) VALUES ($1, $2, $3, $4)")).
+			WillReturnError(errors.New("exception"))
+		// the order must not be committed without its event
+		mock.ExpectRollback()
+
+		orderRepo := b.orderRepo(db, time.Second)
+		order, err := orderRepo.Create(merchantCtx, o)
+		assert.Error(t, err)
+		assert.Nil(t, order)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
 
 func TestOrderRepo_Update(t *testing.T) {
-	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-	if err != nil {
-		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
+	fixNow(t)
 
-	o := &models.Order{
-		Origins:      []float64{22.780247, 113.687473},
-		Destinations: []float64{22.217851, 114.207989},
-		Distance:     100,
-		Status:       models.StatusTaken,
-	}
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
 
-	query := "UPDATE orders SET status = ? where id = ? AND status = ?"
+		o := &models.Order{
+			Origins:      []float64{22.780247, 113.687473},
+			Destinations: []float64{22.217851, 114.207989},
+			Distance:     100,
+			Status:       models.StatusTaken,
+		}
 
-	prep := mock.ExpectPrepare(query)
-	prep.ExpectExec().
-		WithArgs(models.StatusTaken, o.Id, models.StatusUnassigned).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		query := b.q(
+			"UPDATE orders SET status = ?, updated_at = ?, version = version + 1 where id = ? AND merchant_id = ? AND status = ? AND deleted_at IS NULL",
+			"UPDATE orders SET status = $1, updated_at = $2, version = version + 1 where id = $3 AND merchant_id = $4 AND status = $5 AND deleted_at IS NULL")
 
-	orderRepo := NewMysqlOrderRepo(db)
-	order, err := orderRepo.Update(o, models.StatusUnassigned)
-	assert.NoError(t, err)
-	assert.NotNil(t, order)
+		// the statement is prepared once, before the first transaction
+		prep := mock.ExpectPrepare(query)
+		mock.ExpectBegin()
+		prep.ExpectExec().
+			WithArgs(models.StatusTaken, placedAt, o.Id, "merchant-1", models.StatusUnassigned).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(b.q(
+			"INSERT INTO outbox (event_type, order_id, merchant_id, payload) VALUES (?, ?, ?, ?)",
+			"INSERT INTO outbox (event_type, order_id, merchant_id, payload) VALUES ($1, $2, $3, $4)")).
+			WithArgs(models.EventTypeOrderTaken, o.Id, "merchant-1", []byte(`{"id":"0","distance":100,"status":"TAKEN","created_at":"0001-01-01T00:00:00Z","updated_at":"2026-01-02T03:04:05Z","version":0}`)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		// the order belongs to merchant-1, so merchant-2 cannot take it and no event is written
+		mock.ExpectBegin()
+		prep.ExpectExec().
+			WithArgs(models.StatusTaken, placedAt, o.Id, "merchant-2", models.StatusUnassigned).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		orderRepo := b.orderRepo(db, time.Second)
+		order, err := orderRepo.Update(merchantCtx, o, models.StatusUnassigned)
+		assert.NoError(t, err)
+		assert.NotNil(t, order)
+
+		order, err = orderRepo.Update(tenantCtx("merchant-2"), o, models.StatusUnassigned)
+		assert.Equal(t, models.ErrCannotUpdate, err)
+		assert.Nil(t, order)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestOrderRepo_Update_Version(t *testing.T) {
+	fixNow(t)
+
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		o := &models.Order{Id: 1, Distance: 100, Status: models.StatusTaken, CreatedAt: placedAt, UpdatedAt: placedAt, Version: 3}
+
+		prep := mock.ExpectPrepare(b.q(
+			"UPDATE orders SET status = ?, updated_at = ?, version = version + 1 where id = ? AND merchant_id = ? AND status = ? AND deleted_at IS NULL AND version = ?",
+			"UPDATE orders SET status = $1, updated_at = $2, version = version + 1 where id = $3 AND merchant_id = $4 AND status = $5 AND deleted_at IS NULL AND version = $6"))
+		mock.ExpectBegin()
+		prep.ExpectExec().
+			WithArgs(models.StatusTaken, placedAt, 1, "merchant-1", models.StatusUnassigned, 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		// the event carries the order as updated
+		mock.ExpectExec(b.q(
+			"INSERT INTO outbox (event_type, order_id, merchant_id, payload) VALUES (?, ?, ?, ?)",
+			"INSERT INTO outbox (event_type, order_id, merchant_id, payload) VALUES ($1, $2, $3, $4)")).
+			WithArgs(models.EventTypeOrderTaken, 1, "merchant-1", []byte(`{"id":"1","distance":100,"status":"TAKEN","created_at":"2026-01-02T03:04:05Z","updated_at":"2026-01-02T03:04:05Z","version":4}`)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		// the order changed since it was read at version 3
+		mock.ExpectBegin()
+		prep.ExpectExec().
+			WithArgs(models.StatusTaken, placedAt, 1, "merchant-1", models.StatusUnassigned, 3).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		orderRepo := b.orderRepo(db, time.Second)
+		order, err := orderRepo.Update(merchantCtx, o, models.StatusUnassigned)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), order.Version)
+
+		stale := &models.Order{Id: 1, Distance: 100, Status: models.StatusTaken, Version: 3}
+		order, err = orderRepo.Update(merchantCtx, stale, models.StatusUnassigned)
+		assert.Equal(t, models.ErrCannotUpdate, err)
+		assert.Nil(t, order)
+		assert.Equal(t, int64(3), stale.Version)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
 
 func TestOrderRepo_Delete(t *testing.T) {
-	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-	if err != nil {
-		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		query := b.q(
+			"UPDATE orders SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL",
+			"UPDATE orders SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND merchant_id = $2 AND deleted_at IS NULL")
+
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectExec().
+			WithArgs(1, "merchant-1").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		orderRepo := b.orderRepo(db, time.Second)
+		order, err := orderRepo.Delete(merchantCtx, 1)
+		assert.NoError(t, err)
+		assert.NotNil(t, order)
+	})
+}
+
+func TestOrderRepo_Replicas(t *testing.T) {
+	newMock := func() (*sql.DB, sqlmock.Sqlmock) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db, mock
+	}
+	expectGet := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery("SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL").
+			WithArgs(1, "merchant-1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "public_id", "merchant_id", "distance", "status", "created_at", "updated_at", "version"}).AddRow(1, publicId, "merchant-1", 100, "UNASSIGNED", placedAt, placedAt, 1))
 	}
-	defer db.Close()
 
-	query := "DELETE FROM orders WHERE id = ?"
+	primary, primaryMock := newMock()
+	first, firstMock := newMock()
+	second, secondMock := newMock()
 
-	prep := mock.ExpectPrepare(query)
-	prep.ExpectExec().
-		WithArgs(1).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	// reads take turns on the replicas, unless they must see the writes of the primary
+	expectGet(firstMock)
+	expectGet(secondMock)
+	expectGet(firstMock)
+	expectGet(primaryMock)
+	primaryMock.ExpectPrepare("UPDATE orders SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL").
+		ExpectExec().
+		WithArgs(1, "merchant-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	orderRepo := NewMysqlOrderRepo(primary, time.Second)
+	orderRepo.Replicas = []*sql.DB{first, second}
+
+	for i := 0; i < 3; i++ {
+		_, err := orderRepo.GetById(merchantCtx, 1)
+		assert.NoError(t, err)
+	}
 
-	orderRepo := NewMysqlOrderRepo(db)
-	order, err := orderRepo.Delete(1)
+	_, err := orderRepo.GetById(replica.WithPrimary(merchantCtx), 1)
 	assert.NoError(t, err)
-	assert.NotNil(t, order)
+
+	deleted, err := orderRepo.Delete(merchantCtx, 1)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, firstMock.ExpectationsWereMet())
+	assert.NoError(t, secondMock.ExpectationsWereMet())
+}
+
+func TestOrderRepo_PreparedStatements(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		update := b.q(
+			"UPDATE orders SET status = ?, updated_at = ?, version = version + 1 where id = ? AND merchant_id = ? AND status = ? AND deleted_at IS NULL",
+			"UPDATE orders SET status = $1, updated_at = $2, version = version + 1 where id = $3 AND merchant_id = $4 AND status = $5 AND deleted_at IS NULL")
+		updateVersion := b.q(
+			"UPDATE orders SET status = ?, updated_at = ?, version = version + 1 where id = ? AND merchant_id = ? AND status = ? AND deleted_at IS NULL AND version = ?",
+			"UPDATE orders SET status = $1, updated_at = $2, version = version + 1 where id = $3 AND merchant_id = $4 AND status = $5 AND deleted_at IS NULL AND version = $6")
+		insert := b.q(
+			"INSERT INTO orders (public_id, merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, distance, status, created_at, updated_at, version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)",
+			"INSERT INTO orders (public_id, merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, distance, status, created_at, updated_at, version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 1) RETURNING id")
+		del := b.q(
+			"UPDATE orders SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL",
+			"UPDATE orders SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND merchant_id = $2 AND deleted_at IS NULL")
+
+		var unknownStmt error = &mysql.MySQLError{Number: mysqlErrUnknownStmtHandler}
+		if b.postgres {
+			unknownStmt = &pq.Error{Code: "26000"}
+		}
+
+		// every statement is prepared once up front and closed with the repository
+		mock.ExpectPrepare(update).WillBeClosed()
+		mock.ExpectPrepare(updateVersion).WillBeClosed()
+		mock.ExpectPrepare(insert).WillBeClosed()
+		prep := mock.ExpectPrepare(del).WillBeClosed()
+		prep.ExpectExec().WithArgs(1, "merchant-1").WillReturnResult(sqlmock.NewResult(0, 1))
+		prep.ExpectExec().WithArgs(2, "merchant-1").WillReturnResult(sqlmock.NewResult(0, 1))
+		// the server forgot the statement, it is prepared again on the next call
+		prep.ExpectExec().WithArgs(3, "merchant-1").WillReturnError(unknownStmt)
+		prep = mock.ExpectPrepare(del).WillBeClosed()
+		prep.ExpectExec().WithArgs(4, "merchant-1").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		orderRepo := b.orderRepo(db, time.Second)
+		assert.NoError(t, orderRepo.Prepare(context.Background()))
+
+		for id := int64(1); id <= 2; id++ {
+			deleted, err := orderRepo.Delete(merchantCtx, id)
+			assert.NoError(t, err)
+			assert.True(t, deleted)
+		}
+
+		_, err = orderRepo.Delete(merchantCtx, 3)
+		assert.Equal(t, unknownStmt, err)
+
+		deleted, err := orderRepo.Delete(merchantCtx, 4)
+		assert.NoError(t, err)
+		assert.True(t, deleted)
+
+		assert.NoError(t, orderRepo.Close())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestOrderRepo_Archive(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		before := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+		forUpdate := " FOR UPDATE"
+		if b.name == "sqlite" {
+			forUpdate = ""
+		}
+
+		mock.ExpectQuery(b.q(
+			"SELECT id FROM orders WHERE (status IN (?, ?) OR deleted_at IS NOT NULL) AND created_at < ? ORDER BY created_at LIMIT ?",
+			"SELECT id FROM orders WHERE (status IN ($1, $2) OR deleted_at IS NOT NULL) AND created_at < $3 ORDER BY created_at LIMIT $4")).
+			WithArgs(models.StatusDelivered, models.StatusCancelled, before, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(4).AddRow(5))
+		// order 4 was archived by another instance in between, only the others are locked
+		mock.ExpectBegin()
+		mock.ExpectQuery(b.q(
+			"SELECT id FROM orders WHERE id IN (?, ?, ?) AND (status IN (?, ?) OR deleted_at IS NOT NULL)"+forUpdate,
+			"SELECT id FROM orders WHERE id IN ($1, $2, $3) AND (status IN ($4, $5) OR deleted_at IS NOT NULL) FOR UPDATE")).
+			WithArgs(3, 4, 5, models.StatusDelivered, models.StatusCancelled).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(5))
+		mock.ExpectExec(b.q(
+			"INSERT INTO orders_archive (id, public_id, merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, status, distance, created_at, updated_at, version, deleted_at) SELECT id, public_id, merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, status, distance, created_at, updated_at, version, deleted_at FROM orders WHERE id IN (?, ?)",
+			"INSERT INTO orders_archive (id, public_id, merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, status, distance, created_at, updated_at, version, deleted_at) SELECT id, public_id, merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, status, distance, created_at, updated_at, version, deleted_at FROM orders WHERE id IN ($1, $2)")).
+			WithArgs(3, 5).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(b.q("DELETE FROM orders WHERE id IN (?, ?)", "DELETE FROM orders WHERE id IN ($1, $2)")).
+			WithArgs(3, 5).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		// nothing left to archive, no transaction is begun
+		mock.ExpectQuery(b.q(
+			"SELECT id FROM orders WHERE (status IN (?, ?) OR deleted_at IS NOT NULL) AND created_at < ? ORDER BY created_at LIMIT ?",
+			"SELECT id FROM orders WHERE (status IN ($1, $2) OR deleted_at IS NOT NULL) AND created_at < $3 ORDER BY created_at LIMIT $4")).
+			WithArgs(models.StatusDelivered, models.StatusCancelled, before, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		orderRepo := b.orderRepo(db, time.Second)
+		n, err := orderRepo.Archive(context.Background(), before, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		n, err = orderRepo.Archive(context.Background(), before, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestOrderRepo_IncludeArchived(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		columns := []string{"id", "public_id", "merchant_id", "distance", "status", "created_at", "updated_at", "version"}
+		mock.ExpectQuery(b.q(
+			"SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL UNION ALL SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders_archive WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL",
+			"SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE id = $1 AND merchant_id = $2 AND deleted_at IS NULL UNION ALL SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders_archive WHERE id = $3 AND merchant_id = $4 AND deleted_at IS NULL")).
+			WithArgs(1, "merchant-1", 1, "merchant-1").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(1, publicId, "merchant-1", 100, models.StatusTaken, placedAt, placedAt, 1))
+
+		if b.postgres {
+			mock.ExpectQuery("SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM (SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE merchant_id = $1 AND deleted_at IS NULL UNION ALL SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders_archive WHERE merchant_id = $2 AND deleted_at IS NULL) orders ORDER BY id ASC LIMIT $3 OFFSET $4").
+				WithArgs("merchant-1", "merchant-1", 10, 0).
+				WillReturnRows(sqlmock.NewRows(columns).AddRow(1, publicId, "merchant-1", 100, models.StatusTaken, placedAt, placedAt, 1))
+		} else {
+			mock.ExpectQuery("SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM (SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE merchant_id = ? AND deleted_at IS NULL UNION ALL SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders_archive WHERE merchant_id = ? AND deleted_at IS NULL) orders ORDER BY id ASC LIMIT ?, ?").
+				WithArgs("merchant-1", "merchant-1", 0, 10).
+				WillReturnRows(sqlmock.NewRows(columns).AddRow(1, publicId, "merchant-1", 100, models.StatusTaken, placedAt, placedAt, 1))
+		}
+
+		ctx := archive.WithArchived(merchantCtx)
+		orderRepo := b.orderRepo(db, time.Second)
+
+		order, err := orderRepo.GetById(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, models.StatusTaken, order.Status)
+
+		orders, err := orderRepo.List(ctx, 0, 10)
+		assert.NoError(t, err)
+		assert.Len(t, orders, 1)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
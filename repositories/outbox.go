@@ -0,0 +1,194 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"order-service/logger"
+	"order-service/models"
+	"order-service/tenant"
+
+	"github.com/sirupsen/logrus"
+)
+
+// insertEvent writes an event about order to the outbox within tx, so the event is recorded
+// if and only if the change of the order is committed
+func insertEvent(ctx context.Context, tx *sql.Tx, dialect Dialect, eventType string, order *models.Order) error {
+	event, err := models.NewOrderEvent(eventType, order)
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO outbox (event_type, order_id, merchant_id, payload) VALUES (?, ?, ?, ?)"
+
+	_, err = tx.ExecContext(ctx, dialect.Rebind(query), event.Type, event.OrderId, event.MerchantId, []byte(event.Payload))
+	return err
+}
+
+// OutboxRepo reads the events written to the outbox by the other repositories. ListPending and MarkSent
+// serve the relay and are not scoped to a merchant, the other methods are
+type OutboxRepo struct {
+	Conn    *sql.DB
+	Timeout time.Duration
+	Dialect Dialect
+}
+
+// NewMysqlOutboxRepo will create an OutboxRepo on conn, every query is cancelled after timeout unless timeout is zero
+func NewMysqlOutboxRepo(conn *sql.DB, timeout time.Duration) *OutboxRepo {
+	return &OutboxRepo{conn, timeout, MySQL}
+}
+
+// NewSqliteOutboxRepo will create an OutboxRepo on a SQLite conn, every query is cancelled after timeout unless timeout is zero
+func NewSqliteOutboxRepo(conn *sql.DB, timeout time.Duration) *OutboxRepo {
+	return &OutboxRepo{conn, timeout, SQLite}
+}
+
+// NewPostgresOutboxRepo will create an OutboxRepo on a Postgres conn, the outbox lives in the database
+// of the orders since events are written in their transactions
+func NewPostgresOutboxRepo(conn *sql.DB, timeout time.Duration) *OutboxRepo {
+	return &OutboxRepo{conn, timeout, Postgres}
+}
+
+func (rp *OutboxRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if rp.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, rp.Timeout)
+}
+
+// ListPending returns the events not published yet, oldest first
+func (rp *OutboxRepo) ListPending(ctx context.Context, limit int) ([]models.Event, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/outbox", "method": "ListPending", "limit": limit})
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT id, event_type, order_id, merchant_id, payload, created_at FROM outbox WHERE sent_at IS NULL ORDER BY id ASC LIMIT ?"
+
+	rows, err := queryContext(ctx, rp.Conn, rp.Dialect.Rebind(query), limit)
+	if err != nil {
+		log.WithError(err).Error("Failed to query outbox")
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]models.Event, 0)
+
+	for rows.Next() {
+		event := models.Event{}
+		var payload []byte
+
+		err := rows.Scan(&event.Id, &event.Type, &event.OrderId, &event.MerchantId, &payload, &event.CreatedAt)
+		if err != nil {
+			log.WithError(err).Error("Failed to scan outbox event")
+			return nil, err
+		}
+
+		event.Payload = payload
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.WithError(err).Error("Failed to query outbox")
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// ListAfter returns up to limit of the merchant's events written after the event afterId, oldest first,
+// together with the origins of their orders. The order may have been archived since, the events of
+// deleted orders are left out
+func (rp *OutboxRepo) ListAfter(ctx context.Context, afterId int64, limit int) ([]models.Event, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/outbox", "method": "ListAfter", "after_id": afterId, "limit": limit})
+
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return nil, models.ErrNoTenant
+	}
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT e.id, e.event_type, e.order_id, e.merchant_id, e.payload, e.created_at, " +
+		"COALESCE(o.origin_lat, a.origin_lat), COALESCE(o.origin_lng, a.origin_lng) " +
+		"FROM outbox e LEFT JOIN orders o ON o.id = e.order_id LEFT JOIN orders_archive a ON a.id = e.order_id " +
+		"WHERE e.merchant_id = ? AND e.id > ? AND COALESCE(o.id, a.id) IS NOT NULL AND COALESCE(o.deleted_at, a.deleted_at) IS NULL " +
+		"ORDER BY e.id ASC LIMIT ?"
+
+	rows, err := queryContext(ctx, rp.Conn, rp.Dialect.Rebind(query), merchantId, afterId, limit)
+	if err != nil {
+		log.WithError(err).Error("Failed to query outbox")
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]models.Event, 0)
+
+	for rows.Next() {
+		event := models.Event{Origins: make([]float64, 2)}
+		var payload []byte
+
+		err := rows.Scan(&event.Id, &event.Type, &event.OrderId, &event.MerchantId, &payload, &event.CreatedAt, &event.Origins[0], &event.Origins[1])
+		if err != nil {
+			log.WithError(err).Error("Failed to scan outbox event")
+			return nil, err
+		}
+
+		event.Payload = payload
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.WithError(err).Error("Failed to query outbox")
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// LatestId returns the id of the merchant's latest event, 0 if there is none
+func (rp *OutboxRepo) LatestId(ctx context.Context) (int64, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/outbox", "method": "LatestId"})
+
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return 0, models.ErrNoTenant
+	}
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT COALESCE(MAX(id), 0) FROM outbox WHERE merchant_id = ?"
+
+	var id int64
+	err := retryRead(ctx, func() error {
+		return rp.Conn.QueryRowContext(ctx, rp.Dialect.Rebind(query), merchantId).Scan(&id)
+	})
+	if err != nil {
+		log.WithError(err).Error("Failed to query latest outbox event")
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// MarkSent records that the event was published, so it is not relayed again
+func (rp *OutboxRepo) MarkSent(ctx context.Context, id int64) error {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/outbox", "method": "MarkSent", "event_id": id})
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE outbox SET sent_at = ? WHERE id = ?"
+
+	_, err := rp.Conn.ExecContext(ctx, rp.Dialect.Rebind(query), time.Now(), id)
+	if err != nil {
+		log.WithError(err).Error("Failed to mark outbox event as sent")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"order-service/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxRepo_ListPending(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		query := b.q(
+			"SELECT id, event_type, order_id, merchant_id, payload, created_at FROM outbox WHERE sent_at IS NULL ORDER BY id ASC LIMIT ?",
+			"SELECT id, event_type, order_id, merchant_id, payload, created_at FROM outbox WHERE sent_at IS NULL ORDER BY id ASC LIMIT $1")
+
+		mock.ExpectQuery(query).
+			WithArgs(10).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "event_type", "order_id", "merchant_id", "payload", "created_at"}).
+				AddRow(1, "OrderPlaced", 1, "merchant-1", []byte(`{"id":1}`), time.Now()).
+				AddRow(2, "OrderTaken", 1, "merchant-1", []byte(`{"id":1}`), time.Now()))
+
+		repo := b.outboxRepo(db, time.Second)
+
+		events, err := repo.ListPending(context.Background(), 10)
+		assert.NoError(t, err)
+		if assert.Len(t, events, 2) {
+			assert.Equal(t, models.EventTypeOrderTaken, events[1].Type)
+			assert.Equal(t, "merchant-1", events[1].MerchantId)
+			assert.JSONEq(t, `{"id":1}`, string(events[1].Payload))
+		}
+	})
+}
+func TestOutboxRepo_MarkSent(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec(b.q("UPDATE outbox SET sent_at = ? WHERE id = ?", "UPDATE outbox SET sent_at = $1 WHERE id = $2")).
+			WithArgs(sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := b.outboxRepo(db, time.Second)
+
+		err = repo.MarkSent(context.Background(), 1)
+		assert.NoError(t, err)
+	})
+}
+func TestOutboxRepo_ListAfter(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		query := "SELECT e.id, e.event_type, e.order_id, e.merchant_id, e.payload, e.created_at, " +
+			"COALESCE(o.origin_lat, a.origin_lat), COALESCE(o.origin_lng, a.origin_lng) " +
+			"FROM outbox e LEFT JOIN orders o ON o.id = e.order_id LEFT JOIN orders_archive a ON a.id = e.order_id " +
+			b.q("WHERE e.merchant_id = ? AND e.id > ? AND COALESCE(o.id, a.id) IS NOT NULL AND COALESCE(o.deleted_at, a.deleted_at) IS NULL ORDER BY e.id ASC LIMIT ?",
+				"WHERE e.merchant_id = $1 AND e.id > $2 AND COALESCE(o.id, a.id) IS NOT NULL AND COALESCE(o.deleted_at, a.deleted_at) IS NULL ORDER BY e.id ASC LIMIT $3")
+
+		mock.ExpectQuery(query).
+			WithArgs("merchant-1", 5, 10).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "event_type", "order_id", "merchant_id", "payload", "created_at", "origin_lat", "origin_lng"}).
+				AddRow(6, "OrderPlaced", 1, "merchant-1", []byte(`{"id":1}`), time.Now(), 22.3193, 114.1694))
+
+		repo := b.outboxRepo(db, time.Second)
+
+		events, err := repo.ListAfter(merchantCtx, 5, 10)
+		assert.NoError(t, err)
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, int64(6), events[0].Id)
+			assert.Equal(t, []float64{22.3193, 114.1694}, events[0].Origins)
+		}
+	})
+}
+func TestOutboxRepo_ListAfter_NoTenant(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, _, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		repo := b.outboxRepo(db, time.Second)
+
+		_, err = repo.ListAfter(context.Background(), 0, 10)
+		assert.Equal(t, models.ErrNoTenant, err)
+	})
+}
+func TestOutboxRepo_LatestId(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(b.q("SELECT COALESCE(MAX(id), 0) FROM outbox WHERE merchant_id = ?", "SELECT COALESCE(MAX(id), 0) FROM outbox WHERE merchant_id = $1")).
+			WithArgs("merchant-1").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+
+		repo := b.outboxRepo(db, time.Second)
+
+		id, err := repo.LatestId(merchantCtx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(42), id)
+	})
+}
@@ -0,0 +1,14 @@
+package repositories
+
+import (
+	"crypto/rand"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// newPublicId returns the public id of an order placed at t, a ULID whose 80 random bits come from
+// crypto/rand so the id of an order cannot be guessed from the ids of other orders
+var newPublicId = func(t time.Time) string {
+	return ulid.MustNew(ulid.Timestamp(t), rand.Reader).String()
+}
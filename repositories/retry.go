@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"time"
+
+	"order-service/logger"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/sirupsen/logrus"
+)
+
+// ReadRetries is the number of times a read failing on a broken connection is sent again, reads are
+// idempotent so another connection of the pool can safely serve them. Writes are never retried
+var ReadRetries = 2
+
+// readRetryBackoff is the pause before the first retry of a read, it doubles for each further retry
+const readRetryBackoff = 20 * time.Millisecond
+
+// isTransient tells if err is the failure of a connection rather than of the query, database/sql only
+// retries driver.ErrBadConn before a query is sent while mysql reports a connection lost later as ErrInvalidConn
+func isTransient(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+// retryRead runs read until it succeeds, fails with an error that is not transient, runs out of
+// retries or ctx is done, and returns the error of the last run
+func retryRead(ctx context.Context, read func() error) error {
+	backoff := readRetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := read()
+		if err == nil || attempt > ReadRetries || !isTransient(err) {
+			return err
+		}
+
+		logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository", "attempt": attempt}).
+			WithError(err).Warn("Retrying read on a broken connection")
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// queryContext runs the read query on conn, retrying it on a broken connection
+func queryContext(ctx context.Context, conn *sql.DB, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := retryRead(ctx, func() error {
+		var err error
+		rows, err = conn.QueryContext(ctx, query, args...)
+		return err
+	})
+
+	return rows, err
+}
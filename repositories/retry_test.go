@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, isTransient(driver.ErrBadConn))
+	assert.True(t, isTransient(mysql.ErrInvalidConn))
+	assert.True(t, isTransient(fmt.Errorf("query: %w", driver.ErrBadConn)))
+	assert.True(t, isTransient(&net.OpError{Op: "read", Err: errors.New("connection reset by peer")}))
+
+	assert.False(t, isTransient(errors.New("syntax error")))
+	assert.False(t, isTransient(&mysql.MySQLError{Number: mysqlErrDuplicateEntry}))
+}
+
+func TestOrderRepo_List_Retry(t *testing.T) {
+	query := "SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE merchant_id = ? AND deleted_at IS NULL ORDER BY id ASC LIMIT ?, ?"
+
+	t.Run("a read on a broken connection is retried", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(query).WithArgs("merchant-1", 0, 10).WillReturnError(mysql.ErrInvalidConn)
+		mock.ExpectQuery(query).WithArgs("merchant-1", 0, 10).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "public_id", "merchant_id", "distance", "status", "created_at", "updated_at", "version"}).AddRow(1, publicId, "merchant-1", 100, "UNASSIGNED", placedAt, placedAt, 1))
+
+		orders, err := NewMysqlOrderRepo(db, time.Second).List(merchantCtx, 0, 10)
+		assert.NoError(t, err)
+		assert.Len(t, orders, 1)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("retries are limited", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		for i := 0; i <= ReadRetries; i++ {
+			mock.ExpectQuery(query).WithArgs("merchant-1", 0, 10).WillReturnError(mysql.ErrInvalidConn)
+		}
+
+		_, err = NewMysqlOrderRepo(db, time.Second).List(merchantCtx, 0, 10)
+		assert.Equal(t, mysql.ErrInvalidConn, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("other errors are not retried", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(query).WithArgs("merchant-1", 0, 10).WillReturnError(errors.New("exception"))
+
+		_, err = NewMysqlOrderRepo(db, time.Second).List(merchantCtx, 0, 10)
+		assert.EqualError(t, err, "exception")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("writes are not retried", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare("UPDATE orders SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL").
+			ExpectExec().
+			WithArgs(1, "merchant-1").
+			WillReturnError(mysql.ErrInvalidConn)
+
+		_, err = NewMysqlOrderRepo(db, time.Second).Delete(merchantCtx, 1)
+		assert.Equal(t, mysql.ErrInvalidConn, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestApiKeyRepo_GetByHash_Retry(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	query := "SELECT id, name, merchant_id, key_hash, role, created_at FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL"
+	mock.ExpectQuery(query).WithArgs("hash").WillReturnError(mysql.ErrInvalidConn)
+	mock.ExpectQuery(query).WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "merchant_id", "key_hash", "role", "created_at"}).
+			AddRow(1, "ops", "merchant-1", "hash", "ops", time.Now()))
+
+	apiKey, err := NewMysqlApiKeyRepo(db, time.Second).GetByHash(merchantCtx, "hash")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), apiKey.Id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
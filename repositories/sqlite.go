@@ -0,0 +1,315 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/url"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// OpenSqlite opens the SQLite database file at path, creating it and the tables of every repository if needed.
+// A single connection is kept open so concurrent writers wait in the pool instead of failing with SQLITE_BUSY
+func OpenSqlite(path string) (*sql.DB, error) {
+	// times are written in a format SQLite date functions and comparisons understand
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() +
+		"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite"
+
+	db := sql.OpenDB(sqliteConnector{dsn: dsn})
+	db.SetMaxOpenConns(1)
+
+	for _, stat := range sqliteTableStats {
+		if _, err := db.Exec(stat); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	// they fail harmlessly once applied
+	for _, stat := range sqliteMigrationStats {
+		db.Exec(stat)
+	}
+	for _, stat := range sqliteIndexStats {
+		if _, err := db.Exec(stat); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+// sqliteInterruptRetries is the number of times a statement failing with a stale interrupt is run again
+const sqliteInterruptRetries = 3
+
+// errSqliteInterrupted is returned for a statement of a transaction failing with a stale interrupt, SQLite may
+// have rolled back the whole transaction so it is run again from the start by retryTx
+var errSqliteInterrupted = errors.New("sqlite transaction interrupted by the context of an earlier statement")
+
+// isSqliteInterrupt tells if err is a statement interrupted by sqlite3_interrupt
+func isSqliteInterrupt(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == sqlite3.SQLITE_INTERRUPT
+}
+
+// sqliteConnector opens connections of the sqlite driver recovering from stale interrupts. The driver interrupts
+// the connection when the context of a statement is done, but may do it after the statement completed, when the
+// context is cancelled on return, interrupting the next statement of the connection instead. Such a statement
+// fails with SQLITE_INTERRUPT although its own context is not done: outside of a transaction it was rolled back
+// and is run again, inside of one it fails with errSqliteInterrupted
+type sqliteConnector struct {
+	dsn string
+}
+
+func (c sqliteConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.Driver().Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	driverConn, ok := conn.(sqliteDriverConn)
+	if !ok {
+		conn.Close()
+		return nil, errors.New("sqlite connection does not support contexts")
+	}
+
+	return &sqliteConn{sqliteDriverConn: driverConn}, nil
+}
+
+func (c sqliteConnector) Driver() driver.Driver {
+	return &sqlite.Driver{}
+}
+
+// sqliteDriverConn is the connection of the sqlite driver with its context aware methods
+type sqliteDriverConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+}
+
+// sqliteDriverStmt is the statement of the sqlite driver with its context aware methods
+type sqliteDriverStmt interface {
+	driver.Stmt
+	driver.StmtExecContext
+	driver.StmtQueryContext
+}
+
+// sqliteConn runs the statements of a connection again when they fail with a stale interrupt.
+// database/sql uses a connection from one goroutine at a time, so inTx needs no lock
+type sqliteConn struct {
+	sqliteDriverConn
+	inTx bool
+}
+
+// retryInterrupted runs statement until it does not fail with a stale interrupt or runs out of retries
+func (c *sqliteConn) retryInterrupted(ctx context.Context, statement func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := statement()
+		if !isSqliteInterrupt(err) || ctx.Err() != nil || attempt > sqliteInterruptRetries {
+			return err
+		}
+		if c.inTx {
+			return errSqliteInterrupted
+		}
+	}
+}
+
+func (c *sqliteConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	tx, err := c.sqliteDriverConn.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.inTx = true
+	return sqliteTx{tx, c}, nil
+}
+
+func (c *sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.sqliteDriverConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqliteStmt{stmt.(sqliteDriverStmt), c}, nil
+}
+
+func (c *sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	var result driver.Result
+	err := c.retryInterrupted(ctx, func() (err error) {
+		result, err = c.sqliteDriverConn.ExecContext(ctx, query, args)
+		return err
+	})
+
+	return result, err
+}
+
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	err := c.retryInterrupted(ctx, func() (err error) {
+		rows, err = c.sqliteDriverConn.QueryContext(ctx, query, args)
+		return err
+	})
+
+	return rows, err
+}
+
+// sqliteTx tells its connection when the transaction is over
+type sqliteTx struct {
+	driver.Tx
+	c *sqliteConn
+}
+
+func (t sqliteTx) Commit() error {
+	t.c.inTx = false
+	return t.Tx.Commit()
+}
+
+func (t sqliteTx) Rollback() error {
+	t.c.inTx = false
+	return t.Tx.Rollback()
+}
+
+// sqliteStmt runs a prepared statement again when it fails with a stale interrupt
+type sqliteStmt struct {
+	sqliteDriverStmt
+	c *sqliteConn
+}
+
+func (s *sqliteStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var result driver.Result
+	err := s.c.retryInterrupted(ctx, func() (err error) {
+		result, err = s.sqliteDriverStmt.ExecContext(ctx, args)
+		return err
+	})
+
+	return result, err
+}
+
+func (s *sqliteStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	err := s.c.retryInterrupted(ctx, func() (err error) {
+		rows, err = s.sqliteDriverStmt.QueryContext(ctx, args)
+		return err
+	})
+
+	return rows, err
+}
+
+// sqliteTableStats mirror the MySQL tables, SQLite has no unsigned columns so the distance is checked instead
+var sqliteTableStats = []string{
+	`CREATE TABLE IF NOT EXISTS orders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    public_id CHAR(26) NULL,
+    merchant_id VARCHAR(64) NOT NULL,
+    origin_lat DOUBLE NOT NULL,
+    origin_lng DOUBLE NOT NULL,
+    destination_lat DOUBLE NOT NULL,
+    destination_lng DOUBLE NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    distance INTEGER NOT NULL CHECK (distance >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INTEGER NOT NULL DEFAULT 1,
+    deleted_at TIMESTAMP NULL
+)`,
+	`CREATE INDEX IF NOT EXISTS idx_orders_merchant_id ON orders (merchant_id, id)`,
+	`CREATE TABLE IF NOT EXISTS orders_archive (
+    id INTEGER PRIMARY KEY,
+    public_id CHAR(26) NULL,
+    merchant_id VARCHAR(64) NOT NULL,
+    origin_lat DOUBLE NOT NULL,
+    origin_lng DOUBLE NOT NULL,
+    destination_lat DOUBLE NOT NULL,
+    destination_lng DOUBLE NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    distance INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INTEGER NOT NULL DEFAULT 1,
+    deleted_at TIMESTAMP NULL,
+    archived_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`,
+	`CREATE INDEX IF NOT EXISTS idx_orders_archive_merchant_id ON orders_archive (merchant_id, id)`,
+	`CREATE TABLE IF NOT EXISTS idempotency_keys (
+    merchant_id VARCHAR(64) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    response BLOB NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (merchant_id, idempotency_key)
+)`,
+	`CREATE TABLE IF NOT EXISTS api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    merchant_id VARCHAR(64) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    role VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP NULL
+)`,
+	`CREATE TABLE IF NOT EXISTS webhooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    merchant_id VARCHAR(64) NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    events VARCHAR(255) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`,
+	`CREATE INDEX IF NOT EXISTS idx_webhooks_merchant_id ON webhooks (merchant_id)`,
+	`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id INTEGER NOT NULL,
+    event_id INTEGER NULL,
+    event VARCHAR(64) NOT NULL,
+    payload BLOB NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_status_code INTEGER NOT NULL DEFAULT 0,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`,
+	`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, id)`,
+	`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status_next_attempt_at ON webhook_deliveries (status, next_attempt_at)`,
+	`CREATE TABLE IF NOT EXISTS outbox (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_type VARCHAR(64) NOT NULL,
+    order_id INTEGER NOT NULL,
+    merchant_id VARCHAR(64) NOT NULL,
+    payload BLOB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP NULL
+)`,
+	`CREATE INDEX IF NOT EXISTS idx_outbox_sent_at ON outbox (sent_at, id)`,
+	`CREATE INDEX IF NOT EXISTS idx_outbox_merchant_id ON outbox (merchant_id, id)`,
+}
+
+// sqliteMigrationStats add newer columns to tables created before them, existing orders get the time of the migration
+var sqliteMigrationStats = []string{
+	`ALTER TABLE orders ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00'`,
+	`ALTER TABLE orders ADD COLUMN deleted_at TIMESTAMP NULL`,
+	`UPDATE orders SET created_at = CURRENT_TIMESTAMP WHERE created_at = '1970-01-01 00:00:00'`,
+	`ALTER TABLE orders ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00'`,
+	`ALTER TABLE orders ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+	`UPDATE orders SET updated_at = CURRENT_TIMESTAMP WHERE updated_at = '1970-01-01 00:00:00'`,
+	`ALTER TABLE orders_archive ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00'`,
+	`ALTER TABLE orders_archive ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+	`UPDATE orders_archive SET updated_at = CURRENT_TIMESTAMP WHERE updated_at = '1970-01-01 00:00:00'`,
+	`ALTER TABLE orders ADD COLUMN public_id CHAR(26) NULL`,
+	`ALTER TABLE orders_archive ADD COLUMN public_id CHAR(26) NULL`,
+	`ALTER TABLE webhook_deliveries ADD COLUMN event_id INTEGER NULL`,
+}
+
+// sqliteIndexStats index the columns sqliteMigrationStats may have added
+var sqliteIndexStats = []string{
+	`CREATE INDEX IF NOT EXISTS idx_orders_created_at ON orders (created_at)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS uniq_orders_public_id ON orders (public_id)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS uniq_orders_archive_public_id ON orders_archive (public_id)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS uniq_webhook_deliveries_event ON webhook_deliveries (webhook_id, event_id)`,
+}
@@ -0,0 +1,419 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/archive"
+	"order-service/models"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// openSqlite opens a SQLite database in a temporary file, removed with the test
+func openSqlite(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := OpenSqlite(filepath.Join(t.TempDir(), "order.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func newSqliteOrder() *models.Order {
+	return &models.Order{
+		Origins:      []float64{22.3193, 114.1694},
+		Destinations: []float64{22.2783, 114.1747},
+		Distance:     5000,
+		Status:       models.StatusUnassigned,
+	}
+}
+
+func TestSqlite_OrderRepo(t *testing.T) {
+	db := openSqlite(t)
+	rp := NewSqliteOrderRepo(db, time.Second)
+	otherCtx := tenantCtx("merchant-2")
+
+	created, err := rp.Create(merchantCtx, newSqliteOrder())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), created.Id)
+
+	_, err = rp.Create(otherCtx, newSqliteOrder())
+	assert.NoError(t, err)
+
+	order, err := rp.GetById(merchantCtx, created.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "merchant-1", order.MerchantId)
+	assert.Equal(t, 5000, order.Distance)
+	assert.Equal(t, models.StatusUnassigned, order.Status)
+
+	_, err = rp.GetById(otherCtx, created.Id)
+	assert.Equal(t, models.ErrNotFound, err)
+
+	orders, err := rp.List(merchantCtx, 0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, orders, 1)
+
+	orders, err = rp.List(merchantCtx, 1, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, orders)
+
+	_, err = rp.Create(merchantCtx, &models.Order{Origins: []float64{0, 0}, Destinations: []float64{0, 0}, Distance: -1, Status: models.StatusUnassigned})
+	assert.Error(t, err, "negative distances are rejected like the unsigned column of mysql")
+}
+
+func TestSqlite_OrderRepo_Update(t *testing.T) {
+	db := openSqlite(t)
+	rp := NewSqliteOrderRepo(db, time.Second)
+	outboxRepo := NewSqliteOutboxRepo(db, time.Second)
+
+	created, err := rp.Create(merchantCtx, newSqliteOrder())
+	assert.NoError(t, err)
+
+	_, err = rp.Update(tenantCtx("merchant-2"), &models.Order{Id: created.Id, Status: models.StatusTaken}, models.StatusUnassigned)
+	assert.Equal(t, models.ErrCannotUpdate, err)
+
+	// concurrent takes, only one of them matches the unassigned status
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := rp.Update(merchantCtx, &models.Order{Id: created.Id, Status: models.StatusTaken}, models.StatusUnassigned)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	taken := 0
+	for err := range errs {
+		if err == nil {
+			taken++
+			continue
+		}
+		assert.Equal(t, models.ErrCannotUpdate, err)
+	}
+	assert.Equal(t, 1, taken)
+
+	order, err := rp.GetById(merchantCtx, created.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusTaken, order.Status)
+
+	events, err := outboxRepo.ListPending(merchantCtx, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, models.EventTypeOrderPlaced, events[0].Type)
+		assert.Equal(t, models.EventTypeOrderTaken, events[1].Type)
+		assert.Equal(t, created.Id, events[1].OrderId)
+	}
+
+	assert.NoError(t, outboxRepo.MarkSent(merchantCtx, events[0].Id))
+
+	events, err = outboxRepo.ListPending(merchantCtx, 10)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	latest, err := outboxRepo.LatestId(merchantCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, events[0].Id, latest)
+
+	deleted, err := rp.Delete(merchantCtx, created.Id)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+}
+
+func TestSqlite_WebhookRepo(t *testing.T) {
+	db := openSqlite(t)
+	rp := NewSqliteWebhookRepo(db, time.Second)
+
+	created, err := rp.Create(merchantCtx, &models.Webhook{Url: "https://example.com/hook", Events: []string{models.EventTypeOrderPlaced, models.EventTypeOrderTaken}, Secret: "secret"})
+	assert.NoError(t, err)
+
+	webhooks, err := rp.ListByEvent(merchantCtx, models.EventTypeOrderTaken)
+	assert.NoError(t, err)
+	if assert.Len(t, webhooks, 1) {
+		assert.Equal(t, created.Id, webhooks[0].Id)
+	}
+
+	// a prefix of an event is not a match
+	webhooks, err = rp.ListByEvent(merchantCtx, "order")
+	assert.NoError(t, err)
+	assert.Empty(t, webhooks)
+
+	now := time.Now().UTC()
+	delivery := models.WebhookDelivery{WebhookId: created.Id, EventId: 3, Event: models.EventTypeOrderTaken, Payload: []byte(`{}`), Status: models.DeliveryPending, NextAttemptAt: now}
+	_, err = rp.CreateDelivery(merchantCtx, &delivery)
+	assert.NoError(t, err)
+
+	// a relayed event is queued only once
+	duplicate := delivery
+	_, err = rp.CreateDelivery(merchantCtx, &duplicate)
+	assert.Equal(t, models.ErrAlreadyExists, err)
+
+	due, err := rp.ListDueDeliveries(merchantCtx, now.Add(time.Second), 10)
+	assert.NoError(t, err)
+	if assert.Len(t, due, 1) {
+		assert.Equal(t, created.Id, due[0].Webhook.Id)
+	}
+
+	due, err = rp.ListDueDeliveries(merchantCtx, now.Add(-time.Second), 10)
+	assert.NoError(t, err)
+	assert.Empty(t, due)
+
+	// a claimed delivery is neither claimed again nor due until its lease runs out
+	claimedAt := now.Add(time.Second)
+	assert.NoError(t, rp.ClaimDelivery(merchantCtx, delivery.Id, claimedAt, claimedAt.Add(time.Minute)))
+	assert.Equal(t, models.ErrCannotUpdate, rp.ClaimDelivery(merchantCtx, delivery.Id, claimedAt, claimedAt.Add(time.Minute)))
+
+	due, err = rp.ListDueDeliveries(merchantCtx, claimedAt, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, due)
+
+	due, err = rp.ListDueDeliveries(merchantCtx, claimedAt.Add(2*time.Minute), 10)
+	assert.NoError(t, err)
+	assert.Len(t, due, 1)
+}
+
+func TestSqlite_IdempotencyRepo(t *testing.T) {
+	db := openSqlite(t)
+	rp := NewSqliteIdempotencyRepo(db, time.Second)
+
+	record := &models.IdempotencyRecord{Key: "key", RequestHash: "hash"}
+	assert.NoError(t, rp.Create(merchantCtx, record))
+	assert.Equal(t, models.ErrAlreadyExists, rp.Create(merchantCtx, record))
+	assert.NoError(t, rp.Create(tenantCtx("merchant-2"), record))
+
+	record.StatusCode = 201
+	record.Response = []byte(`{"id":1}`)
+	assert.NoError(t, rp.Update(merchantCtx, record))
+
+	stored, err := rp.GetByKey(merchantCtx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, 201, stored.StatusCode)
+	assert.Equal(t, []byte(`{"id":1}`), stored.Response)
+	assert.False(t, stored.CreatedAt.IsZero())
+
+	// the key is not expired yet
+	deleted, err := rp.DeleteExpired(merchantCtx, "key", time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+	deleted, err = rp.DeleteExpired(merchantCtx, "key", time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	_, err = rp.GetByKey(merchantCtx, "key")
+	assert.Equal(t, models.ErrNotFound, err)
+}
+
+func TestSqlite_CancelledContexts(t *testing.T) {
+	rp := NewSqliteOrderRepo(openSqlite(t), time.Second)
+
+	// every call cancels its context on return, which must not interrupt the statements of the next calls
+	for i := 0; i < 500; i++ {
+		if _, err := rp.Create(merchantCtx, newSqliteOrder()); err != nil {
+			t.Fatalf("create %d: %v", i, err)
+		}
+	}
+}
+
+func TestSqlite_OrderRepo_Archive(t *testing.T) {
+	rp := NewSqliteOrderRepo(openSqlite(t), time.Second)
+	archivedCtx := archive.WithArchived(merchantCtx)
+
+	unassigned, err := rp.Create(merchantCtx, newSqliteOrder())
+	assert.NoError(t, err)
+	taken, err := rp.Create(merchantCtx, newSqliteOrder())
+	assert.NoError(t, err)
+	_, err = rp.Update(merchantCtx, &models.Order{Id: taken.Id, Status: models.StatusTaken}, models.StatusUnassigned)
+	assert.NoError(t, err)
+	delivered, err := rp.Create(merchantCtx, newSqliteOrder())
+	assert.NoError(t, err)
+	_, err = rp.Update(merchantCtx, &models.Order{Id: delivered.Id, Status: models.StatusTaken}, models.StatusUnassigned)
+	assert.NoError(t, err)
+	_, err = rp.Update(merchantCtx, &models.Order{Id: delivered.Id, Status: models.StatusDelivered}, models.StatusTaken)
+	assert.NoError(t, err)
+	deleted, err := rp.Create(merchantCtx, newSqliteOrder())
+	assert.NoError(t, err)
+
+	// a deleted order is gone for every query but keeps its row
+	ok, err := rp.Delete(merchantCtx, deleted.Id)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = rp.Delete(merchantCtx, deleted.Id)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	_, err = rp.GetById(merchantCtx, deleted.Id)
+	assert.Equal(t, models.ErrNotFound, err)
+	_, err = rp.Update(merchantCtx, &models.Order{Id: deleted.Id, Status: models.StatusTaken}, models.StatusUnassigned)
+	assert.Equal(t, models.ErrCannotUpdate, err)
+
+	// orders placed after before are kept
+	n, err := rp.Archive(context.Background(), time.Now().Add(-time.Hour), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// the delivered and the deleted orders are archived one batch at a time, a taken order is not done yet
+	n, err = rp.Archive(context.Background(), time.Now().Add(time.Hour), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = rp.Archive(context.Background(), time.Now().Add(time.Hour), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = rp.Archive(context.Background(), time.Now().Add(time.Hour), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	_, err = rp.GetById(merchantCtx, delivered.Id)
+	assert.Equal(t, models.ErrNotFound, err)
+	order, err := rp.GetById(archivedCtx, delivered.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusDelivered, order.Status)
+	// the archive keeps the version and the times of the order
+	assert.Equal(t, int64(3), order.Version)
+	assert.True(t, order.CreatedAt.Equal(delivered.CreatedAt))
+	_, err = rp.GetById(archivedCtx, deleted.Id)
+	assert.Equal(t, models.ErrNotFound, err, "deleted orders stay deleted in the archive")
+
+	orders, err := rp.List(merchantCtx, 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, orders, 2) {
+		assert.Equal(t, unassigned.Id, orders[0].Id)
+		assert.Equal(t, taken.Id, orders[1].Id)
+	}
+
+	orders, err = rp.List(archivedCtx, 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, orders, 3) {
+		assert.Equal(t, unassigned.Id, orders[0].Id)
+		assert.Equal(t, taken.Id, orders[1].Id)
+		assert.Equal(t, delivered.Id, orders[2].Id)
+		assert.True(t, orders[2].UpdatedAt.Equal(order.UpdatedAt))
+	}
+
+	orders, err = rp.List(archive.WithArchived(tenantCtx("merchant-2")), 0, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, orders)
+}
+
+func TestSqlite_OutboxRepo_ListAfter(t *testing.T) {
+	db := openSqlite(t)
+	rp := NewSqliteOrderRepo(db, time.Second)
+	outboxRepo := NewSqliteOutboxRepo(db, time.Second)
+
+	delivered, err := rp.Create(merchantCtx, newSqliteOrder())
+	assert.NoError(t, err)
+	_, err = rp.Update(merchantCtx, &models.Order{Id: delivered.Id, Status: models.StatusTaken}, models.StatusUnassigned)
+	assert.NoError(t, err)
+	_, err = rp.Update(merchantCtx, &models.Order{Id: delivered.Id, Status: models.StatusDelivered}, models.StatusTaken)
+	assert.NoError(t, err)
+	deleted, err := rp.Create(merchantCtx, newSqliteOrder())
+	assert.NoError(t, err)
+	_, err = rp.Delete(merchantCtx, deleted.Id)
+	assert.NoError(t, err)
+	n, err := rp.Archive(context.Background(), time.Now().Add(time.Hour), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// the events of the archived order are still listed with its origins, the deleted order's are left out
+	events, err := outboxRepo.ListAfter(merchantCtx, 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, models.EventTypeOrderPlaced, events[0].Type)
+		assert.Equal(t, models.EventTypeOrderTaken, events[1].Type)
+		for _, event := range events {
+			assert.Equal(t, delivered.Id, event.OrderId)
+			assert.Equal(t, []float64{22.3193, 114.1694}, event.Origins)
+		}
+	}
+}
+
+func TestOpenSqlite_Migration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "order.db")
+
+	// the orders table before soft deletes
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE orders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    merchant_id VARCHAR(64) NOT NULL,
+    origin_lat DOUBLE NOT NULL,
+    origin_lng DOUBLE NOT NULL,
+    destination_lat DOUBLE NOT NULL,
+    destination_lng DOUBLE NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    distance INTEGER NOT NULL CHECK (distance >= 0)
+)`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO orders (merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, status, distance) VALUES ('merchant-1', 0, 0, 0, 0, 'TAKEN', 100)`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO orders (merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, status, distance) VALUES ('merchant-1', 0, 0, 0, 0, 'UNASSIGNED', 200)`)
+	assert.NoError(t, err)
+	db.Close()
+
+	db, err = OpenSqlite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rp := NewSqliteOrderRepo(db, time.Second)
+
+	// the existing order is at its first version, placed and last updated at the migration, without public id
+	order, err := rp.GetById(merchantCtx, 1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(1), order.Version)
+		assert.WithinDuration(t, time.Now(), order.CreatedAt, time.Minute)
+		assert.WithinDuration(t, time.Now(), order.UpdatedAt, time.Minute)
+		assert.Equal(t, "", order.PublicId)
+	}
+
+	_, err = rp.Delete(merchantCtx, 1)
+	assert.NoError(t, err)
+
+	// the existing order is considered placed at the migration
+	n, err := rp.Archive(context.Background(), time.Now().Add(-time.Hour), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	n, err = rp.Archive(context.Background(), time.Now().Add(time.Hour), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// the existing orders are given public ids, archived or not, once
+	n, err = rp.AssignPublicIds(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	n, err = rp.AssignPublicIds(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	order, err = rp.GetById(merchantCtx, 2)
+	if assert.NoError(t, err) {
+		id, err := ulid.ParseStrict(order.PublicId)
+		assert.NoError(t, err)
+		// generated from the time the order was placed
+		assert.Equal(t, ulid.Timestamp(order.CreatedAt), id.Time())
+
+		found, err := rp.GetByPublicId(merchantCtx, order.PublicId)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), found.Id)
+	}
+	var archivedId string
+	assert.NoError(t, db.QueryRow("SELECT public_id FROM orders_archive WHERE id = 1").Scan(&archivedId))
+	assert.Len(t, archivedId, 26)
+
+	// opening the migrated file again changes nothing
+	db.Close()
+	db, err = OpenSqlite(path)
+	assert.NoError(t, err)
+	db.Close()
+}
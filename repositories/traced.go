@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"context"
+
+	"order-service/models"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("order-service/repositories")
+
+type tracedOrderRepo struct {
+	next   OrderRepository
+	system string
+}
+
+// NewTracedOrderRepo will wrap an OrderRepository so every call is recorded as a client span,
+// system is the database kind reported as db.system, e.g. "mysql"
+func NewTracedOrderRepo(next OrderRepository, system string) OrderRepository {
+	return &tracedOrderRepo{next, system}
+}
+
+func (rp *tracedOrderRepo) start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, semconv.DBSystemKey.String(rp.system), semconv.DBOperationKey.String(operation))
+
+	return tracer.Start(ctx, "OrderRepository."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+}
+
+// end records err on span before ending it, a failed conditional update is not treated as an error
+func end(span trace.Span, err error) {
+	if err != nil && err != models.ErrCannotUpdate {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+func (rp *tracedOrderRepo) GetById(ctx context.Context, id int64) (*models.Order, error) {
+	ctx, span := rp.start(ctx, "GetById", attribute.Int64("order.id", id))
+
+	order, err := rp.next.GetById(ctx, id)
+	end(span, err)
+
+	return order, err
+}
+
+func (rp *tracedOrderRepo) GetByPublicId(ctx context.Context, publicId string) (*models.Order, error) {
+	ctx, span := rp.start(ctx, "GetByPublicId", attribute.String("order.public_id", publicId))
+
+	order, err := rp.next.GetByPublicId(ctx, publicId)
+	end(span, err)
+
+	return order, err
+}
+
+func (rp *tracedOrderRepo) Update(ctx context.Context, order *models.Order, withStatus string) (*models.Order, error) {
+	ctx, span := rp.start(ctx, "Update", attribute.Int64("order.id", order.Id), attribute.String("order.status", order.Status))
+
+	o, err := rp.next.Update(ctx, order, withStatus)
+	span.SetAttributes(attribute.Bool("order.updated", err == nil))
+	end(span, err)
+
+	return o, err
+}
+
+func (rp *tracedOrderRepo) Create(ctx context.Context, o *models.Order) (*models.Order, error) {
+	ctx, span := rp.start(ctx, "Create")
+
+	order, err := rp.next.Create(ctx, o)
+	if err == nil {
+		span.SetAttributes(attribute.Int64("order.id", order.Id))
+	}
+	end(span, err)
+
+	return order, err
+}
+
+func (rp *tracedOrderRepo) Delete(ctx context.Context, id int64) (bool, error) {
+	ctx, span := rp.start(ctx, "Delete", attribute.Int64("order.id", id))
+
+	deleted, err := rp.next.Delete(ctx, id)
+	end(span, err)
+
+	return deleted, err
+}
+
+func (rp *tracedOrderRepo) List(ctx context.Context, offset, limit int) ([]models.Order, error) {
+	ctx, span := rp.start(ctx, "List", attribute.Int("offset", offset), attribute.Int("limit", limit))
+
+	orders, err := rp.next.List(ctx, offset, limit)
+	end(span, err)
+
+	return orders, err
+}
+
+// WithTx records the transaction as a span, parent of the spans of the calls of fn
+func (rp *tracedOrderRepo) WithTx(ctx context.Context, fn func(ctx context.Context, repo OrderRepository) error) error {
+	ctx, span := rp.start(ctx, "WithTx")
+
+	err := rp.next.WithTx(ctx, func(ctx context.Context, repo OrderRepository) error {
+		return fn(ctx, &tracedOrderRepo{repo, rp.system})
+	})
+	end(span, err)
+
+	return err
+}
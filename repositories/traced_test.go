@@ -0,0 +1,87 @@
+package repositories_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"order-service/models"
+	"order-service/repositories"
+	"order-service/repositories/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedOrderRepo(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+
+	mockOrderRepo := new(mocks.OrderRepository)
+	orderRepo := repositories.NewTracedOrderRepo(mockOrderRepo, "mysql")
+
+	t.Run("success", func(t *testing.T) {
+		mockOrderRepo.On("GetById", mock.Anything, int64(1)).Return(&models.Order{Id: 1}, nil).Once()
+
+		order, err := orderRepo.GetById(ctx, 1)
+		assert.NoError(t, err)
+		assert.NotNil(t, order)
+
+		spans := recorder.Ended()
+		if assert.Equal(t, 1, len(spans)) {
+			assert.Equal(t, "OrderRepository.GetById", spans[0].Name())
+			assert.Equal(t, parent.SpanContext().TraceID(), spans[0].SpanContext().TraceID())
+			assert.Equal(t, codes.Unset, spans[0].Status().Code)
+		}
+
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("error is recorded", func(t *testing.T) {
+		mockOrderRepo.On("List", mock.Anything, 0, 10).Return(nil, errors.New("exception")).Once()
+
+		_, err := orderRepo.List(ctx, 0, 10)
+		assert.Error(t, err)
+
+		spans := recorder.Ended()
+		if assert.Equal(t, 2, len(spans)) {
+			assert.Equal(t, "OrderRepository.List", spans[1].Name())
+			assert.Equal(t, codes.Error, spans[1].Status().Code)
+		}
+
+		mockOrderRepo.AssertExpectations(t)
+	})
+	t.Run("calls of a transaction are children of its span", func(t *testing.T) {
+		txRepo := new(mocks.OrderRepository)
+		txRepo.On("Delete", mock.Anything, int64(1)).Return(true, nil).Once()
+		mockOrderRepo.On("WithTx", mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) {
+				fn := args.Get(1).(func(context.Context, repositories.OrderRepository) error)
+				assert.NoError(t, fn(args.Get(0).(context.Context), txRepo))
+			}).
+			Return(nil).Once()
+
+		err := orderRepo.WithTx(ctx, func(ctx context.Context, repo repositories.OrderRepository) error {
+			_, err := repo.Delete(ctx, 1)
+			return err
+		})
+		assert.NoError(t, err)
+
+		spans := recorder.Ended()
+		if assert.Equal(t, 4, len(spans)) {
+			assert.Equal(t, "OrderRepository.Delete", spans[2].Name())
+			assert.Equal(t, "OrderRepository.WithTx", spans[3].Name())
+			assert.Equal(t, spans[3].SpanContext().SpanID(), spans[2].Parent().SpanID())
+		}
+
+		mockOrderRepo.AssertExpectations(t)
+		txRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"order-service/logger"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// TxRetries is the number of times a rolled back transaction, e.g. the victim of a deadlock, is run again
+var TxRetries = 3
+
+// txRetryBackoff is the pause before the first retry of a transaction, it doubles for each further retry
+const txRetryBackoff = 20 * time.Millisecond
+
+// mysqlErrDeadlock is returned by mysql when the transaction was rolled back to break a deadlock
+const mysqlErrDeadlock = 1213
+
+// isDeadlock tells if err is the rollback of a transaction chosen as the victim of a deadlock,
+// the transaction can then be run again from the start
+func isDeadlock(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrDeadlock
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// deadlock_detected
+		return pqErr.Code == "40P01"
+	}
+
+	return false
+}
+
+type txKey struct{}
+
+// withinTx marks ctx as the context of a transaction, so transactions cannot be started from it
+func withinTx(ctx context.Context) context.Context {
+	return context.WithValue(ctx, txKey{}, true)
+}
+
+// inTx tells if ctx is the context of a transaction
+func inTx(ctx context.Context) bool {
+	within, _ := ctx.Value(txKey{}).(bool)
+	return within
+}
+
+// isRolledBack tells if err is the rollback of a transaction that can be run again from the start,
+// after a deadlock or a stale interrupt of SQLite
+func isRolledBack(err error) bool {
+	return isDeadlock(err) || errors.Is(err, errSqliteInterrupted)
+}
+
+// retryTx runs tx until it succeeds, fails with an error other than a rollback, runs out of retries or
+// ctx is done, and returns the error of the last run
+func retryTx(ctx context.Context, tx func() error) error {
+	backoff := txRetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := tx()
+		if err == nil || attempt > TxRetries || !isRolledBack(err) {
+			return err
+		}
+
+		logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository", "attempt": attempt}).
+			WithError(err).Warn("Retrying rolled back transaction")
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,164 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"order-service/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDeadlock(t *testing.T) {
+	assert.True(t, isDeadlock(&mysql.MySQLError{Number: mysqlErrDeadlock}))
+	assert.True(t, isDeadlock(fmt.Errorf("take: %w", &mysql.MySQLError{Number: mysqlErrDeadlock})))
+	assert.True(t, isDeadlock(&pq.Error{Code: "40P01"}))
+
+	assert.False(t, isDeadlock(&mysql.MySQLError{Number: mysqlErrDuplicateEntry}))
+	assert.False(t, isDeadlock(models.ErrCannotUpdate))
+}
+
+func TestIsRolledBack(t *testing.T) {
+	assert.True(t, isRolledBack(&mysql.MySQLError{Number: mysqlErrDeadlock}))
+	assert.True(t, isRolledBack(errSqliteInterrupted))
+
+	assert.False(t, isRolledBack(models.ErrCannotUpdate))
+}
+
+func TestOrderRepo_WithTx(t *testing.T) {
+	const (
+		updateQuery = "UPDATE orders SET status = ?, updated_at = ?, version = version + 1 where id = ? AND merchant_id = ? AND status = ? AND deleted_at IS NULL"
+		outboxQuery = "INSERT INTO outbox (event_type, order_id, merchant_id, payload) VALUES (?, ?, ?, ?)"
+	)
+
+	newRepo := func(t *testing.T) (*OrderRepo, sqlmock.Sqlmock, *sqlmock.ExpectedPrepare) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+		}
+		t.Cleanup(func() {
+			assert.NoError(t, mock.ExpectationsWereMet())
+			db.Close()
+		})
+
+		// the statements are prepared before the transaction begins
+		prep := mock.ExpectPrepare(updateQuery)
+		mock.ExpectPrepare(updateOrderVersionQuery)
+		mock.ExpectPrepare(insertOrderQuery)
+		mock.ExpectPrepare(deleteOrderQuery)
+
+		return NewMysqlOrderRepo(db, time.Second), mock, prep
+	}
+	take := func(ctx context.Context, repo OrderRepository) error {
+		_, err := repo.Update(ctx, &models.Order{Id: 1, Status: models.StatusTaken}, models.StatusUnassigned)
+		return err
+	}
+
+	t.Run("the calls are committed together", func(t *testing.T) {
+		orderRepo, mock, prep := newRepo(t)
+		mock.ExpectBegin()
+		prep.ExpectExec().WithArgs(models.StatusTaken, sqlmock.AnyArg(), 1, "merchant-1", models.StatusUnassigned).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(outboxQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL").
+			WithArgs(1, "merchant-1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "public_id", "merchant_id", "distance", "status", "created_at", "updated_at", "version"}).AddRow(1, publicId, "merchant-1", 100, models.StatusTaken, placedAt, placedAt, 1))
+		mock.ExpectCommit()
+
+		err := orderRepo.WithTx(merchantCtx, func(ctx context.Context, repo OrderRepository) error {
+			if err := take(ctx, repo); err != nil {
+				return err
+			}
+
+			order, err := repo.GetById(ctx, 1)
+			if err != nil {
+				return err
+			}
+			assert.Equal(t, models.StatusTaken, order.Status)
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("a deadlock runs the transaction again", func(t *testing.T) {
+		orderRepo, mock, prep := newRepo(t)
+		mock.ExpectBegin()
+		prep.ExpectExec().WillReturnError(&mysql.MySQLError{Number: mysqlErrDeadlock})
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		prep.ExpectExec().WithArgs(models.StatusTaken, sqlmock.AnyArg(), 1, "merchant-1", models.StatusUnassigned).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(outboxQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		runs := 0
+		err := orderRepo.WithTx(merchantCtx, func(ctx context.Context, repo OrderRepository) error {
+			runs++
+			return take(ctx, repo)
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, runs)
+	})
+
+	t.Run("deadlock retries are limited", func(t *testing.T) {
+		orderRepo, mock, prep := newRepo(t)
+		deadlock := &mysql.MySQLError{Number: mysqlErrDeadlock}
+		for i := 0; i <= TxRetries; i++ {
+			mock.ExpectBegin()
+			prep.ExpectExec().WillReturnError(deadlock)
+			mock.ExpectRollback()
+		}
+
+		err := orderRepo.WithTx(merchantCtx, take)
+		assert.Equal(t, deadlock, err)
+	})
+
+	t.Run("an error rolls back", func(t *testing.T) {
+		orderRepo, mock, prep := newRepo(t)
+		mock.ExpectBegin()
+		prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(outboxQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectRollback()
+
+		exception := errors.New("exception")
+		err := orderRepo.WithTx(merchantCtx, func(ctx context.Context, repo OrderRepository) error {
+			if err := take(ctx, repo); err != nil {
+				return err
+			}
+			return exception
+		})
+		assert.Equal(t, exception, err)
+	})
+
+	t.Run("a panic rolls back", func(t *testing.T) {
+		orderRepo, mock, _ := newRepo(t)
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		assert.PanicsWithValue(t, "exception", func() {
+			orderRepo.WithTx(merchantCtx, func(context.Context, OrderRepository) error {
+				panic("exception")
+			})
+		})
+	})
+
+	t.Run("writes outside of the transaction fail", func(t *testing.T) {
+		orderRepo, mock, _ := newRepo(t)
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		err := orderRepo.WithTx(merchantCtx, func(ctx context.Context, repo OrderRepository) error {
+			assert.Equal(t, models.ErrNestedTx, repo.WithTx(ctx, take))
+			assert.Equal(t, models.ErrNestedTx, orderRepo.WithTx(ctx, take))
+			assert.Equal(t, models.ErrNestedTx, take(ctx, orderRepo))
+			_, err := orderRepo.Delete(ctx, 1)
+			assert.Equal(t, models.ErrNestedTx, err)
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,318 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"order-service/logger"
+	"order-service/models"
+	"order-service/tenant"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WebhookRepo stores webhooks and their deliveries in MySQL or SQLite
+type WebhookRepo struct {
+	Conn    *sql.DB
+	Timeout time.Duration
+	Dialect Dialect
+}
+
+// NewMysqlWebhookRepo will create a WebhookRepo on conn, every query is cancelled after timeout unless timeout is zero
+func NewMysqlWebhookRepo(conn *sql.DB, timeout time.Duration) *WebhookRepo {
+	return &WebhookRepo{conn, timeout, MySQL}
+}
+
+// NewSqliteWebhookRepo will create a WebhookRepo on a SQLite conn, every query is cancelled after timeout unless timeout is zero
+func NewSqliteWebhookRepo(conn *sql.DB, timeout time.Duration) *WebhookRepo {
+	return &WebhookRepo{conn, timeout, SQLite}
+}
+
+func (rp *WebhookRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if rp.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, rp.Timeout)
+}
+
+func (rp *WebhookRepo) fetch(ctx context.Context, query string, args ...interface{}) ([]models.Webhook, error) {
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := queryContext(ctx, rp.Conn, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]models.Webhook, 0)
+
+	for rows.Next() {
+		webhook := models.Webhook{}
+		var events string
+
+		err := rows.Scan(&webhook.Id, &webhook.MerchantId, &webhook.Url, &events, &webhook.Secret, &webhook.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		webhook.Events = strings.Split(events, ",")
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (rp *WebhookRepo) GetById(ctx context.Context, id int64) (*models.Webhook, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/webhook", "method": "GetById", "webhook_id": id})
+
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return nil, models.ErrNoTenant
+	}
+
+	query := "SELECT id, merchant_id, url, events, secret, created_at FROM webhooks WHERE id = ? AND merchant_id = ?"
+	webhooks, err := rp.fetch(ctx, query, id, merchantId)
+	if err != nil {
+		log.WithError(err).Error("Failed to query webhook")
+		return nil, err
+	}
+
+	if len(webhooks) == 0 {
+		return nil, models.ErrNotFound
+	}
+
+	return &webhooks[0], nil
+}
+
+func (rp *WebhookRepo) Create(ctx context.Context, webhook *models.Webhook) (*models.Webhook, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/webhook", "method": "Create"})
+
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return nil, models.ErrNoTenant
+	}
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	query := "INSERT INTO webhooks (merchant_id, url, events, secret) VALUES (?, ?, ?, ?)"
+
+	result, err := rp.Conn.ExecContext(ctx, query, merchantId, webhook.Url, strings.Join(webhook.Events, ","), webhook.Secret)
+	if err != nil {
+		log.WithError(err).Error("Failed to insert webhook")
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.WithError(err).Error("Failed to get inserted id")
+		return nil, err
+	}
+
+	webhook.Id = id
+	webhook.MerchantId = merchantId
+	webhook.CreatedAt = time.Now()
+	return webhook, nil
+}
+
+// ListByEvent returns the webhooks of the merchant subscribed to event
+func (rp *WebhookRepo) ListByEvent(ctx context.Context, event string) ([]models.Webhook, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/webhook", "method": "ListByEvent", "event": event})
+
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return nil, models.ErrNoTenant
+	}
+
+	query := "SELECT id, merchant_id, url, events, secret, created_at FROM webhooks WHERE merchant_id = ? AND " + rp.Dialect.findInSet("events")
+	webhooks, err := rp.fetch(ctx, query, merchantId, event)
+	if err != nil {
+		log.WithError(err).Error("Failed to query webhooks")
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// CreateDelivery queues delivery, it returns models.ErrAlreadyExists if the webhook already has a delivery of its event
+func (rp *WebhookRepo) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/webhook", "method": "CreateDelivery", "webhook_id": delivery.WebhookId, "event": delivery.Event})
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	query := "INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, status, next_attempt_at) VALUES (?, ?, ?, ?, ?, ?)"
+
+	result, err := rp.Conn.ExecContext(ctx, query, delivery.WebhookId, delivery.EventId, delivery.Event, []byte(delivery.Payload), delivery.Status, delivery.NextAttemptAt)
+	if isDuplicate(err) {
+		return nil, models.ErrAlreadyExists
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to insert webhook delivery")
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.WithError(err).Error("Failed to get inserted id")
+		return nil, err
+	}
+
+	delivery.Id = id
+	delivery.CreatedAt = time.Now()
+	return delivery, nil
+}
+
+func (rp *WebhookRepo) fetchDeliveries(ctx context.Context, withWebhook bool, query string, args ...interface{}) ([]models.WebhookDelivery, error) {
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := queryContext(ctx, rp.Conn, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]models.WebhookDelivery, 0)
+
+	for rows.Next() {
+		d := models.WebhookDelivery{}
+		var payload []byte
+
+		dest := []interface{}{&d.Id, &d.WebhookId, &d.Event, &payload, &d.Status, &d.Attempts, &d.LastStatusCode, &d.LastError, &d.NextAttemptAt, &d.CreatedAt}
+
+		var events string
+		if withWebhook {
+			d.Webhook = &models.Webhook{}
+			dest = append(dest, &d.Webhook.Id, &d.Webhook.MerchantId, &d.Webhook.Url, &events, &d.Webhook.Secret, &d.Webhook.CreatedAt)
+		}
+
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+
+		d.Payload = payload
+		if withWebhook {
+			d.Webhook.Events = strings.Split(events, ",")
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// ListDeliveries returns the deliveries of the merchant's webhook, latest first
+func (rp *WebhookRepo) ListDeliveries(ctx context.Context, webhookId int64, offset, limit int) ([]models.WebhookDelivery, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/webhook", "method": "ListDeliveries", "webhook_id": webhookId, "offset": offset, "limit": limit})
+
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return nil, models.ErrNoTenant
+	}
+
+	query := "SELECT d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.last_status_code, d.last_error, d.next_attempt_at, d.created_at " +
+		"FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id " +
+		"WHERE d.webhook_id = ? AND w.merchant_id = ? ORDER BY d.id DESC LIMIT ?, ?"
+
+	deliveries, err := rp.fetchDeliveries(ctx, false, query, webhookId, merchantId, offset, limit)
+	if err != nil {
+		log.WithError(err).Error("Failed to query webhook deliveries")
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (rp *WebhookRepo) ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/webhook", "method": "ListDueDeliveries", "limit": limit})
+
+	query := "SELECT d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.last_status_code, d.last_error, d.next_attempt_at, d.created_at, " +
+		"w.id, w.merchant_id, w.url, w.events, w.secret, w.created_at " +
+		"FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id " +
+		"WHERE d.status = ? AND d.next_attempt_at <= ? ORDER BY d.next_attempt_at ASC LIMIT ?"
+
+	deliveries, err := rp.fetchDeliveries(ctx, true, query, models.DeliveryPending, now, limit)
+	if err != nil {
+		log.WithError(err).Error("Failed to query due webhook deliveries")
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// ClaimDelivery moves the next attempt of the delivery to until if it is still pending and due at now,
+// a worker stopping before the outcome is stored leaves the delivery due again once until is reached
+func (rp *WebhookRepo) ClaimDelivery(ctx context.Context, id int64, now, until time.Time) error {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/webhook", "method": "ClaimDelivery", "delivery_id": id})
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ? AND status = ? AND next_attempt_at <= ?"
+
+	result, err := rp.Conn.ExecContext(ctx, query, until, id, models.DeliveryPending, now)
+	if err != nil {
+		log.WithError(err).Error("Failed to claim webhook delivery")
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.WithError(err).Error("Failed to get affected rows")
+		return err
+	}
+
+	if rowsAffected != 1 {
+		return models.ErrCannotUpdate
+	}
+
+	return nil
+}
+
+// UpdateDelivery stores the outcome of the last attempt of delivery
+func (rp *WebhookRepo) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "repository/webhook", "method": "UpdateDelivery", "delivery_id": delivery.Id, "status": delivery.Status})
+
+	ctx, cancel := rp.withTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE webhook_deliveries SET status = ?, attempts = ?, last_status_code = ?, last_error = ?, next_attempt_at = ? WHERE id = ?"
+
+	result, err := rp.Conn.ExecContext(ctx, query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.LastStatusCode,
+		delivery.LastError,
+		delivery.NextAttemptAt,
+		delivery.Id)
+	if err != nil {
+		log.WithError(err).Error("Failed to update webhook delivery")
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.WithError(err).Error("Failed to get affected rows")
+		return err
+	}
+
+	if rowsAffected != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,249 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"order-service/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+var webhookColumns = []string{"id", "merchant_id", "url", "events", "secret", "created_at"}
+
+var deliveryColumns = []string{"id", "webhook_id", "event", "payload", "status", "attempts", "last_status_code", "last_error", "next_attempt_at", "created_at"}
+
+func TestWebhookRepo_GetById(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	query := "SELECT id, merchant_id, url, events, secret, created_at FROM webhooks WHERE id = ? AND merchant_id = ?"
+
+	mock.ExpectQuery(query).
+		WithArgs(1, "merchant-1").
+		WillReturnRows(sqlmock.NewRows(webhookColumns).
+			AddRow(1, "merchant-1", "https://example.com/hook", "order.placed,order.taken", "secret", time.Now()))
+
+	mock.ExpectQuery(query).
+		WithArgs(1, "merchant-2").
+		WillReturnRows(sqlmock.NewRows(webhookColumns))
+
+	repo := NewMysqlWebhookRepo(db, time.Second)
+
+	webhook, err := repo.GetById(merchantCtx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{models.EventOrderPlaced, models.EventOrderTaken}, webhook.Events)
+
+	webhook, err = repo.GetById(tenantCtx("merchant-2"), 1)
+	assert.Equal(t, models.ErrNotFound, err)
+	assert.Nil(t, webhook)
+
+	_, err = repo.GetById(context.Background(), 1)
+	assert.Equal(t, models.ErrNoTenant, err)
+}
+
+func TestWebhookRepo_Create(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	query := "INSERT INTO webhooks (merchant_id, url, events, secret) VALUES (?, ?, ?, ?)"
+
+	mock.ExpectExec(query).
+		WithArgs("merchant-1", "https://example.com/hook", "order.placed,order.taken", "secret").
+		WillReturnResult(sqlmock.NewResult(3, 1))
+
+	repo := NewMysqlWebhookRepo(db, time.Second)
+
+	webhook, err := repo.Create(merchantCtx, &models.Webhook{
+		Url:    "https://example.com/hook",
+		Events: []string{models.EventOrderPlaced, models.EventOrderTaken},
+		Secret: "secret",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), webhook.Id)
+	assert.Equal(t, "merchant-1", webhook.MerchantId)
+}
+
+func TestWebhookRepo_ListByEvent(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	query := "SELECT id, merchant_id, url, events, secret, created_at FROM webhooks WHERE merchant_id = ? AND FIND_IN_SET(?, events) > 0"
+
+	mock.ExpectQuery(query).
+		WithArgs("merchant-1", models.EventOrderTaken).
+		WillReturnRows(sqlmock.NewRows(webhookColumns).
+			AddRow(1, "merchant-1", "https://example.com/hook", "order.taken", "secret", time.Now()))
+
+	repo := NewMysqlWebhookRepo(db, time.Second)
+
+	webhooks, err := repo.ListByEvent(merchantCtx, models.EventOrderTaken)
+	assert.NoError(t, err)
+	assert.Len(t, webhooks, 1)
+}
+
+func TestWebhookRepo_CreateDelivery(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	query := "INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, status, next_attempt_at) VALUES (?, ?, ?, ?, ?, ?)"
+
+	repo := NewMysqlWebhookRepo(db, time.Second)
+	newDelivery := func() *models.WebhookDelivery {
+		return &models.WebhookDelivery{
+			WebhookId:     1,
+			EventId:       9,
+			Event:         models.EventOrderTaken,
+			Payload:       []byte(`{}`),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: now,
+		}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectExec(query).
+			WithArgs(1, 9, models.EventOrderTaken, []byte(`{}`), models.DeliveryPending, now).
+			WillReturnResult(sqlmock.NewResult(5, 1))
+
+		delivery, err := repo.CreateDelivery(merchantCtx, newDelivery())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), delivery.Id)
+	})
+
+	t.Run("event already queued", func(t *testing.T) {
+		mock.ExpectExec(query).
+			WithArgs(1, 9, models.EventOrderTaken, []byte(`{}`), models.DeliveryPending, now).
+			WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1-9' for key 'uniq_webhook_event'"})
+
+		delivery, err := repo.CreateDelivery(merchantCtx, newDelivery())
+		assert.Equal(t, models.ErrAlreadyExists, err)
+		assert.Nil(t, delivery)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepo_ListDeliveries(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	query := "SELECT d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.last_status_code, d.last_error, d.next_attempt_at, d.created_at " +
+		"FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id " +
+		"WHERE d.webhook_id = ? AND w.merchant_id = ? ORDER BY d.id DESC LIMIT ?, ?"
+
+	mock.ExpectQuery(query).
+		WithArgs(1, "merchant-1", 0, 10).
+		WillReturnRows(sqlmock.NewRows(deliveryColumns).
+			AddRow(2, 1, "order.taken", []byte(`{}`), "PENDING", 1, 500, "unexpected status 500", time.Now(), time.Now()))
+
+	repo := NewMysqlWebhookRepo(db, time.Second)
+
+	deliveries, err := repo.ListDeliveries(merchantCtx, 1, 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, 500, deliveries[0].LastStatusCode)
+		assert.Nil(t, deliveries[0].Webhook)
+	}
+}
+
+func TestWebhookRepo_ListDueDeliveries(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	query := "SELECT d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.last_status_code, d.last_error, d.next_attempt_at, d.created_at, " +
+		"w.id, w.merchant_id, w.url, w.events, w.secret, w.created_at " +
+		"FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id " +
+		"WHERE d.status = ? AND d.next_attempt_at <= ? ORDER BY d.next_attempt_at ASC LIMIT ?"
+
+	mock.ExpectQuery(query).
+		WithArgs(models.DeliveryPending, now, 10).
+		WillReturnRows(sqlmock.NewRows(append(deliveryColumns, webhookColumns...)).
+			AddRow(2, 1, "order.taken", []byte(`{}`), "PENDING", 0, 0, "", now, now,
+				1, "merchant-1", "https://example.com/hook", "order.taken", "secret", now))
+
+	repo := NewMysqlWebhookRepo(db, time.Second)
+
+	// the worker is not scoped to a merchant
+	deliveries, err := repo.ListDueDeliveries(context.Background(), now, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, "https://example.com/hook", deliveries[0].Webhook.Url)
+		assert.Equal(t, "secret", deliveries[0].Webhook.Secret)
+	}
+}
+
+func TestWebhookRepo_UpdateDelivery(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	query := "UPDATE webhook_deliveries SET status = ?, attempts = ?, last_status_code = ?, last_error = ?, next_attempt_at = ? WHERE id = ?"
+
+	mock.ExpectExec(query).
+		WithArgs(models.DeliveryDead, 8, 500, "unexpected status 500", now, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	repo := NewMysqlWebhookRepo(db, time.Second)
+
+	err = repo.UpdateDelivery(context.Background(), &models.WebhookDelivery{
+		Id:             2,
+		Status:         models.DeliveryDead,
+		Attempts:       8,
+		LastStatusCode: 500,
+		LastError:      "unexpected status 500",
+		NextAttemptAt:  now,
+	})
+	assert.NoError(t, err)
+}
+
+func TestWebhookRepo_ClaimDelivery(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	until := now.Add(time.Minute)
+	query := "UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ? AND status = ? AND next_attempt_at <= ?"
+
+	mock.ExpectExec(query).
+		WithArgs(until, 2, models.DeliveryPending, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// another worker claimed the delivery first
+	mock.ExpectExec(query).
+		WithArgs(until, 2, models.DeliveryPending, now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	repo := NewMysqlWebhookRepo(db, time.Second)
+
+	assert.NoError(t, repo.ClaimDelivery(context.Background(), 2, now, until))
+	assert.Equal(t, models.ErrCannotUpdate, repo.ClaimDelivery(context.Background(), 2, now, until))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
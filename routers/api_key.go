@@ -0,0 +1,13 @@
+package routers
+
+import (
+	hd "order-service/handlers"
+	mid "order-service/middlewares"
+	srvauth "order-service/services/auth"
+
+	"github.com/kataras/iris"
+)
+
+func apiKey(app *iris.Application, opts Options) {
+	app.Post("/api-keys", rateLimit(opts, createApiKeyRoute), mid.Authenticate(opts.Authenticator), mid.RequireRole(srvauth.CreateApiKeyRoles...), hd.CreateApiKey(opts.Authenticator))
+}
@@ -0,0 +1,18 @@
+package routers
+
+import (
+	hd "order-service/handlers"
+	mid "order-service/middlewares"
+	srvauth "order-service/services/auth"
+
+	"github.com/kataras/iris"
+)
+
+// driverChannelPath serves long-lived connections, so it is exempt from the request timeout
+const driverChannelPath = "/drivers/channel"
+
+func driver(app *iris.Application, opts Options) {
+	auth := mid.Authenticate(opts.Authenticator)
+
+	app.Get(driverChannelPath, auth, mid.RequireRole(srvauth.DriverChannelRoles...), hd.DriverChannel(opts.OrderService, opts.EventHub, opts.DriverChannel, opts.LegacyOrderIds))
+}
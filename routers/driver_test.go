@@ -0,0 +1,184 @@
+package routers
+
+import (
+	"net/http"
+	nethttptest "net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	hd "order-service/handlers"
+	"order-service/models"
+	"order-service/replica"
+	srvorder "order-service/services"
+	srvmocks "order-service/services/mocks"
+
+	"github.com/gorilla/websocket"
+	"github.com/kataras/iris"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func dialDriverChannel(t *testing.T, app *iris.Application, token string) (*websocket.Conn, *http.Response, func()) {
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+	srv := nethttptest.NewServer(app)
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/drivers/channel", header)
+	if err != nil {
+		srv.Close()
+		return nil, resp, func() {}
+	}
+
+	return conn, resp, func() {
+		conn.Close()
+		srv.Close()
+	}
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) hd.ChannelMessage {
+	var msg hd.ChannelMessage
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+
+	return msg
+}
+
+func newDriverApp(orderService srvorder.OrderService, hub srvorder.EventHub, legacyIds bool) *iris.Application {
+	mockAuth := new(srvmocks.Authenticator)
+	mockAuth.On("AuthenticateToken", mock.Anything, "driver").
+		Return(&models.Principal{Subject: "driver", Role: models.RoleDriver, MerchantId: "merchant-1"}, nil)
+	mockAuth.On("AuthenticateToken", mock.Anything, "customer").
+		Return(&models.Principal{Subject: "customer", Role: models.RoleCustomer, MerchantId: "merchant-1"}, nil)
+
+	app := iris.New()
+	Register(app, Options{
+		OrderService:   orderService,
+		EventHub:       hub,
+		Authenticator:  mockAuth,
+		RequestTimeout: 50 * time.Millisecond,
+		LegacyOrderIds: legacyIds,
+		DriverChannel:  hd.DriverChannelOptions{PingPeriod: time.Second, MessageTimeout: time.Second},
+	})
+
+	return app
+}
+
+func TestDriverChannel(t *testing.T) {
+	events := make(chan models.Event, 10)
+	unsubscribed := make(chan struct{})
+
+	mockHub := new(srvmocks.EventHub)
+	mockHub.On("Subscribe", mock.Anything).
+		Return((<-chan models.Event)(events), func() { close(unsubscribed) }, nil).Once()
+
+	const otherId = "01KDZ3J0K8A7Z4W9X2M5N6P7Q9"
+
+	order := &models.Order{Id: 1, PublicId: publicId, Status: models.StatusUnassigned}
+	mockOrder := new(srvmocks.OrderService)
+	// orders are read from the primary before a take
+	primaryCtx := mock.MatchedBy(replica.Primary)
+	mockOrder.On("GetByPublicId", primaryCtx, publicId).Return(order, nil)
+	mockOrder.On("TakeOrder", mock.Anything, order).Return(order, nil).Once()
+	mockOrder.On("TakeOrder", mock.Anything, order).Return(nil, srvorder.ErrOrderAlreadyTaken).Once()
+	mockOrder.On("GetByPublicId", primaryCtx, otherId).Return(nil, models.ErrNotFound)
+
+	conn, _, closeConn := dialDriverChannel(t, newDriverApp(mockOrder, mockHub, false), "driver")
+	if conn == nil {
+		t.Fatal("failed to open driver channel")
+	}
+	defer closeConn()
+
+	// offers are limited to the area around the driver's location
+	assert.NoError(t, conn.WriteJSON(hd.DriverMessage{Type: hd.MessageLocation, Lat: 22.3193, Lng: 114.1694, Radius: 5000}))
+	time.Sleep(50 * time.Millisecond)
+
+	events <- models.Event{Id: 6, Type: models.EventTypeOrderPlaced, OrderId: 2, Payload: []byte(`{"id":"` + otherId + `"}`), Origins: []float64{23.2193, 114.1694}}
+	events <- models.Event{Id: 7, Type: models.EventTypeOrderPlaced, OrderId: 1, Payload: []byte(`{"id":"` + publicId + `"}`), Origins: []float64{22.3193, 114.1694}}
+	events <- models.Event{Id: 8, Type: models.EventTypeOrderTaken, OrderId: 2, Payload: []byte(`{"id":"` + otherId + `"}`), Origins: []float64{23.2193, 114.1694}}
+
+	// orders are sent with the id of the REST API, not the sequential id of the event
+	msg := readMessage(t, conn)
+	assert.Equal(t, hd.MessageOffer, msg.Type)
+	assert.Equal(t, int64(7), msg.EventId)
+	assert.Equal(t, publicId, msg.OrderId)
+	assert.JSONEq(t, `{"id":"`+publicId+`"}`, string(msg.Order))
+
+	msg = readMessage(t, conn)
+	assert.Equal(t, hd.MessageUpdate, msg.Type)
+	assert.Equal(t, int64(8), msg.EventId)
+	assert.Equal(t, otherId, msg.OrderId)
+
+	assert.NoError(t, conn.WriteJSON(hd.DriverMessage{Type: hd.MessageTake, Ref: "a", OrderId: publicId}))
+	msg = readMessage(t, conn)
+	assert.Equal(t, hd.ChannelMessage{Type: hd.MessageResult, Ref: "a", OrderId: publicId, Status: hd.ResultSuccess}, msg)
+
+	assert.NoError(t, conn.WriteJSON(hd.DriverMessage{Type: hd.MessageAccept, Ref: "b", OrderId: publicId}))
+	msg = readMessage(t, conn)
+	assert.Equal(t, hd.ResultAlreadyTaken, msg.Status)
+	assert.Equal(t, "b", msg.Ref)
+
+	assert.NoError(t, conn.WriteJSON(hd.DriverMessage{Type: hd.MessageTake, Ref: "c", OrderId: otherId}))
+	assert.Equal(t, hd.ResultNotFound, readMessage(t, conn).Status)
+
+	// sequential ids are not accepted without legacy ids
+	assert.NoError(t, conn.WriteJSON(hd.DriverMessage{Type: hd.MessageTake, Ref: "e", OrderId: "1"}))
+	msg = readMessage(t, conn)
+	assert.Equal(t, hd.MessageError, msg.Type)
+	assert.Equal(t, "e", msg.Ref)
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("{")))
+	assert.Equal(t, hd.MessageError, readMessage(t, conn).Type)
+
+	assert.NoError(t, conn.WriteJSON(hd.DriverMessage{Type: "dance", Ref: "d"}))
+	msg = readMessage(t, conn)
+	assert.Equal(t, hd.MessageError, msg.Type)
+	assert.Equal(t, "d", msg.Ref)
+
+	// closing the connection ends the subscription
+	conn.Close()
+	select {
+	case <-unsubscribed:
+	case <-time.After(time.Second):
+		t.Fatal("subscription was not ended")
+	}
+	mockOrder.AssertExpectations(t)
+}
+
+func TestDriverChannel_LegacyOrderIds(t *testing.T) {
+	mockHub := new(srvmocks.EventHub)
+	mockHub.On("Subscribe", mock.Anything).
+		Return((<-chan models.Event)(make(chan models.Event)), func() {}, nil).Once()
+
+	order := &models.Order{Id: 1, PublicId: publicId, Status: models.StatusUnassigned}
+	mockOrder := new(srvmocks.OrderService)
+	mockOrder.On("GetById", mock.MatchedBy(replica.Primary), int64(1)).Return(order, nil).Once()
+	mockOrder.On("TakeOrder", mock.Anything, order).Return(order, nil).Once()
+
+	conn, _, closeConn := dialDriverChannel(t, newDriverApp(mockOrder, mockHub, true), "driver")
+	if conn == nil {
+		t.Fatal("failed to open driver channel")
+	}
+	defer closeConn()
+
+	assert.NoError(t, conn.WriteJSON(hd.DriverMessage{Type: hd.MessageTake, Ref: "a", OrderId: "1"}))
+	assert.Equal(t, hd.ResultSuccess, readMessage(t, conn).Status)
+	mockOrder.AssertExpectations(t)
+}
+
+func TestDriverChannel_Forbidden(t *testing.T) {
+	conn, resp, closeConn := dialDriverChannel(t, newDriverApp(new(srvmocks.OrderService), new(srvmocks.EventHub), false), "customer")
+	defer closeConn()
+
+	assert.Nil(t, conn)
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, iris.StatusForbidden, resp.StatusCode)
+	}
+}
@@ -0,0 +1,10 @@
+package routers
+
+import (
+	"github.com/kataras/iris"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func metrics(app *iris.Application) {
+	app.Get("/metrics", iris.FromStd(promhttp.Handler()))
+}
@@ -0,0 +1,288 @@
+package routers
+
+import (
+	"strconv"
+	"strings"
+
+	hd "order-service/handlers"
+	mid "order-service/middlewares"
+	"order-service/models"
+	"order-service/openapi"
+	"order-service/problem"
+	"order-service/services"
+	srvauth "order-service/services/auth"
+
+	"github.com/kataras/iris"
+)
+
+const openapiPath = "/openapi.json"
+
+func openAPI(app *iris.Application) {
+	doc := apiSpec()
+
+	app.Get(openapiPath, func(ctx iris.Context) {
+		ctx.JSON(doc)
+	})
+}
+
+// apiSpec describes every route registered by Register, request and response bodies are
+// generated from the types the handlers read and write
+func apiSpec() *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:       "Order service",
+		Description: "Place, take and list delivery orders.",
+		Version:     "1.0.0",
+	})
+	doc.Components.SecuritySchemes["bearerAuth"] = &openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+	doc.Components.SecuritySchemes["apiKey"] = &openapi.SecurityScheme{Type: "apiKey", Name: mid.ApiKeyHeader, In: "header"}
+
+	errorResp := doc.Schema(problem.Problem{})
+	order := doc.Schema(models.Order{})
+
+	doc.Add("GET", "/", &openapi.Operation{
+		OperationId: "home",
+		Summary:     "Health check",
+		Tags:        []string{"service"},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "The service is up", Content: openapi.JSON(doc.Schema(hd.StatusResp{}))},
+		},
+	})
+	doc.Add("GET", "/metrics", &openapi.Operation{
+		OperationId: "metrics",
+		Summary:     "Prometheus metrics",
+		Tags:        []string{"service"},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "Metrics in the Prometheus text format", Content: map[string]*openapi.MediaType{
+				"text/plain": {Schema: &openapi.Schema{Type: "string"}},
+			}},
+		},
+	})
+	doc.Add("GET", openapiPath, &openapi.Operation{
+		OperationId: "openapi",
+		Summary:     "This document",
+		Tags:        []string{"service"},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "The OpenAPI document of the service", Content: openapi.JSON(&openapi.Schema{Type: "object"})},
+		},
+	})
+
+	doc.Add("POST", "/orders", secured(&openapi.Operation{
+		OperationId: "placeOrder",
+		Summary:     "Place an order",
+		Description: "The distance is calculated between origin and destination.",
+		Tags:        []string{"orders"},
+		Parameters: []*openapi.Parameter{{
+			Name:        mid.IdempotencyKeyHeader,
+			In:          "header",
+			Description: "Retries with the same key replay the first response instead of placing another order.",
+			Schema:      &openapi.Schema{Type: "string"},
+		}},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(doc.Schema(hd.PlaceOrderReq{}))},
+		Responses: errorResponses(errorResp, placeOrderRoute, map[string]*openapi.Response{
+			"200": {Description: "The placed order", Content: openapi.JSON(order)},
+			"400": {Description: "Invalid body or the distance cannot be calculated"},
+			"409": {Description: "A request with the same idempotency key is in progress"},
+			"422": {Description: "The idempotency key was used for a different request"},
+		}),
+	}, srvauth.PlaceOrderRoles))
+	doc.Add("GET", "/orders/:id", secured(&openapi.Operation{
+		OperationId: "getOrder",
+		Summary:     "Get an order",
+		Description: "The ETag of the response is the version of the order.",
+		Tags:        []string{"orders"},
+		Parameters:  append([]*openapi.Parameter{orderIdParameter(), includeArchivedParameter()}, preconditionParameters()...),
+		Responses: errorResponses(errorResp, getOrderRoute, map[string]*openapi.Response{
+			"200": {Description: "The order", Headers: etagHeader(), Content: openapi.JSON(order)},
+			"304": {Description: "The order still has the version of If-None-Match", Headers: etagHeader()},
+			"400": {Description: "Invalid id or include_archived"},
+			"404": {Description: "The order does not exist"},
+			"412": {Description: "The order no longer has the version of If-Match"},
+		}),
+	}, srvauth.GetOrderRoles))
+	doc.Add("PATCH", "/orders/:id", secured(&openapi.Operation{
+		OperationId: "takeOrder",
+		Summary:     "Take an order",
+		Description: "With If-Match, the order is only taken if it still has the given version.",
+		Tags:        []string{"orders"},
+		Parameters:  append([]*openapi.Parameter{orderIdParameter()}, preconditionParameters()...),
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(doc.Schema(hd.TakeOrderReq{}))},
+		Responses: errorResponses(errorResp, takeOrderRoute, map[string]*openapi.Response{
+			"200": {Description: "The order was taken", Headers: etagHeader(), Content: openapi.JSON(doc.Schema(hd.StatusResp{}))},
+			"400": {Description: "Invalid id or body"},
+			"404": {Description: "The order does not exist"},
+			"409": {Description: "The order was already taken"},
+			"412": {Description: "The order no longer has the version of If-Match"},
+		}),
+	}, srvauth.TakeOrderRoles))
+	doc.Add("GET", "/orders", secured(&openapi.Operation{
+		OperationId: "listOrders",
+		Summary:     "List orders",
+		Tags:        []string{"orders"},
+		Parameters:  append(pageParameters(), includeArchivedParameter()),
+		Responses: errorResponses(errorResp, listOrdersRoute, map[string]*openapi.Response{
+			"200": {Description: "A page of orders by id", Content: openapi.JSON(&openapi.Schema{Type: "array", Items: order})},
+			"400": {Description: "Invalid page or limit"},
+		}),
+	}, srvauth.ListOrdersRoles))
+	doc.Add("GET", streamOrdersPath, secured(&openapi.Operation{
+		OperationId: "streamOrders",
+		Summary:     "Stream order events",
+		Description: "A server-sent events stream of the OrderPlaced and OrderTaken events of the merchant's orders.",
+		Tags:        []string{"orders"},
+		Parameters: []*openapi.Parameter{
+			{Name: "lat", In: "query", Description: "Latitude of the area, given with lng and radius", Schema: &openapi.Schema{Type: "number", Format: "double"}},
+			{Name: "lng", In: "query", Description: "Longitude of the area, given with lat and radius", Schema: &openapi.Schema{Type: "number", Format: "double"}},
+			{Name: "radius", In: "query", Description: "Radius of the area in meters, only events of orders originating within it are sent", Schema: &openapi.Schema{Type: "number", Format: "double"}},
+			{Name: "Last-Event-ID", In: "header", Description: "Resumes the stream after this event", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+		},
+		Responses: errorResponses(errorResp, "", map[string]*openapi.Response{
+			"200": {Description: "The event stream", Content: map[string]*openapi.MediaType{
+				"text/event-stream": {Schema: &openapi.Schema{Type: "string"}},
+			}},
+			"400": {Description: "Invalid area or Last-Event-ID"},
+		}),
+	}, srvauth.StreamOrdersRoles))
+
+	doc.Add("POST", "/api-keys", secured(&openapi.Operation{
+		OperationId: "createApiKey",
+		Summary:     "Create an api key",
+		Tags:        []string{"auth"},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(doc.Schema(hd.CreateApiKeyReq{}))},
+		Responses: errorResponses(errorResp, createApiKeyRoute, map[string]*openapi.Response{
+			"201": {Description: "The api key, the plain key is only returned here", Content: openapi.JSON(doc.Schema(hd.CreateApiKeyResp{}))},
+			"400": {Description: "Invalid body"},
+		}),
+	}, srvauth.CreateApiKeyRoles))
+
+	doc.Add("POST", "/webhooks", secured(&openapi.Operation{
+		OperationId: "createWebhook",
+		Summary:     "Subscribe a webhook to order events",
+		Tags:        []string{"webhooks"},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(doc.Schema(hd.CreateWebhookReq{}))},
+		Responses: errorResponses(errorResp, "", map[string]*openapi.Response{
+			"201": {Description: "The webhook", Content: openapi.JSON(doc.Schema(models.Webhook{}))},
+			"400": {Description: "Invalid body"},
+		}),
+	}, srvauth.ManageWebhookRoles))
+	doc.Add("GET", "/webhooks/:id/deliveries", secured(&openapi.Operation{
+		OperationId: "listWebhookDeliveries",
+		Summary:     "List the deliveries of a webhook",
+		Tags:        []string{"webhooks"},
+		Parameters:  append([]*openapi.Parameter{idParameter("webhook")}, pageParameters()...),
+		Responses: errorResponses(errorResp, "", map[string]*openapi.Response{
+			"200": {Description: "A page of deliveries, newest first", Content: openapi.JSON(&openapi.Schema{Type: "array", Items: doc.Schema(models.WebhookDelivery{})})},
+			"400": {Description: "Invalid id, page or limit"},
+			"404": {Description: "The webhook does not exist"},
+		}),
+	}, srvauth.ManageWebhookRoles))
+
+	doc.Add("GET", driverChannelPath, secured(&openapi.Operation{
+		OperationId: "driverChannel",
+		Summary:     "Open the driver channel",
+		Description: "Upgrades to a WebSocket connection exchanging JSON messages, see the README for the protocol.",
+		Tags:        []string{"drivers"},
+		Responses: errorResponses(errorResp, "", map[string]*openapi.Response{
+			"101": {Description: "Switched to the WebSocket protocol"},
+		}),
+	}, srvauth.DriverChannelRoles))
+
+	return doc
+}
+
+// errorResponses adds the error responses shared by the authenticated routes to responses,
+// and the rate limit response if route is rate limited. Errors are problem details
+func errorResponses(errorResp *openapi.Schema, route string, responses map[string]*openapi.Response) map[string]*openapi.Response {
+	shared := map[string]string{
+		"401": "Missing or invalid credentials",
+		"403": "The role of the caller is not allowed",
+		"500": "Internal error",
+		"504": "The request timed out",
+	}
+	if route != "" {
+		shared["429"] = "Too many requests, retry after the Retry-After header"
+	}
+
+	for code, description := range shared {
+		if responses[code] == nil {
+			responses[code] = &openapi.Response{Description: description}
+		}
+	}
+
+	for code, response := range responses {
+		if code >= "400" && response.Content == nil {
+			response.Content = map[string]*openapi.MediaType{problem.ContentType: {Schema: errorResp}}
+		}
+	}
+
+	return responses
+}
+
+// secured requires either a bearer token or an api key for op, and lists the roles allowed to call it
+func secured(op *openapi.Operation, roles []string) *openapi.Operation {
+	op.Security = []map[string][]string{
+		{"bearerAuth": {}},
+		{"apiKey": {}},
+	}
+
+	if op.Description != "" {
+		op.Description += " "
+	}
+	op.Description += "Allowed roles: " + strings.Join(roles, ", ") + "."
+
+	return op
+}
+
+func idParameter(resource string) *openapi.Parameter {
+	return &openapi.Parameter{
+		Name:        "id",
+		In:          "path",
+		Description: "Id of the " + resource,
+		Required:    true,
+		Schema:      &openapi.Schema{Type: "integer", Format: "int64"},
+	}
+}
+
+// orderIdParameter is the public id of an order, or its sequential id while legacy ids are accepted
+func orderIdParameter() *openapi.Parameter {
+	length := 26
+	return &openapi.Parameter{
+		Name:        "id",
+		In:          "path",
+		Description: "Public id of the order, a ULID. The sequential ids of orders are still accepted while clients migrate",
+		Required:    true,
+		Schema:      &openapi.Schema{Type: "string", MaxLength: &length},
+	}
+}
+
+func includeArchivedParameter() *openapi.Parameter {
+	return &openapi.Parameter{
+		Name:        "include_archived",
+		In:          "query",
+		Description: "Also return the orders moved to the archive",
+		Schema:      &openapi.Schema{Type: "boolean"},
+	}
+}
+
+// preconditionParameters are the headers of the requests conditional on the version of the resource
+func preconditionParameters() []*openapi.Parameter {
+	return []*openapi.Parameter{
+		{Name: "If-Match", In: "header", Description: "Only serve the request if the ETag of the resource is one of these", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "If-None-Match", In: "header", Description: "Only serve the request if the ETag of the resource is none of these", Schema: &openapi.Schema{Type: "string"}},
+	}
+}
+
+func etagHeader() map[string]*openapi.Header {
+	return map[string]*openapi.Header{
+		"ETag": {Description: "The version of the order", Schema: &openapi.Schema{Type: "string"}},
+	}
+}
+
+func pageParameters() []*openapi.Parameter {
+	one := float64(1)
+	zero := float64(0)
+
+	return []*openapi.Parameter{
+		{Name: "page", In: "query", Description: "Page number, starting at 1", Required: true, Schema: &openapi.Schema{Type: "integer", Minimum: &one}},
+		{Name: "limit", In: "query", Description: "Number of items per page, larger limits are capped to " + strconv.Itoa(services.MaxPageLimit), Required: true, Schema: &openapi.Schema{Type: "integer", Minimum: &zero}},
+	}
+}
@@ -0,0 +1,51 @@
+package routers
+
+import (
+	"strings"
+	"testing"
+
+	"order-service/openapi"
+
+	"github.com/kataras/iris"
+	"github.com/kataras/iris/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPI_DescribesEveryRoute(t *testing.T) {
+	app := iris.New()
+	Register(app, Options{})
+
+	doc := apiSpec()
+	registered := make(map[string]bool)
+
+	for _, r := range app.GetRoutes() {
+		registered[strings.ToLower(r.Method)+" "+openapi.Path(r.Path)] = true
+
+		assert.NotNil(t, doc.Operation(r.Method, r.Path), "route %s %s is missing from the OpenAPI document", r.Method, r.Path)
+	}
+
+	// and the document does not describe routes which no longer exist
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			assert.True(t, registered[method+" "+path], "operation %s %s is not registered", method, path)
+		}
+	}
+}
+
+func TestOpenAPI_Serve(t *testing.T) {
+	app := iris.New()
+	Register(app, Options{})
+
+	e := httptest.New(t, app)
+	doc := e.GET("/openapi.json").Expect().Status(iris.StatusOK).JSON().Object()
+
+	doc.ValueEqual("openapi", openapi.Version)
+	doc.Value("paths").Object().Value("/orders/{id}").Object().Path("$.patch.operationId").Equal("takeOrder")
+
+	// request bodies follow the validation of the handlers
+	placeOrder := doc.Path("$.components.schemas.PlaceOrderReq").Object()
+	placeOrder.Value("required").Array().ContainsOnly("origin", "destination")
+	placeOrder.Path("$.properties.origin.minItems").Equal(2)
+	placeOrder.Path("$.properties.origin.maxItems").Equal(2)
+	doc.Path("$.components.schemas.TakeOrderReq.properties.status.enum").Array().ContainsOnly("TAKEN")
+}
@@ -3,13 +3,20 @@ package routers
 import (
 	hd "order-service/handlers"
 	mid "order-service/middlewares"
-	srvorder "order-service/services"
+	srvauth "order-service/services/auth"
 
 	"github.com/kataras/iris"
 )
 
-func order(app *iris.Application, orderService srvorder.OrderService) {
-	app.Post("/orders", hd.PlaceOrder(orderService))
-	app.Patch("/orders/:id", mid.FetchOrder(orderService), hd.TakeOrder(orderService))
-	app.Get("/orders", mid.Paginate, hd.ListOrders(orderService))
+// streamOrdersPath serves a long-lived response, so it is exempt from the request timeout
+const streamOrdersPath = "/orders/stream"
+
+func order(app *iris.Application, opts Options) {
+	auth := mid.Authenticate(opts.Authenticator)
+
+	app.Post("/orders", rateLimit(opts, placeOrderRoute), auth, mid.RequireRole(srvauth.PlaceOrderRoles...), mid.Idempotency(opts.IdempotencyService), hd.PlaceOrder(opts.OrderService))
+	app.Get("/orders/:id", rateLimit(opts, getOrderRoute), auth, mid.RequireRole(srvauth.GetOrderRoles...), mid.IncludeArchived, mid.FetchOrder(opts.OrderService, opts.LegacyOrderIds), mid.OrderPreconditions, hd.GetOrder)
+	app.Patch("/orders/:id", rateLimit(opts, takeOrderRoute), auth, mid.RequireRole(srvauth.TakeOrderRoles...), mid.FetchOrder(opts.OrderService, opts.LegacyOrderIds), mid.OrderPreconditions, hd.TakeOrder(opts.OrderService))
+	app.Get("/orders", rateLimit(opts, listOrdersRoute), auth, mid.RequireRole(srvauth.ListOrdersRoles...), mid.Paginate, mid.IncludeArchived, hd.ListOrders(opts.OrderService))
+	app.Get(streamOrdersPath, auth, mid.RequireRole(srvauth.StreamOrdersRoles...), hd.StreamOrders(opts.OrderEventService, opts.Stream))
 }
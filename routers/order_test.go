@@ -0,0 +1,311 @@
+package routers
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"order-service/archive"
+	"order-service/models"
+	"order-service/problem"
+	"order-service/repositories"
+	"order-service/services"
+	srvmocks "order-service/services/mocks"
+	srvorder "order-service/services/order"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/iris-contrib/httpexpect"
+	"github.com/kataras/iris"
+	"github.com/kataras/iris/httptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestTakeOrder_OtherMerchant(t *testing.T) {
+	db, sqlMock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// order 1 belongs to merchant-1, it does not exist for merchant-2
+	sqlMock.ExpectQuery("SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL").
+		WithArgs(1, "merchant-2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "public_id", "merchant_id", "distance", "status", "created_at", "updated_at", "version"}))
+
+	mockAuth := new(srvmocks.Authenticator)
+	mockAuth.On("AuthenticateToken", mock.Anything, "merchant-2-driver").
+		Return(&models.Principal{Subject: "driver", Role: models.RoleDriver, MerchantId: "merchant-2"}, nil)
+
+	app := iris.New()
+	Register(app, Options{
+		OrderService:   srvorder.NewOrderService(repositories.NewMysqlOrderRepo(db, time.Second), new(srvmocks.DistanceCalculator)),
+		Authenticator:  mockAuth,
+		LegacyOrderIds: true,
+	})
+
+	e := httptest.New(t, app)
+	e.PATCH("/orders/1").
+		WithHeader("Authorization", "Bearer merchant-2-driver").
+		WithJSON(map[string]string{"status": models.StatusTaken}).
+		Expect().
+		Status(iris.StatusNotFound)
+
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestOrders_ReadReplica(t *testing.T) {
+	primary, primaryMock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer primary.Close()
+
+	stale, staleMock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		assert.NoErrorf(t, err, "an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer stale.Close()
+
+	// the replica has not caught up with order 1 yet, it is seen by the listing and a plain read
+	staleMock.ExpectQuery("SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE merchant_id = ? AND deleted_at IS NULL ORDER BY id ASC LIMIT ?, ?").
+		WithArgs("merchant-1", 0, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "public_id", "merchant_id", "distance", "status", "created_at", "updated_at", "version"}))
+	staleMock.ExpectQuery("SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL").
+		WithArgs(1, "merchant-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "public_id", "merchant_id", "distance", "status", "created_at", "updated_at", "version"}))
+
+	// a conditional read checks the current version, so it reads the order from the primary
+	primaryMock.ExpectQuery("SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL").
+		WithArgs(1, "merchant-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "public_id", "merchant_id", "distance", "status", "created_at", "updated_at", "version"}).AddRow(1, nil, "merchant-1", 100, models.StatusUnassigned, time.Now(), time.Now(), 1))
+
+	// the take reads the order from the primary
+	primaryMock.ExpectQuery("SELECT id, public_id, merchant_id, distance, status, created_at, updated_at, version FROM orders WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL").
+		WithArgs(1, "merchant-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "public_id", "merchant_id", "distance", "status", "created_at", "updated_at", "version"}).AddRow(1, nil, "merchant-1", 100, models.StatusUnassigned, time.Now(), time.Now(), 1))
+	// the take runs in a transaction, which prepares the statements of the repository first
+	primaryMock.ExpectPrepare("UPDATE orders SET status = ?, updated_at = ?, version = version + 1 where id = ? AND merchant_id = ? AND status = ? AND deleted_at IS NULL")
+	prep := primaryMock.ExpectPrepare("UPDATE orders SET status = ?, updated_at = ?, version = version + 1 where id = ? AND merchant_id = ? AND status = ? AND deleted_at IS NULL AND version = ?")
+	primaryMock.ExpectPrepare("INSERT INTO orders (public_id, merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, distance, status, created_at, updated_at, version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)")
+	primaryMock.ExpectPrepare("UPDATE orders SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL")
+	primaryMock.ExpectBegin()
+	prep.ExpectExec().
+		WithArgs(models.StatusTaken, sqlmock.AnyArg(), 1, "merchant-1", models.StatusUnassigned, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	primaryMock.ExpectExec("INSERT INTO outbox (event_type, order_id, merchant_id, payload) VALUES (?, ?, ?, ?)").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	primaryMock.ExpectCommit()
+
+	mockAuth := new(srvmocks.Authenticator)
+	mockAuth.On("AuthenticateToken", mock.Anything, "driver").
+		Return(&models.Principal{Subject: "driver", Role: models.RoleDriver, MerchantId: "merchant-1"}, nil)
+	mockAuth.On("AuthenticateToken", mock.Anything, "ops").
+		Return(&models.Principal{Subject: "ops", Role: models.RoleOps, MerchantId: "merchant-1"}, nil)
+
+	orderRepo := repositories.NewMysqlOrderRepo(primary, time.Second)
+	orderRepo.Replicas = []*sql.DB{stale}
+
+	app := iris.New()
+	Register(app, Options{
+		OrderService:   srvorder.NewOrderService(orderRepo, new(srvmocks.DistanceCalculator)),
+		Authenticator:  mockAuth,
+		LegacyOrderIds: true,
+	})
+
+	e := httptest.New(t, app)
+	e.GET("/orders").
+		WithQuery("page", 1).
+		WithQuery("limit", 10).
+		WithHeader("Authorization", "Bearer ops").
+		Expect().
+		Status(iris.StatusOK)
+
+	e.GET("/orders/1").
+		WithHeader("Authorization", "Bearer ops").
+		Expect().
+		Status(iris.StatusNotFound)
+
+	e.GET("/orders/1").
+		WithHeader("Authorization", "Bearer ops").
+		WithHeader("If-Match", "*").
+		Expect().
+		Status(iris.StatusOK)
+
+	e.PATCH("/orders/1").
+		WithHeader("Authorization", "Bearer driver").
+		WithJSON(map[string]string{"status": models.StatusTaken}).
+		Expect().
+		Status(iris.StatusOK)
+
+	assert.NoError(t, staleMock.ExpectationsWereMet())
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+}
+
+func TestListOrders_LimitIsCapped(t *testing.T) {
+	mockAuth := new(srvmocks.Authenticator)
+	mockAuth.On("AuthenticateToken", mock.Anything, "ops").
+		Return(&models.Principal{Subject: "ops", Role: models.RoleOps, MerchantId: "merchant-1"}, nil)
+	mockOrderSrv := new(srvmocks.OrderService)
+	mockOrderSrv.On("ListOrders", mock.Anything, services.MaxPageLimit, services.MaxPageLimit).Return([]models.Order{}, nil).Once()
+
+	app := iris.New()
+	Register(app, Options{
+		OrderService:  mockOrderSrv,
+		Authenticator: mockAuth,
+	})
+
+	e := httptest.New(t, app)
+	e.GET("/orders").
+		WithQuery("page", 2).
+		WithQuery("limit", 100000).
+		WithHeader("Authorization", "Bearer ops").
+		Expect().
+		Status(iris.StatusOK)
+
+	mockOrderSrv.AssertExpectations(t)
+}
+
+func TestListOrders_IncludeArchived(t *testing.T) {
+	mockAuth := new(srvmocks.Authenticator)
+	mockAuth.On("AuthenticateToken", mock.Anything, "ops").
+		Return(&models.Principal{Subject: "ops", Role: models.RoleOps, MerchantId: "merchant-1"}, nil)
+	mockOrderSrv := new(srvmocks.OrderService)
+	mockOrderSrv.On("ListOrders", mock.MatchedBy(archive.Included), 0, 10).
+		Return([]models.Order{{Id: 1, Distance: 100, Status: models.StatusTaken}}, nil).Once()
+	mockOrderSrv.On("ListOrders", mock.MatchedBy(func(ctx context.Context) bool { return !archive.Included(ctx) }), 0, 10).
+		Return([]models.Order{}, nil).Once()
+
+	app := iris.New()
+	Register(app, Options{
+		OrderService:  mockOrderSrv,
+		Authenticator: mockAuth,
+	})
+
+	e := httptest.New(t, app)
+	list := func(includeArchived string) *httpexpect.Response {
+		req := e.GET("/orders").
+			WithQuery("page", 1).
+			WithQuery("limit", 10).
+			WithHeader("Authorization", "Bearer ops")
+		if includeArchived != "" {
+			req = req.WithQuery("include_archived", includeArchived)
+		}
+		return req.Expect()
+	}
+
+	list("true").Status(iris.StatusOK).JSON().Array().Length().Equal(1)
+	list("").Status(iris.StatusOK).JSON().Array().Empty()
+	list("maybe").Status(iris.StatusBadRequest)
+
+	mockOrderSrv.AssertExpectations(t)
+}
+
+func TestGetOrder_ETag(t *testing.T) {
+	placedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	mockOrderSrv := new(srvmocks.OrderService)
+	mockOrderSrv.On("GetByPublicId", mock.Anything, publicId).
+		Return(&models.Order{Id: 1, PublicId: publicId, Distance: 100, Status: models.StatusTaken, CreatedAt: placedAt, UpdatedAt: placedAt, Version: 2}, nil)
+
+	e := httptest.New(t, newProblemApp(mockOrderSrv))
+	get := func(header, value string) *httpexpect.Response {
+		req := e.GET("/orders/"+publicId).WithHeader("Authorization", "Bearer customer")
+		if header != "" {
+			req = req.WithHeader(header, value)
+		}
+		return req.Expect()
+	}
+
+	resp := get("", "")
+	resp.Status(iris.StatusOK).Header("ETag").Equal(`"2"`)
+	body := resp.JSON().Object()
+	// the sequential id is never exposed
+	body.ValueEqual("id", publicId)
+	body.ValueEqual("version", 2)
+	body.ValueEqual("created_at", "2026-01-02T03:04:05Z")
+	body.ValueEqual("updated_at", "2026-01-02T03:04:05Z")
+
+	get("If-None-Match", `"1", W/"2"`).Status(iris.StatusNotModified).Header("ETag").Equal(`"2"`)
+	get("If-None-Match", `"1"`).Status(iris.StatusOK)
+	get("If-Match", `"2"`).Status(iris.StatusOK)
+	get("If-Match", "*").Status(iris.StatusOK)
+
+	p := decodeProblem(t, get("If-Match", `"1"`).Status(iris.StatusPreconditionFailed))
+	assert.Equal(t, problem.CodePreconditionFailed, p.Code)
+	// If-Match compares strongly, a weak tag never matches
+	get("If-Match", `W/"2"`).Status(iris.StatusPreconditionFailed)
+}
+
+func TestTakeOrder_IfMatch(t *testing.T) {
+	mockOrderSrv := new(srvmocks.OrderService)
+	mockOrderSrv.On("GetById", mock.Anything, int64(1)).
+		Return(&models.Order{Id: 1, Distance: 100, Status: models.StatusUnassigned, Version: 2}, nil)
+	mockOrderSrv.On("TakeOrder", mock.Anything, mock.MatchedBy(func(order *models.Order) bool { return order.Version == 2 })).
+		Return(&models.Order{Id: 1, Distance: 100, Status: models.StatusTaken, Version: 3}, nil).Once()
+
+	e := httptest.New(t, newProblemApp(mockOrderSrv))
+	take := func(ifMatch string) *httpexpect.Response {
+		return e.PATCH("/orders/1").
+			WithHeader("Authorization", "Bearer driver").
+			WithHeader("If-Match", ifMatch).
+			WithJSON(map[string]string{"status": models.StatusTaken}).
+			Expect()
+	}
+
+	// the order was changed since the client read version 1, it is not taken
+	p := decodeProblem(t, take(`"1"`).Status(iris.StatusPreconditionFailed))
+	assert.Equal(t, problem.CodePreconditionFailed, p.Code)
+
+	take(`"2"`).Status(iris.StatusOK).Header("ETag").Equal(`"3"`)
+
+	mockOrderSrv.AssertExpectations(t)
+}
+
+// publicId is the public id of the orders of the tests
+const publicId = "01KDZ3J0K8A7Z4W9X2M5N6P7Q8"
+
+func TestFetchOrder_Ids(t *testing.T) {
+	mockOrderSrv := new(srvmocks.OrderService)
+	mockOrderSrv.On("GetByPublicId", mock.Anything, publicId).
+		Return(&models.Order{Id: 1, PublicId: publicId, Distance: 100, Status: models.StatusTaken, Version: 1}, nil)
+	mockOrderSrv.On("GetByPublicId", mock.Anything, "01ARZ3NDEKTSV4RRFFQ69G5FAV").
+		Return(nil, models.ErrNotFound)
+	mockOrderSrv.On("GetById", mock.Anything, int64(1)).
+		Return(&models.Order{Id: 1, PublicId: publicId, Distance: 100, Status: models.StatusTaken, Version: 1}, nil)
+
+	mockAuth := new(srvmocks.Authenticator)
+	mockAuth.On("AuthenticateToken", mock.Anything, "customer").
+		Return(&models.Principal{Subject: "customer", Role: models.RoleCustomer, MerchantId: "merchant-1"}, nil)
+
+	for _, legacyIds := range []bool{true, false} {
+		app := iris.New()
+		Register(app, Options{
+			OrderService:   mockOrderSrv,
+			Authenticator:  mockAuth,
+			LegacyOrderIds: legacyIds,
+		})
+
+		e := httptest.New(t, app)
+		get := func(id string) *httpexpect.Response {
+			return e.GET("/orders/"+id).WithHeader("Authorization", "Bearer customer").Expect()
+		}
+
+		get(publicId).Status(iris.StatusOK).JSON().Object().ValueEqual("id", publicId)
+		// ULIDs are case insensitive
+		get(strings.ToLower(publicId)).Status(iris.StatusOK).JSON().Object().ValueEqual("id", publicId)
+		get("01ARZ3NDEKTSV4RRFFQ69G5FAV").Status(iris.StatusNotFound)
+		get("abc").Status(iris.StatusBadRequest)
+
+		if legacyIds {
+			// the response carries the public id clients should switch to
+			get("1").Status(iris.StatusOK).JSON().Object().ValueEqual("id", publicId)
+		} else {
+			p := decodeProblem(t, get("1").Status(iris.StatusBadRequest))
+			assert.Equal(t, problem.CodeInvalidParameter, p.Code)
+		}
+	}
+
+	mockOrderSrv.AssertNumberOfCalls(t, "GetById", 1)
+}
@@ -0,0 +1,107 @@
+package routers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"order-service/models"
+	"order-service/problem"
+	srvorder "order-service/services"
+	srvmocks "order-service/services/mocks"
+
+	"github.com/iris-contrib/httpexpect"
+	"github.com/kataras/iris"
+	"github.com/kataras/iris/httptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// decodeProblem asserts resp is a problem details response and returns its body
+func decodeProblem(t *testing.T, resp *httpexpect.Response) problem.Problem {
+	t.Helper()
+
+	resp.ContentType(problem.ContentType)
+
+	var p problem.Problem
+	if err := json.Unmarshal([]byte(resp.Body().Raw()), &p); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, resp.Raw().StatusCode, p.Status)
+	assert.Equal(t, "about:blank", p.Type)
+
+	return p
+}
+
+func newProblemApp(orderService srvorder.OrderService) *iris.Application {
+	mockAuth := new(srvmocks.Authenticator)
+	mockAuth.On("AuthenticateToken", mock.Anything, "customer").
+		Return(&models.Principal{Subject: "customer", Role: models.RoleCustomer, MerchantId: "merchant-1"}, nil)
+	mockAuth.On("AuthenticateToken", mock.Anything, "driver").
+		Return(&models.Principal{Subject: "driver", Role: models.RoleDriver, MerchantId: "merchant-1"}, nil)
+	mockAuth.On("AuthenticateToken", mock.Anything, mock.Anything).Return(nil, srvorder.ErrUnauthenticated)
+
+	app := iris.New()
+	Register(app, Options{
+		OrderService:   orderService,
+		Authenticator:  mockAuth,
+		LegacyOrderIds: true,
+	})
+
+	return app
+}
+
+func TestProblem_Validation(t *testing.T) {
+	e := httptest.New(t, newProblemApp(new(srvmocks.OrderService)))
+
+	resp := e.POST("/orders").
+		WithHeader("Authorization", "Bearer customer").
+		WithJSON(map[string]interface{}{"origin": []string{"95", "114.1694"}}).
+		Expect().
+		Status(iris.StatusBadRequest)
+
+	p := decodeProblem(t, resp)
+	assert.Equal(t, problem.CodeValidationFailed, p.Code)
+	assert.Equal(t, "/orders", p.Instance)
+	assert.NotEmpty(t, p.RequestId)
+	assert.Equal(t, []problem.FieldError{
+		{Field: "origin", Code: "location", Message: "must be a latitude and a longitude between -90 and 90, and -180 and 180"},
+		{Field: "destination", Code: "required", Message: "is required"},
+	}, p.Errors)
+
+	resp = e.POST("/orders").
+		WithHeader("Authorization", "Bearer customer").
+		WithBytes([]byte("{")).
+		Expect().
+		Status(iris.StatusBadRequest)
+	assert.Equal(t, problem.CodeInvalidJSON, decodeProblem(t, resp).Code)
+}
+
+func TestProblem_Middlewares(t *testing.T) {
+	mockOrder := new(srvmocks.OrderService)
+	mockOrder.On("GetById", mock.Anything, int64(1)).Return(nil, models.ErrNotFound)
+
+	e := httptest.New(t, newProblemApp(mockOrder))
+
+	tests := []struct {
+		name   string
+		req    *httpexpect.Request
+		status int
+		code   string
+	}{
+		{"missing credentials", e.GET("/orders"), iris.StatusUnauthorized, problem.CodeUnauthenticated},
+		{"invalid credentials", e.GET("/orders").WithHeader("Authorization", "Bearer invalid"), iris.StatusUnauthorized, problem.CodeUnauthenticated},
+		{"role not allowed", e.PATCH("/orders/1").WithHeader("Authorization", "Bearer customer"), iris.StatusForbidden, problem.CodePermissionDenied},
+		{"invalid page", e.GET("/webhooks/1/deliveries").WithQuery("page", "0").WithQuery("limit", "10").WithHeader("Authorization", "Bearer customer"), iris.StatusBadRequest, problem.CodeInvalidParameter},
+		{"invalid order id", e.PATCH("/orders/abc").WithHeader("Authorization", "Bearer driver"), iris.StatusBadRequest, problem.CodeInvalidParameter},
+		{"unknown order", e.PATCH("/orders/1").WithHeader("Authorization", "Bearer driver"), iris.StatusNotFound, problem.CodeOrderNotFound},
+		{"unknown route", e.GET("/unknown"), iris.StatusNotFound, problem.CodeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := decodeProblem(t, tt.req.Expect().Status(tt.status))
+			assert.Equal(t, tt.code, p.Code)
+			assert.NotEmpty(t, p.Detail)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package routers
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"order-service/models"
+	"order-service/problem"
+	srvorder "order-service/services"
+	srvmocks "order-service/services/mocks"
+	"order-service/services/ratelimit"
+
+	"github.com/kataras/iris"
+	"github.com/kataras/iris/httptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRateLimit(t *testing.T) {
+	mockAuth := new(srvmocks.Authenticator)
+	mockAuth.On("AuthenticateApiKey", mock.Anything, mock.Anything).Return(nil, srvorder.ErrUnauthenticated)
+
+	app := iris.New()
+	Register(app, Options{
+		Authenticator: mockAuth,
+		RateLimiter:   ratelimit.NewMemoryRateLimiter(),
+		RateLimits: map[string]models.RateLimit{
+			placeOrderRoute: {Requests: 1, Per: time.Minute},
+		},
+	})
+
+	e := httptest.New(t, app)
+
+	resp := e.POST("/orders").WithHeader("X-API-Key", "key-1").Expect()
+	resp.Status(iris.StatusUnauthorized)
+	resp.Header("X-RateLimit-Limit").Equal("1")
+	resp.Header("X-RateLimit-Remaining").Equal("0")
+	resp.Header("X-RateLimit-Reset").Equal("60")
+
+	resp = e.POST("/orders").WithHeader("X-API-Key", "key-1").Expect()
+	resp.Status(iris.StatusTooManyRequests)
+	resp.Header("Retry-After").Equal("60")
+	assert.Equal(t, problem.CodeRateLimited, decodeProblem(t, resp).Code)
+
+	// a new api key does not get around the bucket of the IP, other routes have their own buckets
+	e.POST("/orders").WithHeader("X-API-Key", "key-2").Expect().Status(iris.StatusTooManyRequests)
+	e.GET("/orders").WithHeader("X-API-Key", "key-1").Expect().Status(iris.StatusUnauthorized).Header("X-RateLimit-Limit").Empty()
+}
+
+func TestRateLimit_RandomApiKeys(t *testing.T) {
+	mockAuth := new(srvmocks.Authenticator)
+	mockAuth.On("AuthenticateApiKey", mock.Anything, mock.Anything).Return(nil, srvorder.ErrUnauthenticated)
+
+	app := iris.New()
+	Register(app, Options{
+		Authenticator: mockAuth,
+		RateLimiter:   ratelimit.NewMemoryRateLimiter(),
+		RateLimits: map[string]models.RateLimit{
+			placeOrderRoute: {Requests: 3, Per: time.Minute},
+		},
+	})
+
+	e := httptest.New(t, app)
+
+	// every request sends a key never seen before, the IP is still limited
+	for i := 0; i < 3; i++ {
+		e.POST("/orders").WithHeader("X-API-Key", fmt.Sprintf("random-%d", i)).Expect().Status(iris.StatusUnauthorized)
+	}
+	e.POST("/orders").WithHeader("X-API-Key", "random-3").Expect().Status(iris.StatusTooManyRequests)
+	e.POST("/orders").Expect().Status(iris.StatusTooManyRequests)
+}
+
+func TestRateLimit_LimiterFailure(t *testing.T) {
+	mockAuth := new(srvmocks.Authenticator)
+	mockAuth.On("AuthenticateApiKey", mock.Anything, mock.Anything).Return(nil, srvorder.ErrUnauthenticated)
+
+	mockLimiter := new(srvmocks.RateLimiter)
+	mockLimiter.On("Allow", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("store unavailable"))
+
+	app := iris.New()
+	Register(app, Options{
+		Authenticator: mockAuth,
+		RateLimiter:   mockLimiter,
+		RateLimits: map[string]models.RateLimit{
+			placeOrderRoute: {Requests: 1, Per: time.Minute},
+		},
+	})
+
+	// requests are let through while the limiter is unavailable
+	e := httptest.New(t, app)
+	e.POST("/orders").WithHeader("X-API-Key", "key-1").Expect().Status(iris.StatusUnauthorized)
+	e.POST("/orders").WithHeader("X-API-Key", "key-1").Expect().Status(iris.StatusUnauthorized)
+}
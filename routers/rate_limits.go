@@ -0,0 +1,30 @@
+package routers
+
+import (
+	mid "order-service/middlewares"
+	"order-service/services/ratelimit"
+
+	"github.com/kataras/iris"
+	"github.com/kataras/iris/context"
+)
+
+// names of the rate limited routes, matching the keys of Options.RateLimits
+const (
+	placeOrderRoute   = ratelimit.PlaceOrder
+	getOrderRoute     = ratelimit.GetOrder
+	takeOrderRoute    = ratelimit.TakeOrder
+	listOrdersRoute   = ratelimit.ListOrders
+	createApiKeyRoute = ratelimit.CreateApiKey
+)
+
+// rateLimit limits route by its configured limit, routes without a limit are not limited
+func rateLimit(opts Options, route string) context.Handler {
+	limit := opts.RateLimits[route]
+	if opts.RateLimiter == nil || !limit.Enabled() {
+		return func(ctx iris.Context) {
+			ctx.Next()
+		}
+	}
+
+	return mid.RateLimit(opts.RateLimiter, route, limit)
+}
@@ -1,20 +1,49 @@
 package routers
 
 import (
+	"time"
+
+	hd "order-service/handlers"
+	mid "order-service/middlewares"
+	"order-service/models"
+	"order-service/problem"
 	srvorder "order-service/services"
 
 	"github.com/kataras/iris"
 )
 
-func Register(app *iris.Application, orderService srvorder.OrderService) {
+// Options holds the services and settings the routes are registered with
+type Options struct {
+	OrderService       srvorder.OrderService
+	IdempotencyService srvorder.IdempotencyService
+	Authenticator      srvorder.Authenticator
+	WebhookService     srvorder.WebhookService
+	OrderEventService  srvorder.OrderEventService
+	EventHub           srvorder.EventHub
+	RateLimiter        srvorder.RateLimiter
+	// RateLimits holds the limit of each rate limited route by name, e.g. "place_order"
+	RateLimits     map[string]models.RateLimit
+	RequestTimeout time.Duration
+	// LegacyOrderIds accepts the sequential ids of orders on /orders/:id and the driver channel besides their public ids
+	LegacyOrderIds bool
+	Stream         hd.StreamOrdersOptions
+	DriverChannel  hd.DriverChannelOptions
+}
+
+func Register(app *iris.Application, opts Options) {
+	app.UseGlobal(mid.RequestId, mid.Tracing, mid.AccessLog, mid.Metrics, mid.Timeout(opts.RequestTimeout, streamOrdersPath, driverChannelPath))
+
 	home(app)
-	order(app, orderService)
+	metrics(app)
+	openAPI(app)
+	order(app, opts)
+	apiKey(app, opts)
+	webhook(app, opts)
+	driver(app, opts)
 
 	app.OnErrorCode(iris.StatusNotFound, notFoundHandler)
 }
 
 func notFoundHandler(ctx iris.Context) {
-	ctx.JSON(iris.Map{
-		"error": "Not found",
-	})
+	problem.Write(ctx, iris.StatusNotFound, problem.CodeNotFound, "Not found")
 }
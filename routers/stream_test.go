@@ -0,0 +1,115 @@
+package routers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	nethttptest "net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	hd "order-service/handlers"
+	"order-service/models"
+	srvmocks "order-service/services/mocks"
+
+	"github.com/kataras/iris"
+	"github.com/kataras/iris/httptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newStreamApp(t *testing.T, eventService *srvmocks.OrderEventService) *iris.Application {
+	mockAuth := new(srvmocks.Authenticator)
+	mockAuth.On("AuthenticateToken", mock.Anything, "driver").
+		Return(&models.Principal{Subject: "driver", Role: models.RoleDriver, MerchantId: "merchant-1"}, nil)
+
+	app := iris.New()
+	Register(app, Options{
+		Authenticator:     mockAuth,
+		OrderEventService: eventService,
+		RequestTimeout:    50 * time.Millisecond,
+		Stream: hd.StreamOrdersOptions{
+			PollInterval:      10 * time.Millisecond,
+			HeartbeatInterval: 100 * time.Millisecond,
+		},
+	})
+
+	return app
+}
+
+func TestStreamOrders(t *testing.T) {
+	mockEvents := new(srvmocks.OrderEventService)
+	mockEvents.On("EventsAfter", mock.Anything, int64(5), 100).Return([]models.Event{
+		{Id: 6, Type: models.EventTypeOrderPlaced, OrderId: 1, MerchantId: "merchant-1", Payload: []byte(`{"id":"` + publicId + `"}`), Origins: []float64{22.3193, 114.1694}},
+		// about 100km away from the area
+		{Id: 7, Type: models.EventTypeOrderPlaced, OrderId: 2, Payload: []byte(`{"id":2}`), Origins: []float64{23.2193, 114.1694}},
+	}, nil).Once()
+	mockEvents.On("EventsAfter", mock.Anything, int64(7), 100).Return([]models.Event{}, nil)
+
+	app := newStreamApp(t, mockEvents)
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := nethttptest.NewServer(app)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/orders/stream?lat=22.3193&lng=114.1694&radius=5000", nil)
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer driver")
+	req.Header.Set("Last-Event-ID", "5")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", strings.Split(resp.Header.Get("Content-Type"), ";")[0])
+
+	// the stream outlives the request timeout, it ends once both the event and a heartbeat were read
+	var sawEvent, sawHeartbeat bool
+	scanner := bufio.NewScanner(resp.Body)
+	for !(sawEvent && sawHeartbeat) && scanner.Scan() {
+		switch line := scanner.Text(); line {
+		case "id: 6":
+			sawEvent = true
+			assert.True(t, scanner.Scan())
+			assert.Equal(t, "event: OrderPlaced", scanner.Text())
+			assert.True(t, scanner.Scan())
+			// the order is known by its public id, the sequential id and the merchant stay internal
+			assert.Equal(t, `data: {"id":6,"type":"OrderPlaced","payload":{"id":"`+publicId+`"},"created_at":"0001-01-01T00:00:00Z","order_id":"`+publicId+`"}`, scanner.Text())
+		case "id: 7":
+			t.Fatal("event of an order outside the area was sent")
+		case ": heartbeat":
+			sawHeartbeat = true
+		}
+	}
+
+	assert.True(t, sawEvent)
+	assert.True(t, sawHeartbeat)
+	mockEvents.AssertCalled(t, "EventsAfter", mock.Anything, int64(7), 100)
+}
+
+func TestStreamOrders_InvalidArea(t *testing.T) {
+	app := newStreamApp(t, new(srvmocks.OrderEventService))
+
+	e := httptest.New(t, app)
+	e.GET("/orders/stream").WithQuery("lat", "22.3193").
+		WithHeader("Authorization", "Bearer driver").
+		Expect().
+		Status(iris.StatusBadRequest)
+	e.GET("/orders/stream").WithQuery("lat", "22.3193").WithQuery("lng", "114.1694").WithQuery("radius", "-1").
+		WithHeader("Authorization", "Bearer driver").
+		Expect().
+		Status(iris.StatusBadRequest)
+	e.GET("/orders/stream").WithHeader("Last-Event-ID", "abc").
+		WithHeader("Authorization", "Bearer driver").
+		Expect().
+		Status(iris.StatusBadRequest)
+}
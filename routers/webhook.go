@@ -0,0 +1,16 @@
+package routers
+
+import (
+	hd "order-service/handlers"
+	mid "order-service/middlewares"
+	srvauth "order-service/services/auth"
+
+	"github.com/kataras/iris"
+)
+
+func webhook(app *iris.Application, opts Options) {
+	auth := mid.Authenticate(opts.Authenticator)
+
+	app.Post("/webhooks", auth, mid.RequireRole(srvauth.ManageWebhookRoles...), hd.CreateWebhook(opts.WebhookService))
+	app.Get("/webhooks/:id/deliveries", auth, mid.RequireRole(srvauth.ManageWebhookRoles...), mid.Paginate, hd.ListWebhookDeliveries(opts.WebhookService))
+}
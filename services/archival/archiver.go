@@ -0,0 +1,89 @@
+package archival
+
+import (
+	"context"
+	"time"
+
+	"order-service/logger"
+	"order-service/repositories"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Options configures which orders are archived and how fast
+type Options struct {
+	// Interval is how often old orders are archived
+	Interval time.Duration
+	// After is the age of the orders archived
+	After time.Duration
+	// BatchSize is the maximum number of orders moved in one transaction
+	BatchSize int
+	// BatchPause is the pause between batches, so the archival leaves room to the other writes
+	BatchPause time.Duration
+}
+
+// Archiver moves orders done or deleted for a while to the archive, in small batches so it never
+// holds locks for long
+type Archiver struct {
+	repo repositories.OrderArchiveRepository
+	opts Options
+	now  func() time.Time
+}
+
+// NewArchiver will create an Archiver archiving the orders of repo
+func NewArchiver(repo repositories.OrderArchiveRepository, opts Options) *Archiver {
+	return &Archiver{
+		repo: repo,
+		opts: opts,
+		now:  time.Now,
+	}
+}
+
+// Run archives old orders every Interval until ctx is done
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.ArchiveOld(ctx)
+		}
+	}
+}
+
+// ArchiveOld moves every order older than After to the archive, one batch after the other until a batch
+// is not full, and returns how many were moved
+func (a *Archiver) ArchiveOld(ctx context.Context) (int, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/archival", "method": "ArchiveOld"})
+
+	before := a.now().Add(-a.opts.After)
+
+	archived := 0
+	for {
+		n, err := a.repo.Archive(ctx, before, a.opts.BatchSize)
+		archived += n
+		if err != nil {
+			log.WithError(err).WithField("archived", archived).Error("Failed to archive orders")
+			return archived, err
+		}
+
+		if n < a.opts.BatchSize {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return archived, ctx.Err()
+		case <-time.After(a.opts.BatchPause):
+		}
+	}
+
+	if archived > 0 {
+		log.WithField("archived", archived).Info("Archived orders")
+	}
+
+	return archived, nil
+}
@@ -0,0 +1,64 @@
+package archival
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	rpmocks "order-service/repositories/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var archiverOptions = Options{Interval: time.Hour, After: 24 * time.Hour, BatchSize: 10}
+
+func newArchiver(repo *rpmocks.OrderArchiveRepository, now time.Time) *Archiver {
+	a := NewArchiver(repo, archiverOptions)
+	a.now = func() time.Time { return now }
+	return a
+}
+
+func TestArchiver_ArchiveOld(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	before := now.Add(-24 * time.Hour)
+
+	t.Run("archives batches until one is not full", func(t *testing.T) {
+		mockRepo := new(rpmocks.OrderArchiveRepository)
+		mockRepo.On("Archive", context.Background(), before, 10).Return(10, nil).Twice()
+		mockRepo.On("Archive", context.Background(), before, 10).Return(3, nil).Once()
+
+		n, err := newArchiver(mockRepo, now).ArchiveOld(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 23, n)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("stops at the first failed batch", func(t *testing.T) {
+		mockRepo := new(rpmocks.OrderArchiveRepository)
+		mockRepo.On("Archive", context.Background(), before, 10).Return(10, nil).Once()
+		mockRepo.On("Archive", context.Background(), before, 10).Return(0, errors.New("lock wait timeout")).Once()
+
+		n, err := newArchiver(mockRepo, now).ArchiveOld(context.Background())
+		assert.Error(t, err)
+		assert.Equal(t, 10, n)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("stops between batches when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		mockRepo := new(rpmocks.OrderArchiveRepository)
+		mockRepo.On("Archive", ctx, before, 10).Run(func(_ mock.Arguments) { cancel() }).Return(10, nil).Once()
+
+		a := newArchiver(mockRepo, now)
+		a.opts.BatchPause = time.Hour
+		n, err := a.ArchiveOld(ctx)
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, 10, n)
+
+		mockRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"order-service/logger"
+	"order-service/models"
+	"order-service/repositories"
+	"order-service/services"
+	"order-service/tenant"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/sirupsen/logrus"
+)
+
+const apiKeyPrefix = "osk_"
+
+var (
+	errUnsupportedAlg = errors.New("unsupported signing algorithm")
+	errUnknownKey     = errors.New("unknown signing key")
+)
+
+// Options configures how bearer tokens are verified, a token is only accepted
+// if it is signed with an algorithm whose key is configured
+type Options struct {
+	// HS256Secret verifies HS256 tokens
+	HS256Secret string
+	// JWKSFile is a local JWKS file with the public keys verifying RS256 tokens
+	JWKSFile string
+	// Issuer and Audience are checked against the iss and aud claims if not empty
+	Issuer   string
+	Audience string
+}
+
+type claims struct {
+	Role       string `json:"role"`
+	MerchantId string `json:"merchant_id"`
+	jwt.StandardClaims
+}
+
+type authenticator struct {
+	apiKeyRepo  repositories.ApiKeyRepository
+	hs256Secret []byte
+	rsaKeys     map[string]*rsa.PublicKey
+	issuer      string
+	audience    string
+}
+
+// NewAuthenticator will create an Authenticator checking api keys against apiKeyRepo and bearer tokens against opts
+func NewAuthenticator(apiKeyRepo repositories.ApiKeyRepository, opts Options) (services.Authenticator, error) {
+	a := &authenticator{
+		apiKeyRepo:  apiKeyRepo,
+		hs256Secret: []byte(opts.HS256Secret),
+		rsaKeys:     map[string]*rsa.PublicKey{},
+		issuer:      opts.Issuer,
+		audience:    opts.Audience,
+	}
+
+	if opts.JWKSFile != "" {
+		keys, err := loadJWKS(opts.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+
+		a.rsaKeys = keys
+	}
+
+	return a, nil
+}
+
+func hashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *authenticator) AuthenticateApiKey(ctx context.Context, key string) (*models.Principal, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/auth", "method": "AuthenticateApiKey"})
+
+	apiKey, err := a.apiKeyRepo.GetByHash(ctx, hashApiKey(key))
+	if err == models.ErrNotFound {
+		log.Debug("Unknown or revoked api key")
+		return nil, services.ErrUnauthenticated
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to get api key")
+		return nil, err
+	}
+	// keys created before merchants belong to no merchant, like tokens without a merchant claim
+	if apiKey.MerchantId == "" {
+		log.WithField("api_key_id", apiKey.Id).Warn("Api key belongs to no merchant")
+		return nil, services.ErrUnauthenticated
+	}
+
+	return &models.Principal{
+		Subject:    apiKey.Name,
+		Role:       apiKey.Role,
+		MerchantId: apiKey.MerchantId,
+	}, nil
+}
+
+func (a *authenticator) AuthenticateToken(ctx context.Context, token string) (*models.Principal, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/auth", "method": "AuthenticateToken"})
+
+	c := &claims{}
+
+	_, err := jwt.ParseWithClaims(token, c, a.keyFunc)
+	if err != nil {
+		log.WithError(err).Debug("Invalid bearer token")
+		return nil, services.ErrUnauthenticated
+	}
+
+	if c.ExpiresAt == 0 || c.MerchantId == "" ||
+		(a.issuer != "" && !c.VerifyIssuer(a.issuer, true)) ||
+		(a.audience != "" && !c.VerifyAudience(a.audience, true)) {
+		log.Debug("Bearer token has missing or unexpected claims")
+		return nil, services.ErrUnauthenticated
+	}
+
+	if !models.ValidRole(c.Role) {
+		log.WithField("role", c.Role).Debug("Bearer token has unknown role")
+		return nil, services.ErrUnauthenticated
+	}
+
+	return &models.Principal{
+		Subject:    c.Subject,
+		Role:       c.Role,
+		MerchantId: c.MerchantId,
+	}, nil
+}
+
+// keyFunc picks the verification key by the alg header, the key type always matches the algorithm
+// so an RS256 public key can never be used as an HS256 secret
+func (a *authenticator) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		if len(a.hs256Secret) == 0 {
+			return nil, errUnsupportedAlg
+		}
+
+		return a.hs256Secret, nil
+	case jwt.SigningMethodRS256.Alg():
+		kid, _ := token.Header["kid"].(string)
+
+		key, ok := a.rsaKeys[kid]
+		if !ok {
+			return nil, errUnknownKey
+		}
+
+		return key, nil
+	}
+
+	return nil, errUnsupportedAlg
+}
+
+// CreateApiKey issues a key for the merchant ctx is scoped to, so a caller can never create keys for another merchant
+func (a *authenticator) CreateApiKey(ctx context.Context, name, role string) (string, *models.ApiKey, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/auth", "method": "CreateApiKey", "name": name, "role": role})
+
+	if !models.ValidRole(role) {
+		return "", nil, services.ErrInvalidRole
+	}
+
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return "", nil, models.ErrNoTenant
+	}
+
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		log.WithError(err).Error("Failed to generate api key")
+		return "", nil, err
+	}
+
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+
+	apiKey, err := a.apiKeyRepo.Create(ctx, &models.ApiKey{
+		Name:       name,
+		MerchantId: merchantId,
+		KeyHash:    hashApiKey(key),
+		Role:       role,
+	})
+	if err != nil {
+		log.WithError(err).Error("Failed to create api key")
+		return "", nil, err
+	}
+
+	return key, apiKey, nil
+}
@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"order-service/models"
+	rpmocks "order-service/repositories/mocks"
+	"order-service/services"
+	"order-service/tenant"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const secret = "test-secret"
+
+func signHS256(t *testing.T, c claims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte(secret))
+	assert.NoError(t, err)
+	return token
+}
+
+func validClaims(role string) claims {
+	return claims{
+		Role:       role,
+		MerchantId: "merchant-1",
+		StandardClaims: jwt.StandardClaims{
+			Subject:   "user-1",
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+	}
+}
+
+// writeJWKS writes the public part of key as a JWKS file and returns its path
+func writeJWKS(t *testing.T, kid string, key *rsa.PublicKey) string {
+	set := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}
+	bs, _ := json.Marshal(set)
+
+	f, err := ioutil.TempFile("", "jwks-*.json")
+	assert.NoError(t, err)
+	f.Write(bs)
+	f.Close()
+
+	return f.Name()
+}
+
+func TestAuthenticator_AuthenticateToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	jwksFile := writeJWKS(t, "key-1", &rsaKey.PublicKey)
+	defer os.Remove(jwksFile)
+
+	a, err := NewAuthenticator(new(rpmocks.ApiKeyRepository), Options{HS256Secret: secret, JWKSFile: jwksFile})
+	assert.NoError(t, err)
+
+	t.Run("valid HS256 token", func(t *testing.T) {
+		principal, err := a.AuthenticateToken(context.Background(), signHS256(t, validClaims(models.RoleDriver)))
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", principal.Subject)
+		assert.Equal(t, models.RoleDriver, principal.Role)
+		assert.Equal(t, "merchant-1", principal.MerchantId)
+	})
+
+	t.Run("valid RS256 token", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims(models.RoleOps))
+		token.Header["kid"] = "key-1"
+		signed, err := token.SignedString(rsaKey)
+		assert.NoError(t, err)
+
+		principal, err := a.AuthenticateToken(context.Background(), signed)
+		assert.NoError(t, err)
+		assert.Equal(t, models.RoleOps, principal.Role)
+	})
+
+	t.Run("RS256 token with unknown kid", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims(models.RoleOps))
+		token.Header["kid"] = "key-2"
+		signed, _ := token.SignedString(rsaKey)
+
+		_, err := a.AuthenticateToken(context.Background(), signed)
+		assert.Equal(t, services.ErrUnauthenticated, err)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		c := validClaims(models.RoleDriver)
+		c.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+
+		_, err := a.AuthenticateToken(context.Background(), signHS256(t, c))
+		assert.Equal(t, services.ErrUnauthenticated, err)
+	})
+
+	t.Run("token without expiry", func(t *testing.T) {
+		c := validClaims(models.RoleDriver)
+		c.ExpiresAt = 0
+
+		_, err := a.AuthenticateToken(context.Background(), signHS256(t, c))
+		assert.Equal(t, services.ErrUnauthenticated, err)
+	})
+
+	t.Run("token without merchant", func(t *testing.T) {
+		c := validClaims(models.RoleDriver)
+		c.MerchantId = ""
+
+		_, err := a.AuthenticateToken(context.Background(), signHS256(t, c))
+		assert.Equal(t, services.ErrUnauthenticated, err)
+	})
+
+	t.Run("unknown role", func(t *testing.T) {
+		_, err := a.AuthenticateToken(context.Background(), signHS256(t, validClaims("root")))
+		assert.Equal(t, services.ErrUnauthenticated, err)
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims(models.RoleAdmin)).SignedString([]byte("other"))
+
+		_, err := a.AuthenticateToken(context.Background(), token)
+		assert.Equal(t, services.ErrUnauthenticated, err)
+	})
+
+	t.Run("unsigned token", func(t *testing.T) {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims(models.RoleAdmin)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+		_, err := a.AuthenticateToken(context.Background(), token)
+		assert.Equal(t, services.ErrUnauthenticated, err)
+	})
+}
+
+func TestAuthenticator_AuthenticateApiKey(t *testing.T) {
+	mockRepo := new(rpmocks.ApiKeyRepository)
+
+	a, err := NewAuthenticator(mockRepo, Options{})
+	assert.NoError(t, err)
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.On("GetByHash", mock.Anything, hashApiKey("osk_valid")).Return(&models.ApiKey{Id: 1, Name: "merchant", MerchantId: "merchant-1", Role: models.RoleCustomer}, nil).Once()
+
+		principal, err := a.AuthenticateApiKey(context.Background(), "osk_valid")
+		assert.NoError(t, err)
+		assert.Equal(t, models.RoleCustomer, principal.Role)
+		assert.Equal(t, "merchant-1", principal.MerchantId)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("key without merchant", func(t *testing.T) {
+		mockRepo.On("GetByHash", mock.Anything, hashApiKey("osk_legacy")).Return(&models.ApiKey{Id: 2, Name: "legacy", Role: models.RoleCustomer}, nil).Once()
+
+		principal, err := a.AuthenticateApiKey(context.Background(), "osk_legacy")
+		assert.Equal(t, services.ErrUnauthenticated, err)
+		assert.Nil(t, principal)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		mockRepo.On("GetByHash", mock.Anything, hashApiKey("osk_unknown")).Return(nil, models.ErrNotFound).Once()
+
+		principal, err := a.AuthenticateApiKey(context.Background(), "osk_unknown")
+		assert.Equal(t, services.ErrUnauthenticated, err)
+		assert.Nil(t, principal)
+
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestAuthenticator_CreateApiKey(t *testing.T) {
+	mockRepo := new(rpmocks.ApiKeyRepository)
+
+	a, err := NewAuthenticator(mockRepo, Options{})
+	assert.NoError(t, err)
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.ApiKey")).
+			Return(func(ctx context.Context, k *models.ApiKey) *models.ApiKey { return k }, nil).Once()
+
+		ctx := tenant.WithMerchantId(context.Background(), "merchant-1")
+
+		key, apiKey, err := a.CreateApiKey(ctx, "merchant", models.RoleCustomer)
+		assert.NoError(t, err)
+		assert.Equal(t, "merchant-1", apiKey.MerchantId)
+		assert.Equal(t, hashApiKey(key), apiKey.KeyHash)
+		assert.NotEqual(t, key, apiKey.KeyHash)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid role", func(t *testing.T) {
+		_, _, err := a.CreateApiKey(context.Background(), "merchant", "root")
+		assert.Equal(t, services.ErrInvalidRole, err)
+	})
+
+	t.Run("no merchant", func(t *testing.T) {
+		_, _, err := a.CreateApiKey(context.Background(), "merchant", models.RoleCustomer)
+		assert.Equal(t, models.ErrNoTenant, err)
+	})
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"strings"
+
+	"order-service/models"
+)
+
+// roles allowed to call each operation, shared by the REST routes and the gRPC methods
+var (
+	PlaceOrderRoles   = []string{models.RoleCustomer, models.RoleOps, models.RoleAdmin}
+	GetOrderRoles     = []string{models.RoleCustomer, models.RoleDriver, models.RoleOps, models.RoleAdmin}
+	TakeOrderRoles    = []string{models.RoleDriver}
+	ListOrdersRoles   = []string{models.RoleOps, models.RoleAdmin}
+	StreamOrdersRoles = []string{models.RoleDriver, models.RoleOps, models.RoleAdmin}
+	// the driver channel takes orders, like TakeOrderRoles
+	DriverChannelRoles = []string{models.RoleDriver}
+	CreateApiKeyRoles  = []string{models.RoleAdmin}
+	// merchant integrations manage their own webhooks
+	ManageWebhookRoles = []string{models.RoleCustomer, models.RoleOps, models.RoleAdmin}
+)
+
+// MaxRequestIdLength is the longest request id accepted from a client
+const MaxRequestIdLength = 128
+
+// HasRole reports whether principal has one of roles
+func HasRole(principal *models.Principal, roles []string) bool {
+	for _, role := range roles {
+		if principal.Role == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BearerToken returns the token of an "Authorization: Bearer" value, or "" if value is not one
+func BearerToken(value string) string {
+	const prefix = "Bearer "
+
+	if len(value) <= len(prefix) || !strings.EqualFold(value[:len(prefix)], prefix) {
+		return ""
+	}
+
+	return strings.TrimSpace(value[len(prefix):])
+}
+
+// ValidRequestId rejects empty, oversized or non printable ids
+// so a client cannot inject arbitrary content into our logs
+func ValidRequestId(requestId string) bool {
+	if requestId == "" || len(requestId) > MaxRequestIdLength {
+		return false
+	}
+
+	for _, c := range requestId {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"order-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasRole(t *testing.T) {
+	assert.True(t, HasRole(&models.Principal{Role: models.RoleDriver}, TakeOrderRoles))
+	assert.False(t, HasRole(&models.Principal{Role: models.RoleCustomer}, TakeOrderRoles))
+	assert.False(t, HasRole(&models.Principal{Role: models.RoleAdmin}, nil))
+}
+
+func TestBearerToken(t *testing.T) {
+	assert.Equal(t, "token", BearerToken("Bearer token"))
+	assert.Equal(t, "token", BearerToken("bearer  token "))
+	assert.Equal(t, "", BearerToken("Bearer "))
+	assert.Equal(t, "", BearerToken("Basic dXNlcjpwYXNz"))
+	assert.Equal(t, "", BearerToken(""))
+}
+
+func TestValidRequestId(t *testing.T) {
+	assert.True(t, ValidRequestId("req-1"))
+	assert.True(t, ValidRequestId(strings.Repeat("a", MaxRequestIdLength)))
+	assert.False(t, ValidRequestId(""))
+	assert.False(t, ValidRequestId(strings.Repeat("a", MaxRequestIdLength+1)))
+	assert.False(t, ValidRequestId("req 1"))
+	assert.False(t, ValidRequestId("req\n1"))
+}
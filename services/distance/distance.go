@@ -3,18 +3,30 @@ package distance
 import (
 	"context"
 	"os"
+	"time"
 
+	"order-service/logger"
+	"order-service/metrics"
 	"order-service/services"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"googlemaps.github.io/maps"
 )
 
+var tracer = otel.Tracer("order-service/services/distance")
+
 type distanceService struct {
-	c *maps.Client
+	c       *maps.Client
+	timeout time.Duration
 }
 
-func NewDistanceService() (services.DistanceCalculator, error) {
+// NewDistanceService will create a DistanceCalculator backed by google distance matrix api,
+// each request is cancelled after timeout unless timeout is zero
+func NewDistanceService(timeout time.Duration) (services.DistanceCalculator, error) {
 	key := os.Getenv("GOOGLE_API_KEY")
 
 	c, err := maps.NewClient(maps.WithAPIKey(key))
@@ -22,11 +34,11 @@ func NewDistanceService() (services.DistanceCalculator, error) {
 		return nil, err
 	}
 
-	return &distanceService{c}, nil
+	return &distanceService{c, timeout}, nil
 }
 
-func (s *distanceService) GetDistance(origins, destinations []string) (int, error) {
-	log := logrus.WithFields(logrus.Fields{"module": "service/distance", "method": "GetDistance", "origins": origins, "destinations": destinations})
+func (s *distanceService) GetDistance(ctx context.Context, origins, destinations []string) (int, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/distance", "method": "GetDistance", "origins": origins, "destinations": destinations})
 
 	r := &maps.DistanceMatrixRequest{
 		Origins:      origins,
@@ -34,9 +46,25 @@ func (s *distanceService) GetDistance(origins, destinations []string) (int, erro
 		Mode:         maps.TravelModeDriving,
 	}
 
-	resp, err := s.c.DistanceMatrix(context.Background(), r)
+	ctx, span := tracer.Start(ctx, "DistanceCalculator.GetDistance",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("distance.provider", "google")))
+	defer span.End()
+
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	resp, err := s.c.DistanceMatrix(ctx, r)
+	metrics.DistanceRequestDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
 		log.WithError(err).Error("Failed to get distance from google")
+		metrics.DistanceErrors.WithLabelValues("request").Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return 0, err
 	}
 
@@ -44,8 +72,13 @@ func (s *distanceService) GetDistance(origins, destinations []string) (int, erro
 		len(resp.Rows[0].Elements) != 1 ||
 		resp.Rows[0].Elements[0].Status != "OK" {
 		log.Error("Failed to calculate distance for give location")
+		metrics.DistanceErrors.WithLabelValues("no_route").Inc()
+		span.SetStatus(codes.Error, services.ErrCannotCalculateDistance.Error())
 		return 0, services.ErrCannotCalculateDistance
 	}
 
-	return resp.Rows[0].Elements[0].Distance.Meters, nil
+	meters := resp.Rows[0].Elements[0].Distance.Meters
+	span.SetAttributes(attribute.Int("distance.meters", meters))
+
+	return meters, nil
 }
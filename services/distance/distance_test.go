@@ -1,11 +1,13 @@
 package distance
 
 import (
+	"context"
 	"log"
 	"os"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"order-service/services"
 
@@ -16,7 +18,7 @@ import (
 func TestDistanceService_GetDistance(t *testing.T) {
 	loadEnv()
 
-	service, err := NewDistanceService()
+	service, err := NewDistanceService(5 * time.Second)
 	assert.NoError(t, err)
 	assert.NotNil(t, service)
 
@@ -25,6 +27,7 @@ func TestDistanceService_GetDistance(t *testing.T) {
 		destinations := []string{"22.279707", "114.186301"}
 
 		_, err = service.GetDistance(
+			context.Background(),
 			[]string{strings.Join(origins, ",")},
 			[]string{strings.Join(destinations, ",")})
 		assert.NoError(t, err)
@@ -35,6 +38,7 @@ func TestDistanceService_GetDistance(t *testing.T) {
 		destinations := []string{"22.279707", "114.186301"}
 
 		_, err = service.GetDistance(
+			context.Background(),
 			[]string{strings.Join(origins, ",")},
 			[]string{strings.Join(destinations, ",")})
 		assert.EqualError(t, err, services.ErrCannotCalculateDistance.Error())
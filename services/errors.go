@@ -1,8 +1,24 @@
 package services
 
-import "errors"
+import (
+	"context"
+	"errors"
+)
 
 var (
 	ErrCannotCalculateDistance = errors.New("cannot calculate distance for given location")
 	ErrOrderAlreadyTaken       = errors.New("order already taken")
+	ErrIdempotencyKeyReused    = errors.New("idempotency key already used for a different request")
+	ErrRequestInProgress       = errors.New("request with the same idempotency key is in progress")
+	ErrUnauthenticated         = errors.New("invalid credentials")
+	ErrInvalidRole             = errors.New("invalid role")
+	ErrInvalidWebhookUrl       = errors.New("webhook url must be an absolute http or https url")
+	ErrInvalidEvent            = errors.New("invalid event")
+	ErrWebhookUrlNotPublic     = errors.New("webhook url must resolve to public addresses only")
+	ErrInvalidOrderId          = errors.New("not an order id")
 )
+
+// IsTimeout reports whether err was caused by a deadline of the request context being exceeded
+func IsTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,45 @@
+package event
+
+import (
+	"context"
+
+	"order-service/logger"
+	"order-service/models"
+	"order-service/repositories"
+	"order-service/services"
+
+	"github.com/sirupsen/logrus"
+)
+
+type orderEventService struct {
+	repo repositories.OutboxRepository
+}
+
+// NewOrderEventService will create an OrderEventService reading the events recorded in the outbox
+func NewOrderEventService(repo repositories.OutboxRepository) services.OrderEventService {
+	return &orderEventService{
+		repo: repo,
+	}
+}
+
+func (s *orderEventService) LatestEventId(ctx context.Context) (int64, error) {
+	id, err := s.repo.LatestId(ctx)
+	if err != nil {
+		logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/event", "method": "LatestEventId"}).
+			WithError(err).Error("Failed to get latest event id")
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *orderEventService) EventsAfter(ctx context.Context, afterId int64, limit int) ([]models.Event, error) {
+	events, err := s.repo.ListAfter(ctx, afterId, limit)
+	if err != nil {
+		logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/event", "method": "EventsAfter", "after_id": afterId}).
+			WithError(err).Error("Failed to list events")
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,46 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"order-service/models"
+	rpmocks "order-service/repositories/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOrderEventService_LatestEventId(t *testing.T) {
+	mockRepo := new(rpmocks.OutboxRepository)
+	mockRepo.On("LatestId", mock.Anything).Return(int64(42), nil).Once()
+
+	id, err := NewOrderEventService(mockRepo).LatestEventId(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), id)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestOrderEventService_EventsAfter(t *testing.T) {
+	t.Run("returns the events after the cursor", func(t *testing.T) {
+		mockRepo := new(rpmocks.OutboxRepository)
+		mockRepo.On("ListAfter", mock.Anything, int64(5), 10).
+			Return([]models.Event{{Id: 6, Type: models.EventTypeOrderPlaced}}, nil).Once()
+
+		events, err := NewOrderEventService(mockRepo).EventsAfter(context.Background(), 5, 10)
+		assert.NoError(t, err)
+		assert.Len(t, events, 1)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("returns the repository error", func(t *testing.T) {
+		mockRepo := new(rpmocks.OutboxRepository)
+		mockRepo.On("ListAfter", mock.Anything, int64(5), 10).Return(nil, errors.New("db down")).Once()
+
+		_, err := NewOrderEventService(mockRepo).EventsAfter(context.Background(), 5, 10)
+		assert.EqualError(t, err, "db down")
+	})
+}
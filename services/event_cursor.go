@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"order-service/models"
+)
+
+// EventCursor reads a stream of events without skipping the ones which commit late. The id of an event is
+// assigned when it is written but it only becomes visible when its transaction commits, so an event may show
+// up after events with higher ids. The cursor therefore stays below the events read during the last Settle,
+// reading them again until every event with a lower id has committed or was rolled back.
+// Settle must be longer than the transactions writing events, zero trusts that events commit in id order
+type EventCursor struct {
+	// After is the id up to which every event was read, a stream resumed after it misses no event
+	After  int64
+	Settle time.Duration
+
+	// seen holds when the events after After were first read
+	seen map[int64]time.Time
+}
+
+// NewEventCursor will create an EventCursor reading the events after the event after
+func NewEventCursor(after int64, settle time.Duration) *EventCursor {
+	return &EventCursor{
+		After:  after,
+		Settle: settle,
+		seen:   make(map[int64]time.Time),
+	}
+}
+
+// Next reads the events of service after the cursor, limit at a time, and returns up to about limit of the
+// ones it did not return before, oldest first
+func (c *EventCursor) Next(ctx context.Context, service OrderEventService, limit int) ([]models.Event, error) {
+	now := time.Now()
+
+	var events []models.Event
+	after := c.After
+	for {
+		batch, err := service.EventsAfter(ctx, after, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, event := range batch {
+			if _, ok := c.seen[event.Id]; !ok {
+				events = append(events, event)
+			}
+		}
+
+		// the unsettled events read again must not hold back the ones after them
+		if len(batch) < limit || len(events) >= limit {
+			break
+		}
+		after = batch[len(batch)-1].Id
+	}
+
+	for _, event := range events {
+		c.seen[event.Id] = now
+	}
+	c.settle(now)
+
+	return events, nil
+}
+
+// ResumeId is the id a stream sending event resumes after, without missing the events which may still commit
+func (c *EventCursor) ResumeId(event models.Event) int64 {
+	if c.After < event.Id {
+		return c.After
+	}
+	return event.Id
+}
+
+// settle moves After over the events read longer than Settle ago, no event below them can show up anymore
+func (c *EventCursor) settle(now time.Time) {
+	ids := make([]int64, 0, len(c.seen))
+	for id := range c.seen {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		if now.Sub(c.seen[id]) < c.Settle {
+			return
+		}
+		c.After = id
+		delete(c.seen, id)
+	}
+}
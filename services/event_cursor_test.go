@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"order-service/models"
+	srvmocks "order-service/services/mocks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventCursor_Next(t *testing.T) {
+	t.Run("returns events committing after events with higher ids", func(t *testing.T) {
+		mockEvents := new(srvmocks.OrderEventService)
+		mockEvents.On("EventsAfter", context.Background(), int64(5), 10).Return([]models.Event{{Id: 7}}, nil).Once()
+		// 6 was written before 7 but committed after it
+		mockEvents.On("EventsAfter", context.Background(), int64(5), 10).Return([]models.Event{{Id: 6}, {Id: 7}}, nil).Twice()
+		mockEvents.On("EventsAfter", context.Background(), int64(7), 10).Return([]models.Event{}, nil).Once()
+
+		cursor := NewEventCursor(5, 50*time.Millisecond)
+
+		events, err := cursor.Next(context.Background(), mockEvents, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []models.Event{{Id: 7}}, events)
+		// a stream resumed after 7 would miss 6
+		assert.Equal(t, int64(5), cursor.ResumeId(events[0]))
+
+		events, err = cursor.Next(context.Background(), mockEvents, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []models.Event{{Id: 6}}, events)
+
+		// once settled the cursor moves past the events
+		time.Sleep(60 * time.Millisecond)
+		events, err = cursor.Next(context.Background(), mockEvents, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+		assert.Equal(t, int64(7), cursor.After)
+
+		events, err = cursor.Next(context.Background(), mockEvents, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+		mockEvents.AssertExpectations(t)
+	})
+
+	t.Run("reads past a full batch of unsettled events", func(t *testing.T) {
+		mockEvents := new(srvmocks.OrderEventService)
+		mockEvents.On("EventsAfter", context.Background(), int64(5), 2).Return([]models.Event{{Id: 6}, {Id: 7}}, nil).Twice()
+		mockEvents.On("EventsAfter", context.Background(), int64(7), 2).Return([]models.Event{{Id: 8}}, nil).Once()
+
+		cursor := NewEventCursor(5, time.Minute)
+
+		events, err := cursor.Next(context.Background(), mockEvents, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []models.Event{{Id: 6}, {Id: 7}}, events)
+
+		events, err = cursor.Next(context.Background(), mockEvents, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []models.Event{{Id: 8}}, events)
+		mockEvents.AssertExpectations(t)
+	})
+
+	t.Run("without settle window events are trusted to commit in id order", func(t *testing.T) {
+		mockEvents := new(srvmocks.OrderEventService)
+		mockEvents.On("EventsAfter", context.Background(), int64(5), 10).Return([]models.Event{{Id: 6}, {Id: 7}}, nil).Once()
+
+		cursor := NewEventCursor(5, 0)
+
+		events, err := cursor.Next(context.Background(), mockEvents, 10)
+		assert.NoError(t, err)
+		assert.Len(t, events, 2)
+		assert.Equal(t, int64(7), cursor.After)
+		assert.Equal(t, int64(6), cursor.ResumeId(events[0]))
+	})
+}
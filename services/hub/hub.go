@@ -0,0 +1,162 @@
+package hub
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"order-service/logger"
+	"order-service/models"
+	"order-service/services"
+	"order-service/tenant"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Options configures how often the feeds poll for new events and how many events a subscriber may lag behind
+type Options struct {
+	PollInterval time.Duration
+	// SettleWindow is how long events are read again for events with lower ids which commit late
+	SettleWindow time.Duration
+	// BatchSize is the maximum number of events read per poll
+	BatchSize int
+	// BufferSize is the number of events buffered per subscriber, further events are dropped
+	// until the subscriber catches up
+	BufferSize int
+}
+
+// Hub polls the events of each merchant with subscribers once, however many subscribers it has
+type Hub struct {
+	events services.OrderEventService
+	opts   Options
+
+	mu    sync.Mutex
+	feeds map[string]*feed
+}
+
+// feed is the poller of a merchant's events, it stops once its last subscriber is gone
+type feed struct {
+	merchantId  string
+	stop        context.CancelFunc
+	subscribers map[chan models.Event]struct{}
+}
+
+// NewHub will create a Hub reading the events of events
+func NewHub(events services.OrderEventService, opts Options) *Hub {
+	return &Hub{
+		events: events,
+		opts:   opts,
+		feeds:  make(map[string]*feed),
+	}
+}
+
+func (h *Hub) Subscribe(ctx context.Context) (<-chan models.Event, func(), error) {
+	merchantId, ok := tenant.MerchantId(ctx)
+	if !ok {
+		return nil, nil, models.ErrNoTenant
+	}
+
+	ch := make(chan models.Event, h.opts.BufferSize)
+
+	h.mu.Lock()
+	f, ok := h.feeds[merchantId]
+	if ok {
+		f.subscribers[ch] = struct{}{}
+	}
+	h.mu.Unlock()
+
+	if !ok {
+		// the cursor is looked up without holding the lock, so a slow database does not block other merchants
+		cursor, err := h.events.LatestEventId(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		h.mu.Lock()
+		f, ok = h.feeds[merchantId]
+		if !ok {
+			f = h.start(merchantId, cursor)
+		}
+		f.subscribers[ch] = struct{}{}
+		h.mu.Unlock()
+	}
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() { h.unsubscribe(f, ch) })
+	}
+
+	return ch, unsubscribe, nil
+}
+
+// start starts the feed of merchantId after the event cursor, h.mu must be held
+func (h *Hub) start(merchantId string, cursor int64) *feed {
+	ctx, stop := context.WithCancel(tenant.WithMerchantId(context.Background(), merchantId))
+
+	f := &feed{
+		merchantId:  merchantId,
+		stop:        stop,
+		subscribers: make(map[chan models.Event]struct{}),
+	}
+	h.feeds[merchantId] = f
+
+	go h.poll(ctx, f, cursor)
+
+	return f
+}
+
+func (h *Hub) unsubscribe(f *feed, ch chan models.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(f.subscribers, ch)
+	close(ch)
+
+	if len(f.subscribers) == 0 {
+		f.stop()
+		if h.feeds[f.merchantId] == f {
+			delete(h.feeds, f.merchantId)
+		}
+	}
+}
+
+func (h *Hub) poll(ctx context.Context, f *feed, cursor int64) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/hub", "method": "poll", "merchant_id": f.merchantId})
+
+	ticker := time.NewTicker(h.opts.PollInterval)
+	defer ticker.Stop()
+
+	events := services.NewEventCursor(cursor, h.opts.SettleWindow)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			batch, err := events.Next(ctx, h.events, h.opts.BatchSize)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.WithError(err).Error("Failed to poll events")
+				}
+				continue
+			}
+
+			for _, event := range batch {
+				h.broadcast(f, event)
+			}
+		}
+	}
+}
+
+func (h *Hub) broadcast(f *feed, event models.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range f.subscribers {
+		select {
+		case ch <- event:
+		default:
+			logger.FromContext(context.Background()).WithFields(logrus.Fields{"module": "service/hub", "method": "broadcast", "event_id": event.Id}).
+				Warn("Dropped event of a slow subscriber")
+		}
+	}
+}
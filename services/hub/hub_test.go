@@ -0,0 +1,88 @@
+package hub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"order-service/models"
+	srvmocks "order-service/services/mocks"
+	"order-service/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var hubOptions = Options{PollInterval: 20 * time.Millisecond, BatchSize: 10, BufferSize: 10}
+
+func receive(t *testing.T, events <-chan models.Event) models.Event {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+		return models.Event{}
+	}
+}
+
+func TestHub_Subscribe(t *testing.T) {
+	t.Run("shares one feed between the subscribers of a merchant", func(t *testing.T) {
+		mockEvents := new(srvmocks.OrderEventService)
+		mockEvents.On("LatestEventId", mock.Anything).Return(int64(5), nil).Once()
+		mockEvents.On("EventsAfter", mock.Anything, int64(5), 10).Return([]models.Event{{Id: 6}, {Id: 7}}, nil).Once()
+		mockEvents.On("EventsAfter", mock.Anything, int64(7), 10).Return([]models.Event{}, nil)
+
+		h := NewHub(mockEvents, hubOptions)
+		ctx := tenant.WithMerchantId(context.Background(), "merchant-1")
+
+		first, unsubscribeFirst, err := h.Subscribe(ctx)
+		assert.NoError(t, err)
+		second, unsubscribeSecond, err := h.Subscribe(ctx)
+		assert.NoError(t, err)
+
+		assert.Equal(t, int64(6), receive(t, first).Id)
+		assert.Equal(t, int64(7), receive(t, first).Id)
+		assert.Equal(t, int64(6), receive(t, second).Id)
+		assert.Equal(t, int64(7), receive(t, second).Id)
+
+		unsubscribeFirst()
+		unsubscribeSecond()
+		// unsubscribing twice is a no-op
+		unsubscribeSecond()
+
+		_, ok := <-first
+		assert.False(t, ok)
+		assert.Empty(t, h.feeds)
+		mockEvents.AssertNumberOfCalls(t, "LatestEventId", 1)
+	})
+
+	t.Run("drops events of a slow subscriber", func(t *testing.T) {
+		mockEvents := new(srvmocks.OrderEventService)
+		mockEvents.On("LatestEventId", mock.Anything).Return(int64(0), nil).Once()
+		mockEvents.On("EventsAfter", mock.Anything, int64(0), 10).Return([]models.Event{{Id: 1}, {Id: 2}}, nil).Once()
+		mockEvents.On("EventsAfter", mock.Anything, int64(2), 10).Return([]models.Event{}, nil)
+
+		h := NewHub(mockEvents, Options{PollInterval: hubOptions.PollInterval, BatchSize: 10, BufferSize: 1})
+
+		events, unsubscribe, err := h.Subscribe(tenant.WithMerchantId(context.Background(), "merchant-1"))
+		assert.NoError(t, err)
+		defer unsubscribe()
+
+		// nothing is read while both events are broadcast
+		time.Sleep(3 * hubOptions.PollInterval)
+		assert.Equal(t, int64(1), receive(t, events).Id)
+
+		select {
+		case event := <-events:
+			t.Fatalf("event %d was not dropped", event.Id)
+		case <-time.After(3 * hubOptions.PollInterval):
+		}
+	})
+
+	t.Run("requires a merchant", func(t *testing.T) {
+		h := NewHub(new(srvmocks.OrderEventService), hubOptions)
+
+		_, _, err := h.Subscribe(context.Background())
+		assert.Equal(t, models.ErrNoTenant, err)
+	})
+}
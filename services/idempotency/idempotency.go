@@ -0,0 +1,107 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"order-service/logger"
+	"order-service/models"
+	"order-service/repositories"
+	"order-service/services"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MaxKeyLength is the longest idempotency key a client may send
+const MaxKeyLength = 255
+
+type idempotencyService struct {
+	repo repositories.IdempotencyRepository
+	ttl  time.Duration
+}
+
+// NewIdempotencyService will create an IdempotencyService, keys older than ttl are forgotten unless ttl is zero
+func NewIdempotencyService(repo repositories.IdempotencyRepository, ttl time.Duration) services.IdempotencyService {
+	return &idempotencyService{
+		repo: repo,
+		ttl:  ttl,
+	}
+}
+
+func (s *idempotencyService) Begin(ctx context.Context, key, requestHash string) (*models.IdempotencyRecord, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/idempotency", "method": "Begin", "idempotency_key": key})
+
+	record := &models.IdempotencyRecord{Key: key, RequestHash: requestHash}
+
+	err := s.repo.Create(ctx, record)
+	if err == nil {
+		return record, nil
+	}
+	if err != models.ErrAlreadyExists {
+		log.WithError(err).Error("Failed to reserve idempotency key")
+		return nil, err
+	}
+
+	existing, err := s.repo.GetByKey(ctx, key)
+	if err == models.ErrNotFound {
+		// released by a failed request just now, the client should retry
+		return nil, services.ErrRequestInProgress
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to get idempotency key")
+		return nil, err
+	}
+
+	if s.expired(existing) {
+		log.Debug("Idempotency key expired, reserving it again")
+
+		// only the expired reservation is deleted, a concurrent request may have reserved the key again
+		deleted, err := s.repo.DeleteExpired(ctx, key, time.Now().Add(-s.ttl))
+		if err != nil {
+			log.WithError(err).Error("Failed to delete expired idempotency key")
+			return nil, err
+		}
+		if !deleted {
+			return nil, services.ErrRequestInProgress
+		}
+
+		err = s.repo.Create(ctx, record)
+		if err == models.ErrAlreadyExists {
+			return nil, services.ErrRequestInProgress
+		}
+		if err != nil {
+			log.WithError(err).Error("Failed to reserve idempotency key")
+			return nil, err
+		}
+
+		return record, nil
+	}
+
+	if existing.RequestHash != requestHash {
+		log.Debug("Idempotency key reused with a different request")
+		return nil, services.ErrIdempotencyKeyReused
+	}
+
+	if !existing.Completed() {
+		log.Debug("Request with idempotency key still in progress")
+		return nil, services.ErrRequestInProgress
+	}
+
+	return existing, nil
+}
+
+func (s *idempotencyService) expired(record *models.IdempotencyRecord) bool {
+	return s.ttl > 0 && time.Since(record.CreatedAt) > s.ttl
+}
+
+func (s *idempotencyService) Complete(ctx context.Context, key string, statusCode int, response []byte) error {
+	return s.repo.Update(ctx, &models.IdempotencyRecord{
+		Key:        key,
+		StatusCode: statusCode,
+		Response:   response,
+	})
+}
+
+func (s *idempotencyService) Release(ctx context.Context, key string) error {
+	return s.repo.Delete(ctx, key)
+}
@@ -0,0 +1,117 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order-service/models"
+	rpmocks "order-service/repositories/mocks"
+	"order-service/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIdempotencyService_Begin(t *testing.T) {
+	mockRepo := new(rpmocks.IdempotencyRepository)
+
+	completed := &models.IdempotencyRecord{
+		Key:         "key",
+		RequestHash: "hash",
+		StatusCode:  200,
+		Response:    []byte(`{"id":1}`),
+		CreatedAt:   time.Now(),
+	}
+
+	t.Run("new key is reserved", func(t *testing.T) {
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.IdempotencyRecord")).Return(nil).Once()
+		service := NewIdempotencyService(mockRepo, time.Hour)
+
+		record, err := service.Begin(context.Background(), "key", "hash")
+		assert.NoError(t, err)
+		assert.False(t, record.Completed())
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("retry with same body replays response", func(t *testing.T) {
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.IdempotencyRecord")).Return(models.ErrAlreadyExists).Once()
+		mockRepo.On("GetByKey", mock.Anything, "key").Return(completed, nil).Once()
+		service := NewIdempotencyService(mockRepo, time.Hour)
+
+		record, err := service.Begin(context.Background(), "key", "hash")
+		assert.NoError(t, err)
+		assert.True(t, record.Completed())
+		assert.Equal(t, completed.Response, record.Response)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("same key with different body", func(t *testing.T) {
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.IdempotencyRecord")).Return(models.ErrAlreadyExists).Once()
+		mockRepo.On("GetByKey", mock.Anything, "key").Return(completed, nil).Once()
+		service := NewIdempotencyService(mockRepo, time.Hour)
+
+		record, err := service.Begin(context.Background(), "key", "other-hash")
+		assert.EqualError(t, err, services.ErrIdempotencyKeyReused.Error())
+		assert.Nil(t, record)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("concurrent request still in progress", func(t *testing.T) {
+		pending := &models.IdempotencyRecord{Key: "key", RequestHash: "hash", CreatedAt: time.Now()}
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.IdempotencyRecord")).Return(models.ErrAlreadyExists).Once()
+		mockRepo.On("GetByKey", mock.Anything, "key").Return(pending, nil).Once()
+		service := NewIdempotencyService(mockRepo, time.Hour)
+
+		record, err := service.Begin(context.Background(), "key", "hash")
+		assert.EqualError(t, err, services.ErrRequestInProgress.Error())
+		assert.Nil(t, record)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("expired key is reserved again", func(t *testing.T) {
+		expired := &models.IdempotencyRecord{Key: "key", RequestHash: "other-hash", StatusCode: 200, CreatedAt: time.Now().Add(-2 * time.Hour)}
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.IdempotencyRecord")).Return(models.ErrAlreadyExists).Once()
+		mockRepo.On("GetByKey", mock.Anything, "key").Return(expired, nil).Once()
+		mockRepo.On("DeleteExpired", mock.Anything, "key", mock.AnythingOfType("time.Time")).Return(true, nil).Once()
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.IdempotencyRecord")).Return(nil).Once()
+		service := NewIdempotencyService(mockRepo, time.Hour)
+
+		record, err := service.Begin(context.Background(), "key", "hash")
+		assert.NoError(t, err)
+		assert.False(t, record.Completed())
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("expired key reserved again concurrently", func(t *testing.T) {
+		expired := &models.IdempotencyRecord{Key: "key", RequestHash: "hash", CreatedAt: time.Now().Add(-2 * time.Hour)}
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.IdempotencyRecord")).Return(models.ErrAlreadyExists).Once()
+		mockRepo.On("GetByKey", mock.Anything, "key").Return(expired, nil).Once()
+		// the other request deleted the expired key and reserved it first, its reservation is kept
+		mockRepo.On("DeleteExpired", mock.Anything, "key", mock.AnythingOfType("time.Time")).Return(false, nil).Once()
+		service := NewIdempotencyService(mockRepo, time.Hour)
+
+		record, err := service.Begin(context.Background(), "key", "hash")
+		assert.EqualError(t, err, services.ErrRequestInProgress.Error())
+		assert.Nil(t, record)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("error-failed", func(t *testing.T) {
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.IdempotencyRecord")).Return(errors.New("exception")).Once()
+		service := NewIdempotencyService(mockRepo, time.Hour)
+
+		record, err := service.Begin(context.Background(), "key", "hash")
+		assert.Error(t, err)
+		assert.Nil(t, record)
+
+		mockRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,14 @@
+package services
+
+import (
+	"context"
+
+	"order-service/models"
+)
+
+type Authenticator interface {
+	AuthenticateApiKey(ctx context.Context, key string) (*models.Principal, error)
+	AuthenticateToken(ctx context.Context, token string) (*models.Principal, error)
+	// CreateApiKey issues a new api key, the plain key is only returned here and never stored
+	CreateApiKey(ctx context.Context, name, role string) (string, *models.ApiKey, error)
+}
@@ -1,5 +1,7 @@
 package services
 
+import "context"
+
 type DistanceCalculator interface {
-	GetDistance(origins, destinations []string) (int, error)
+	GetDistance(ctx context.Context, origins, destinations []string) (int, error)
 }
@@ -0,0 +1,14 @@
+package services
+
+import (
+	"context"
+
+	"order-service/models"
+)
+
+// EventHub shares one feed of order events per merchant between all the subscribers of a replica
+type EventHub interface {
+	// Subscribe returns the events of the merchant carried by the context written from now on.
+	// The channel is closed by unsubscribe, which must be called once the events are no longer read
+	Subscribe(ctx context.Context) (events <-chan models.Event, unsubscribe func(), err error)
+}
@@ -0,0 +1,17 @@
+package services
+
+import (
+	"context"
+
+	"order-service/models"
+)
+
+type IdempotencyService interface {
+	// Begin reserves key for a request whose body hashes to requestHash. If the key was used by an
+	// earlier request with the same body, the returned record is completed and holds its response.
+	Begin(ctx context.Context, key, requestHash string) (*models.IdempotencyRecord, error)
+	// Complete stores the response of the request that reserved key
+	Complete(ctx context.Context, key string, statusCode int, response []byte) error
+	// Release frees key after a failed request, so the client can retry it
+	Release(ctx context.Context, key string) error
+}
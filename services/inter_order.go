@@ -1,10 +1,19 @@
 package services
 
-import "order-service/models"
+import (
+	"context"
+
+	"order-service/models"
+)
+
+// MaxPageLimit is the most items a page of a list holds, larger limits are capped to it
+// so a client cannot read a whole table at once
+const MaxPageLimit = 100
 
 type OrderService interface {
-	GetById(id int64) (*models.Order, error)
-	PlaceOrder(origins, destinations []string) (*models.Order, error)
-	TakeOrder(order *models.Order) (*models.Order, error)
-	ListOrders(offset, limit int) ([]models.Order, error)
+	GetById(ctx context.Context, id int64) (*models.Order, error)
+	GetByPublicId(ctx context.Context, publicId string) (*models.Order, error)
+	PlaceOrder(ctx context.Context, origins, destinations []string) (*models.Order, error)
+	TakeOrder(ctx context.Context, order *models.Order) (*models.Order, error)
+	ListOrders(ctx context.Context, offset, limit int) ([]models.Order, error)
 }
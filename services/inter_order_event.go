@@ -0,0 +1,15 @@
+package services
+
+import (
+	"context"
+
+	"order-service/models"
+)
+
+// OrderEventService reads the order events of the merchant carried by the context
+type OrderEventService interface {
+	// LatestEventId returns the id of the latest event, 0 if there is none
+	LatestEventId(ctx context.Context) (int64, error)
+	// EventsAfter returns up to limit events written after the event afterId, oldest first
+	EventsAfter(ctx context.Context, afterId int64, limit int) ([]models.Event, error)
+}
@@ -0,0 +1,13 @@
+package services
+
+import (
+	"context"
+
+	"order-service/models"
+)
+
+// Publisher hands domain events to a broker, events are published at least once so
+// consumers have to ignore an event id they have already seen
+type Publisher interface {
+	Publish(ctx context.Context, event *models.Event) error
+}
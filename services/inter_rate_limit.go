@@ -0,0 +1,14 @@
+package services
+
+import (
+	"context"
+
+	"order-service/models"
+)
+
+// RateLimiter counts the requests of a client against a limit. Implementations backed by a
+// shared store let several instances of the service enforce one limit together.
+type RateLimiter interface {
+	// Allow counts one request of the client identified by key and reports whether it is within limit
+	Allow(ctx context.Context, key string, limit models.RateLimit) (*models.RateLimitResult, error)
+}
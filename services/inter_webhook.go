@@ -0,0 +1,17 @@
+package services
+
+import (
+	"context"
+
+	"order-service/models"
+)
+
+type WebhookService interface {
+	// Subscribe registers url to receive events, deliveries are signed with secret
+	Subscribe(ctx context.Context, url string, events []string, secret string) (*models.Webhook, error)
+	// ListDeliveries returns the delivery attempts of a webhook, latest first
+	ListDeliveries(ctx context.Context, webhookId int64, offset, limit int) ([]models.WebhookDelivery, error)
+	// Publish queues a delivery of the order event to every webhook of its merchant subscribed to it,
+	// it is a Publisher of the outbox relay. An event relayed again is not delivered twice
+	Publish(ctx context.Context, event *models.Event) error
+}
@@ -2,6 +2,7 @@
 
 package mocks
 
+import context "context"
 import mock "github.com/stretchr/testify/mock"
 
 // DistanceCalculator is an autogenerated mock type for the DistanceCalculator type
@@ -9,20 +10,20 @@ type DistanceCalculator struct {
 	mock.Mock
 }
 
-// GetDistance provides a mock function with given fields: origins, destinations
-func (_m *DistanceCalculator) GetDistance(origins []string, destinations []string) (int, error) {
-	ret := _m.Called(origins, destinations)
+// GetDistance provides a mock function with given fields: ctx, origins, destinations
+func (_m *DistanceCalculator) GetDistance(ctx context.Context, origins []string, destinations []string) (int, error) {
+	ret := _m.Called(ctx, origins, destinations)
 
 	var r0 int
-	if rf, ok := ret.Get(0).(func([]string, []string) int); ok {
-		r0 = rf(origins, destinations)
+	if rf, ok := ret.Get(0).(func(context.Context, []string, []string) int); ok {
+		r0 = rf(ctx, origins, destinations)
 	} else {
 		r0 = ret.Get(0).(int)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func([]string, []string) error); ok {
-		r1 = rf(origins, destinations)
+	if rf, ok := ret.Get(1).(func(context.Context, []string, []string) error); ok {
+		r1 = rf(ctx, origins, destinations)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -2,6 +2,7 @@
 
 package mocks
 
+import context "context"
 import mock "github.com/stretchr/testify/mock"
 import models "order-service/models"
 
@@ -10,13 +11,13 @@ type OrderService struct {
 	mock.Mock
 }
 
-// GetById provides a mock function with given fields: id
-func (_m *OrderService) GetById(id int64) (*models.Order, error) {
-	ret := _m.Called(id)
+// GetById provides a mock function with given fields: ctx, id
+func (_m *OrderService) GetById(ctx context.Context, id int64) (*models.Order, error) {
+	ret := _m.Called(ctx, id)
 
 	var r0 *models.Order
-	if rf, ok := ret.Get(0).(func(int64) *models.Order); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, int64) *models.Order); ok {
+		r0 = rf(ctx, id)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.Order)
@@ -24,8 +25,8 @@ func (_m *OrderService) GetById(id int64) (*models.Order, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(int64) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -33,13 +34,36 @@ func (_m *OrderService) GetById(id int64) (*models.Order, error) {
 	return r0, r1
 }
 
-// ListOrders provides a mock function with given fields: offset, limit
-func (_m *OrderService) ListOrders(offset int, limit int) ([]models.Order, error) {
-	ret := _m.Called(offset, limit)
+// GetByPublicId provides a mock function with given fields: ctx, publicId
+func (_m *OrderService) GetByPublicId(ctx context.Context, publicId string) (*models.Order, error) {
+	ret := _m.Called(ctx, publicId)
+
+	var r0 *models.Order
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.Order); ok {
+		r0 = rf(ctx, publicId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Order)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, publicId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListOrders provides a mock function with given fields: ctx, offset, limit
+func (_m *OrderService) ListOrders(ctx context.Context, offset int, limit int) ([]models.Order, error) {
+	ret := _m.Called(ctx, offset, limit)
 
 	var r0 []models.Order
-	if rf, ok := ret.Get(0).(func(int, int) []models.Order); ok {
-		r0 = rf(offset, limit)
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) []models.Order); ok {
+		r0 = rf(ctx, offset, limit)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.Order)
@@ -47,8 +71,8 @@ func (_m *OrderService) ListOrders(offset int, limit int) ([]models.Order, error
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(int, int) error); ok {
-		r1 = rf(offset, limit)
+	if rf, ok := ret.Get(1).(func(context.Context, int, int) error); ok {
+		r1 = rf(ctx, offset, limit)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -56,13 +80,13 @@ func (_m *OrderService) ListOrders(offset int, limit int) ([]models.Order, error
 	return r0, r1
 }
 
-// PlaceOrder provides a mock function with given fields: origins, destinations
-func (_m *OrderService) PlaceOrder(origins []string, destinations []string) (*models.Order, error) {
-	ret := _m.Called(origins, destinations)
+// PlaceOrder provides a mock function with given fields: ctx, origins, destinations
+func (_m *OrderService) PlaceOrder(ctx context.Context, origins []string, destinations []string) (*models.Order, error) {
+	ret := _m.Called(ctx, origins, destinations)
 
 	var r0 *models.Order
-	if rf, ok := ret.Get(0).(func([]string, []string) *models.Order); ok {
-		r0 = rf(origins, destinations)
+	if rf, ok := ret.Get(0).(func(context.Context, []string, []string) *models.Order); ok {
+		r0 = rf(ctx, origins, destinations)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.Order)
@@ -70,8 +94,8 @@ func (_m *OrderService) PlaceOrder(origins []string, destinations []string) (*mo
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func([]string, []string) error); ok {
-		r1 = rf(origins, destinations)
+	if rf, ok := ret.Get(1).(func(context.Context, []string, []string) error); ok {
+		r1 = rf(ctx, origins, destinations)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -79,13 +103,13 @@ func (_m *OrderService) PlaceOrder(origins []string, destinations []string) (*mo
 	return r0, r1
 }
 
-// TakeOrder provides a mock function with given fields: order
-func (_m *OrderService) TakeOrder(order *models.Order) (*models.Order, error) {
-	ret := _m.Called(order)
+// TakeOrder provides a mock function with given fields: ctx, order
+func (_m *OrderService) TakeOrder(ctx context.Context, order *models.Order) (*models.Order, error) {
+	ret := _m.Called(ctx, order)
 
 	var r0 *models.Order
-	if rf, ok := ret.Get(0).(func(*models.Order) *models.Order); ok {
-		r0 = rf(order)
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Order) *models.Order); ok {
+		r0 = rf(ctx, order)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.Order)
@@ -93,8 +117,8 @@ func (_m *OrderService) TakeOrder(order *models.Order) (*models.Order, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(*models.Order) error); ok {
-		r1 = rf(order)
+	if rf, ok := ret.Get(1).(func(context.Context, *models.Order) error); ok {
+		r1 = rf(ctx, order)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -1,9 +1,12 @@
 package order
 
 import (
+	"context"
 	"strconv"
 	"strings"
 
+	"order-service/logger"
+	"order-service/metrics"
 	"order-service/models"
 	"order-service/repositories"
 	"order-service/services"
@@ -16,7 +19,8 @@ type orderService struct {
 	distanceCalculator services.DistanceCalculator
 }
 
-// NewOrderService will create new an OrderService object representation of OrderService interface
+// NewOrderService will create new an OrderService object representation of OrderService interface,
+// every status change is recorded in the outbox by the repository and notified to webhooks from there
 func NewOrderService(o repositories.OrderRepository, distanceCalculator services.DistanceCalculator) services.OrderService {
 	return &orderService{
 		orderRepo:          o,
@@ -24,18 +28,28 @@ func NewOrderService(o repositories.OrderRepository, distanceCalculator services
 	}
 }
 
-func (s *orderService) GetById(id int64) (*models.Order, error) {
-	return s.orderRepo.GetById(id)
+func (s *orderService) GetById(ctx context.Context, id int64) (*models.Order, error) {
+	return s.orderRepo.GetById(ctx, id)
 }
 
-func (s *orderService) PlaceOrder(origin, destination []string) (*models.Order, error) {
-	log := logrus.WithFields(logrus.Fields{"module": "service/order", "method": "PlaceOrder"})
+func (s *orderService) GetByPublicId(ctx context.Context, publicId string) (*models.Order, error) {
+	return s.orderRepo.GetByPublicId(ctx, publicId)
+}
+
+func (s *orderService) PlaceOrder(ctx context.Context, origin, destination []string) (*models.Order, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/order", "method": "PlaceOrder"})
 
 	distance, err := s.distanceCalculator.GetDistance(
+		ctx,
 		[]string{strings.Join(origin, ",")},
 		[]string{strings.Join(destination, ",")})
 	if err != nil {
 		log.WithError(err).Error("Failed to get distance")
+		if err == services.ErrCannotCalculateDistance {
+			metrics.PlaceOrders.WithLabelValues(metrics.OutcomeInvalidLocation).Inc()
+		} else {
+			metrics.PlaceOrders.WithLabelValues(metrics.OutcomeError).Inc()
+		}
 		return nil, err
 	}
 
@@ -58,40 +72,53 @@ func (s *orderService) PlaceOrder(origin, destination []string) (*models.Order,
 		Status:       models.StatusUnassigned,
 	}
 
-	order, err = s.orderRepo.Create(order)
+	// the order and its event are written in one transaction, the distance is known before it starts
+	err = s.orderRepo.WithTx(ctx, func(ctx context.Context, repo repositories.OrderRepository) error {
+		_, err := repo.Create(ctx, order)
+		return err
+	})
 	if err != nil {
 		log.WithError(err).Error("Failed to create order")
+		metrics.PlaceOrders.WithLabelValues(metrics.OutcomeError).Inc()
 		return nil, err
 	}
 
+	metrics.PlaceOrders.WithLabelValues(metrics.OutcomeSuccess).Inc()
 	return order, nil
 }
 
-func (s *orderService) TakeOrder(order *models.Order) (*models.Order, error) {
-	log := logrus.WithFields(logrus.Fields{"module": "service/order", "method": "TakeOrder", "order_id": order.Id, "current_status": order.Status})
+func (s *orderService) TakeOrder(ctx context.Context, order *models.Order) (*models.Order, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/order", "method": "TakeOrder", "order_id": order.Id, "current_status": order.Status})
 
 	if order.Status != models.StatusUnassigned {
 		log.Debug("Failed to take order, since it was already taken")
+		metrics.TakeOrders.WithLabelValues(metrics.OutcomeConflict).Inc()
 		return nil, services.ErrOrderAlreadyTaken
 	}
 
 	order.Status = models.StatusTaken
-	_, err := s.orderRepo.Update(order, models.StatusUnassigned)
+	err := s.orderRepo.WithTx(ctx, func(ctx context.Context, repo repositories.OrderRepository) error {
+		_, err := repo.Update(ctx, order, models.StatusUnassigned)
+		return err
+	})
 	if err == models.ErrCannotUpdate {
 		log.Debug("Failed to take order, since it was already taken")
+		metrics.TakeOrders.WithLabelValues(metrics.OutcomeConflict).Inc()
 		return nil, services.ErrOrderAlreadyTaken
 	} else if err != nil {
 		log.WithError(err).Error("Failed to take order")
+		metrics.TakeOrders.WithLabelValues(metrics.OutcomeError).Inc()
 		return nil, err
 	}
 
+	metrics.TakeOrders.WithLabelValues(metrics.OutcomeSuccess).Inc()
 	return order, nil
 }
 
-func (s *orderService) ListOrders(offset, limit int) ([]models.Order, error) {
-	log := logrus.WithFields(logrus.Fields{"module": "service/order", "method": "ListOrders", "offset": offset, "limit": limit})
+func (s *orderService) ListOrders(ctx context.Context, offset, limit int) ([]models.Order, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/order", "method": "ListOrders", "offset": offset, "limit": limit})
 
-	orders, err := s.orderRepo.List(offset, limit)
+	orders, err := s.orderRepo.List(ctx, offset, limit)
 	if err != nil {
 		log.WithError(err).Error("Failed to list orders")
 		return nil, err
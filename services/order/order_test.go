@@ -1,19 +1,35 @@
 package order
 
 import (
+	"context"
 	"errors"
 	"order-service/services"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"order-service/metrics"
 	"order-service/models"
+	"order-service/repositories"
 	rpmocks "order-service/repositories/mocks"
 	srvmocks "order-service/services/mocks"
+	"order-service/tenant"
 
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
+// runTxOn makes the transactions of repo run on repo itself
+func runTxOn(repo *rpmocks.OrderRepository) {
+	repo.On("WithTx", mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, fn func(context.Context, repositories.OrderRepository) error) error {
+			return fn(ctx, repo)
+		})
+}
+
 func TestOrderService_GetById(t *testing.T) {
 	mockOrderRepo := new(rpmocks.OrderRepository)
 	mockDistanceSrv := new(srvmocks.DistanceCalculator)
@@ -27,10 +43,10 @@ func TestOrderService_GetById(t *testing.T) {
 	}
 
 	t.Run("success", func(t *testing.T) {
-		mockOrderRepo.On("GetById", mock.AnythingOfType("int64")).Return(mockOrder, nil).Once()
+		mockOrderRepo.On("GetById", mock.Anything, mock.AnythingOfType("int64")).Return(mockOrder, nil).Once()
 		orderService := NewOrderService(mockOrderRepo, mockDistanceSrv)
 
-		order, err := orderService.GetById(mockOrder.Id)
+		order, err := orderService.GetById(context.Background(), mockOrder.Id)
 		assert.NoError(t, err)
 		assert.NotNil(t, order)
 
@@ -38,10 +54,10 @@ func TestOrderService_GetById(t *testing.T) {
 	})
 
 	t.Run("error-failed", func(t *testing.T) {
-		mockOrderRepo.On("GetById", mock.AnythingOfType("int64")).Return(nil, errors.New("exception")).Once()
+		mockOrderRepo.On("GetById", mock.Anything, mock.AnythingOfType("int64")).Return(nil, errors.New("exception")).Once()
 		orderService := NewOrderService(mockOrderRepo, mockDistanceSrv)
 
-		order, err := orderService.GetById(mockOrder.Id)
+		order, err := orderService.GetById(context.Background(), mockOrder.Id)
 		assert.Error(t, err)
 		assert.Nil(t, order)
 
@@ -51,6 +67,7 @@ func TestOrderService_GetById(t *testing.T) {
 
 func TestOrderService_PlaceOrder(t *testing.T) {
 	mockOrderRepo := new(rpmocks.OrderRepository)
+	runTxOn(mockOrderRepo)
 	mockDistanceSrv := new(srvmocks.DistanceCalculator)
 
 	origins := []float64{22.286681, 114.193260}
@@ -68,13 +85,13 @@ func TestOrderService_PlaceOrder(t *testing.T) {
 	}
 
 	t.Run("success", func(t *testing.T) {
-		mockDistanceSrv.On("GetDistance", []string{strings.Join(originStrs, ",")}, []string{strings.Join(destinationStrs, ",")}).
+		mockDistanceSrv.On("GetDistance", mock.Anything, []string{strings.Join(originStrs, ",")}, []string{strings.Join(destinationStrs, ",")}).
 			Return(100, nil).Once()
-		mockOrderRepo.On("Create", mockOrder).Return(mockOrder, nil).Once()
+		mockOrderRepo.On("Create", mock.Anything, mockOrder).Return(mockOrder, nil).Once()
 
 		orderService := NewOrderService(mockOrderRepo, mockDistanceSrv)
 
-		order, err := orderService.PlaceOrder(originStrs, destinationStrs)
+		order, err := orderService.PlaceOrder(context.Background(), originStrs, destinationStrs)
 		assert.NoError(t, err)
 		assert.NotNil(t, order)
 
@@ -82,12 +99,12 @@ func TestOrderService_PlaceOrder(t *testing.T) {
 	})
 
 	t.Run("on get distance failed", func(t *testing.T) {
-		mockDistanceSrv.On("GetDistance", []string{strings.Join(originStrs, ",")}, []string{strings.Join(destinationStrs, ",")}).
+		mockDistanceSrv.On("GetDistance", mock.Anything, []string{strings.Join(originStrs, ",")}, []string{strings.Join(destinationStrs, ",")}).
 			Return(0, errors.New("exception")).Once()
 
 		orderService := NewOrderService(mockOrderRepo, mockDistanceSrv)
 
-		order, err := orderService.PlaceOrder(originStrs, destinationStrs)
+		order, err := orderService.PlaceOrder(context.Background(), originStrs, destinationStrs)
 		assert.Error(t, err)
 		assert.Nil(t, order)
 
@@ -95,12 +112,12 @@ func TestOrderService_PlaceOrder(t *testing.T) {
 	})
 
 	t.Run("cannot get distance too far away", func(t *testing.T) {
-		mockDistanceSrv.On("GetDistance", []string{strings.Join(originStrs, ",")}, []string{strings.Join(destinationStrs, ",")}).
+		mockDistanceSrv.On("GetDistance", mock.Anything, []string{strings.Join(originStrs, ",")}, []string{strings.Join(destinationStrs, ",")}).
 			Return(0, services.ErrCannotCalculateDistance).Once()
 
 		orderService := NewOrderService(mockOrderRepo, mockDistanceSrv)
 
-		order, err := orderService.PlaceOrder(originStrs, destinationStrs)
+		order, err := orderService.PlaceOrder(context.Background(), originStrs, destinationStrs)
 		assert.Error(t, err)
 		assert.EqualError(t, err, services.ErrCannotCalculateDistance.Error())
 		assert.Nil(t, order)
@@ -108,14 +125,27 @@ func TestOrderService_PlaceOrder(t *testing.T) {
 		mockOrderRepo.AssertExpectations(t)
 	})
 
+	t.Run("get distance timed out", func(t *testing.T) {
+		mockDistanceSrv.On("GetDistance", mock.Anything, []string{strings.Join(originStrs, ",")}, []string{strings.Join(destinationStrs, ",")}).
+			Return(0, context.DeadlineExceeded).Once()
+
+		orderService := NewOrderService(mockOrderRepo, mockDistanceSrv)
+
+		order, err := orderService.PlaceOrder(context.Background(), originStrs, destinationStrs)
+		assert.True(t, services.IsTimeout(err))
+		assert.Nil(t, order)
+
+		mockOrderRepo.AssertExpectations(t)
+	})
+
 	t.Run("cannot create order", func(t *testing.T) {
-		mockDistanceSrv.On("GetDistance", []string{strings.Join(originStrs, ",")}, []string{strings.Join(destinationStrs, ",")}).
+		mockDistanceSrv.On("GetDistance", mock.Anything, []string{strings.Join(originStrs, ",")}, []string{strings.Join(destinationStrs, ",")}).
 			Return(100, nil).Once()
-		mockOrderRepo.On("Create", mockOrder).Return(nil, errors.New("exception")).Once()
+		mockOrderRepo.On("Create", mock.Anything, mockOrder).Return(nil, errors.New("exception")).Once()
 
 		orderService := NewOrderService(mockOrderRepo, mockDistanceSrv)
 
-		order, err := orderService.PlaceOrder(originStrs, destinationStrs)
+		order, err := orderService.PlaceOrder(context.Background(), originStrs, destinationStrs)
 		assert.Error(t, err)
 		assert.Nil(t, order)
 
@@ -125,6 +155,7 @@ func TestOrderService_PlaceOrder(t *testing.T) {
 
 func TestOrderService_TakeOrder(t *testing.T) {
 	mockOrderRepo := new(rpmocks.OrderRepository)
+	runTxOn(mockOrderRepo)
 	mockDistanceSrv := new(srvmocks.DistanceCalculator)
 
 	mockOrder := &models.Order{
@@ -136,12 +167,12 @@ func TestOrderService_TakeOrder(t *testing.T) {
 	}
 
 	t.Run("success", func(t *testing.T) {
-		mockOrderRepo.On("Update", mockOrder, models.StatusUnassigned).Return(mockOrder, nil).Once()
+		mockOrderRepo.On("Update", mock.Anything, mockOrder, models.StatusUnassigned).Return(mockOrder, nil).Once()
 
 		orderService := NewOrderService(mockOrderRepo, mockDistanceSrv)
 
 		mockOrder.Status = models.StatusUnassigned
-		order, err := orderService.TakeOrder(mockOrder)
+		order, err := orderService.TakeOrder(context.Background(), mockOrder)
 		assert.NoError(t, err)
 		assert.NotNil(t, order)
 
@@ -152,7 +183,7 @@ func TestOrderService_TakeOrder(t *testing.T) {
 		orderService := NewOrderService(mockOrderRepo, mockDistanceSrv)
 
 		mockOrder.Status = models.StatusTaken
-		order, err := orderService.TakeOrder(mockOrder)
+		order, err := orderService.TakeOrder(context.Background(), mockOrder)
 		assert.Error(t, err)
 		assert.EqualError(t, err, services.ErrOrderAlreadyTaken.Error())
 		assert.Nil(t, order)
@@ -161,18 +192,157 @@ func TestOrderService_TakeOrder(t *testing.T) {
 	})
 
 	t.Run("order already taken after querying db", func(t *testing.T) {
-		mockOrderRepo.On("Update", mockOrder, models.StatusUnassigned).Return(nil, models.ErrCannotUpdate).Once()
+		mockOrderRepo.On("Update", mock.Anything, mockOrder, models.StatusUnassigned).Return(nil, models.ErrCannotUpdate).Once()
 
 		orderService := NewOrderService(mockOrderRepo, mockDistanceSrv)
 
 		mockOrder.Status = models.StatusUnassigned
-		order, err := orderService.TakeOrder(mockOrder)
+		order, err := orderService.TakeOrder(context.Background(), mockOrder)
 		assert.Error(t, err)
 		assert.EqualError(t, err, services.ErrOrderAlreadyTaken.Error())
 		assert.Nil(t, order)
 
 		mockOrderRepo.AssertExpectations(t)
 	})
+
+	t.Run("take conflict is counted", func(t *testing.T) {
+		mockOrderRepo.On("Update", mock.Anything, mockOrder, models.StatusUnassigned).Return(nil, models.ErrCannotUpdate).Once()
+
+		orderService := NewOrderService(mockOrderRepo, mockDistanceSrv)
+
+		before := testutil.ToFloat64(metrics.TakeOrders.WithLabelValues(metrics.OutcomeConflict))
+
+		mockOrder.Status = models.StatusUnassigned
+		_, err := orderService.TakeOrder(context.Background(), mockOrder)
+		assert.EqualError(t, err, services.ErrOrderAlreadyTaken.Error())
+		assert.Equal(t, before+1, testutil.ToFloat64(metrics.TakeOrders.WithLabelValues(metrics.OutcomeConflict)))
+
+		mockOrderRepo.AssertExpectations(t)
+	})
+}
+
+func TestOrderService_TakeOrder_Concurrent(t *testing.T) {
+	orderRepo := repositories.NewMemoryOrderRepo()
+
+	orderService := NewOrderService(orderRepo, new(srvmocks.DistanceCalculator))
+	ctx := tenant.WithMerchantId(context.Background(), "merchant-1")
+
+	placed, err := orderRepo.Create(ctx, &models.Order{
+		Origins:      []float64{22.780247, 113.687473},
+		Destinations: []float64{22.217851, 114.207989},
+		Distance:     100,
+		Status:       models.StatusUnassigned,
+	})
+	assert.NoError(t, err)
+
+	// every driver read the order before any of them took it
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		order, err := orderRepo.GetById(ctx, placed.Id)
+		assert.NoError(t, err)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := orderService.TakeOrder(ctx, order)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	taken := 0
+	for err := range errs {
+		if err == nil {
+			taken++
+			continue
+		}
+		assert.Equal(t, services.ErrOrderAlreadyTaken, err)
+	}
+	assert.Equal(t, 1, taken)
+
+}
+
+// BenchmarkTakeOrder takes orders on sqlmock, once preparing the statements for every take as before they
+// were cached, and once reusing the cached statements. The cached run expects the statements to be prepared
+// once per repository, so a take preparing them again fails the benchmark
+func BenchmarkTakeOrder(b *testing.B) {
+	// sqlmock looks expectations up from the first one, so every repository only serves a batch of takes
+	const takesPerRepo = 100
+	// a transaction prepares every statement of the repository, the take runs the versioned update
+	queries := []string{
+		"UPDATE orders SET status = ?, updated_at = ?, version = version + 1 where id = ? AND merchant_id = ? AND status = ? AND deleted_at IS NULL",
+		"UPDATE orders SET status = ?, updated_at = ?, version = version + 1 where id = ? AND merchant_id = ? AND status = ? AND deleted_at IS NULL AND version = ?",
+		"INSERT INTO orders (public_id, merchant_id, origin_lat, origin_lng, destination_lat, destination_lng, distance, status, created_at, updated_at, version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)",
+		"UPDATE orders SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND merchant_id = ? AND deleted_at IS NULL",
+	}
+
+	for _, bm := range []struct {
+		name   string
+		cached bool
+	}{
+		{name: "prepared per call"},
+		{name: "cached statements", cached: true},
+	} {
+		bm := bm
+		b.Run(bm.name, func(b *testing.B) {
+			ctx := tenant.WithMerchantId(context.Background(), "merchant-1")
+
+			for taken := 0; taken < b.N; taken += takesPerRepo {
+				takes := b.N - taken
+				if takes > takesPerRepo {
+					takes = takesPerRepo
+				}
+
+				b.StopTimer()
+				db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				var prep *sqlmock.ExpectedPrepare
+				for i := 0; i < takes; i++ {
+					if prep == nil || !bm.cached {
+						for j, query := range queries {
+							if expected := mock.ExpectPrepare(query); j == 1 {
+								prep = expected
+							}
+						}
+					}
+					mock.ExpectBegin()
+					prep.ExpectExec().
+						WithArgs(models.StatusTaken, sqlmock.AnyArg(), taken+i+1, "merchant-1", models.StatusUnassigned, 1).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectExec("INSERT INTO outbox (event_type, order_id, merchant_id, payload) VALUES (?, ?, ?, ?)").
+						WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectCommit()
+				}
+
+				orderRepo := repositories.NewMysqlOrderRepo(db, time.Second)
+				orderService := NewOrderService(orderRepo, new(srvmocks.DistanceCalculator))
+				b.StartTimer()
+
+				for i := 0; i < takes; i++ {
+					order := &models.Order{Id: int64(taken + i + 1), Distance: 100, Status: models.StatusUnassigned, Version: 1}
+					if _, err := orderService.TakeOrder(ctx, order); err != nil {
+						b.Fatal(err)
+					}
+					if !bm.cached {
+						orderRepo.Close()
+					}
+				}
+
+				b.StopTimer()
+				if err := mock.ExpectationsWereMet(); err != nil {
+					b.Fatal(err)
+				}
+				orderRepo.Close()
+				db.Close()
+				b.StartTimer()
+			}
+		})
+	}
 }
 
 func TestOrderService_ListOrders(t *testing.T) {
@@ -200,10 +370,10 @@ func TestOrderService_ListOrders(t *testing.T) {
 	}
 
 	t.Run("success", func(t *testing.T) {
-		mockOrderRepo.On("List", mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(mockOrders, nil).Once()
+		mockOrderRepo.On("List", mock.Anything, mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(mockOrders, nil).Once()
 		orderService := NewOrderService(mockOrderRepo, mockDistanceSrv)
 
-		orders, err := orderService.ListOrders(1, 1)
+		orders, err := orderService.ListOrders(context.Background(), 1, 1)
 		assert.NoError(t, err)
 		assert.NotNil(t, orders)
 
@@ -211,10 +381,10 @@ func TestOrderService_ListOrders(t *testing.T) {
 	})
 
 	t.Run("error-failed", func(t *testing.T) {
-		mockOrderRepo.On("List", mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(nil, errors.New("exception")).Once()
+		mockOrderRepo.On("List", mock.Anything, mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(nil, errors.New("exception")).Once()
 		orderService := NewOrderService(mockOrderRepo, mockDistanceSrv)
 
-		orders, err := orderService.ListOrders(1, 1)
+		orders, err := orderService.ListOrders(context.Background(), 1, 1)
 		assert.Error(t, err)
 		assert.Nil(t, orders)
 
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"context"
+	"strconv"
+
+	"order-service/models"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// FindOrder loads the order of id, the public id of the order, or its sequential id while legacyIds
+// accepts them. An id which is neither is ErrInvalidOrderId
+func FindOrder(ctx context.Context, service OrderService, id string, legacyIds bool) (*models.Order, error) {
+	if seq, err := strconv.ParseInt(id, 10, 64); legacyIds && err == nil && seq >= 0 {
+		return service.GetById(ctx, seq)
+	}
+
+	publicId, err := ulid.ParseStrict(id)
+	if err != nil {
+		return nil, ErrInvalidOrderId
+	}
+
+	// ids are stored in their canonical upper case form
+	return service.GetByPublicId(ctx, publicId.String())
+}
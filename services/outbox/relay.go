@@ -0,0 +1,80 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"order-service/logger"
+	"order-service/repositories"
+	"order-service/services"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RelayOptions configures how often the outbox is relayed
+type RelayOptions struct {
+	// PollInterval is how often pending events are looked up
+	PollInterval time.Duration
+	// BatchSize is the maximum number of events published per poll
+	BatchSize int
+}
+
+// Relay publishes the events recorded in the outbox in the order they were written
+type Relay struct {
+	repo      repositories.OutboxRepository
+	publisher services.Publisher
+	opts      RelayOptions
+}
+
+// NewRelay will create a Relay publishing the pending events of repo through publisher
+func NewRelay(repo repositories.OutboxRepository, publisher services.Publisher, opts RelayOptions) *Relay {
+	return &Relay{
+		repo:      repo,
+		publisher: publisher,
+		opts:      opts,
+	}
+}
+
+// Run relays pending events every PollInterval until ctx is done
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.RelayPending(ctx)
+		}
+	}
+}
+
+// RelayPending publishes one batch of pending events and returns how many were published.
+// It stops at the first event that fails, so events are never published out of order.
+// An event that was published but could not be marked sent is published again by the next call.
+func (r *Relay) RelayPending(ctx context.Context) (int, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/outbox", "method": "RelayPending"})
+
+	events, err := r.repo.ListPending(ctx, r.opts.BatchSize)
+	if err != nil {
+		log.WithError(err).Error("Failed to list pending events")
+		return 0, err
+	}
+
+	for i := range events {
+		event := &events[i]
+
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			log.WithError(err).WithField("event_id", event.Id).Error("Failed to publish event")
+			return i, err
+		}
+
+		if err := r.repo.MarkSent(ctx, event.Id); err != nil {
+			log.WithError(err).WithField("event_id", event.Id).Error("Failed to mark event as sent")
+			return i + 1, err
+		}
+	}
+
+	return len(events), nil
+}
@@ -0,0 +1,77 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order-service/models"
+	rpmocks "order-service/repositories/mocks"
+	srvmocks "order-service/services/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var relayOptions = RelayOptions{PollInterval: time.Second, BatchSize: 10}
+
+func pendingEvents() []models.Event {
+	return []models.Event{
+		{Id: 1, Type: models.EventTypeOrderPlaced, OrderId: 1},
+		{Id: 2, Type: models.EventTypeOrderTaken, OrderId: 1},
+		{Id: 3, Type: models.EventTypeOrderPlaced, OrderId: 2},
+	}
+}
+
+func TestRelay_RelayPending(t *testing.T) {
+	t.Run("publishes and marks pending events in order", func(t *testing.T) {
+		mockRepo := new(rpmocks.OutboxRepository)
+		mockPublisher := new(srvmocks.Publisher)
+
+		var published []int64
+
+		mockRepo.On("ListPending", mock.Anything, 10).Return(pendingEvents(), nil).Once()
+		mockPublisher.On("Publish", mock.Anything, mock.AnythingOfType("*models.Event")).
+			Run(func(args mock.Arguments) { published = append(published, args.Get(1).(*models.Event).Id) }).
+			Return(nil).Times(3)
+		mockRepo.On("MarkSent", mock.Anything, mock.AnythingOfType("int64")).Return(nil).Times(3)
+
+		n, err := NewRelay(mockRepo, mockPublisher, relayOptions).RelayPending(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+		assert.Equal(t, []int64{1, 2, 3}, published)
+
+		mockRepo.AssertExpectations(t)
+		mockPublisher.AssertExpectations(t)
+	})
+
+	t.Run("stops at the first failed event", func(t *testing.T) {
+		mockRepo := new(rpmocks.OutboxRepository)
+		mockPublisher := new(srvmocks.Publisher)
+
+		mockRepo.On("ListPending", mock.Anything, 10).Return(pendingEvents(), nil).Once()
+		mockPublisher.On("Publish", mock.Anything, mock.MatchedBy(func(e *models.Event) bool { return e.Id == 1 })).Return(nil).Once()
+		mockPublisher.On("Publish", mock.Anything, mock.MatchedBy(func(e *models.Event) bool { return e.Id == 2 })).Return(errors.New("broker unavailable")).Once()
+		mockRepo.On("MarkSent", mock.Anything, int64(1)).Return(nil).Once()
+
+		n, err := NewRelay(mockRepo, mockPublisher, relayOptions).RelayPending(context.Background())
+		assert.Error(t, err)
+		assert.Equal(t, 1, n)
+
+		// event 3 is neither published nor marked before event 2
+		mockRepo.AssertExpectations(t)
+		mockPublisher.AssertExpectations(t)
+	})
+
+	t.Run("failed listing", func(t *testing.T) {
+		mockRepo := new(rpmocks.OutboxRepository)
+		mockPublisher := new(srvmocks.Publisher)
+
+		mockRepo.On("ListPending", mock.Anything, 10).Return(nil, errors.New("exception")).Once()
+
+		n, err := NewRelay(mockRepo, mockPublisher, relayOptions).RelayPending(context.Background())
+		assert.Error(t, err)
+		assert.Equal(t, 0, n)
+	})
+}
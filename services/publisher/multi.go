@@ -0,0 +1,28 @@
+package publisher
+
+import (
+	"context"
+
+	"order-service/models"
+	"order-service/services"
+)
+
+type multiPublisher struct {
+	publishers []services.Publisher
+}
+
+// NewMultiPublisher will create a Publisher handing every event to each of publishers in turn. It stops at the
+// first that fails, so the relay publishes the event again to all of them and each has to ignore events it has seen
+func NewMultiPublisher(publishers ...services.Publisher) services.Publisher {
+	return &multiPublisher{publishers: publishers}
+}
+
+func (p *multiPublisher) Publish(ctx context.Context, event *models.Event) error {
+	for _, publisher := range p.publishers {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
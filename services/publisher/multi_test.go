@@ -0,0 +1,38 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"order-service/models"
+	srvmocks "order-service/services/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMultiPublisher_Publish(t *testing.T) {
+	event := &models.Event{Id: 1, Type: models.EventTypeOrderPlaced, OrderId: 1}
+
+	t.Run("publishes to every publisher", func(t *testing.T) {
+		first, second := new(srvmocks.Publisher), new(srvmocks.Publisher)
+		first.On("Publish", mock.Anything, event).Return(nil).Once()
+		second.On("Publish", mock.Anything, event).Return(nil).Once()
+
+		assert.NoError(t, NewMultiPublisher(first, second).Publish(context.Background(), event))
+
+		first.AssertExpectations(t)
+		second.AssertExpectations(t)
+	})
+
+	t.Run("stops at the first failed publisher", func(t *testing.T) {
+		first, second := new(srvmocks.Publisher), new(srvmocks.Publisher)
+		first.On("Publish", mock.Anything, event).Return(errors.New("broker unavailable")).Once()
+
+		assert.Error(t, NewMultiPublisher(first, second).Publish(context.Background(), event))
+
+		first.AssertExpectations(t)
+		second.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,50 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"order-service/models"
+	"order-service/services"
+)
+
+type writerPublisher struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterPublisher will create a Publisher writing every event as one line of JSON to w
+func NewWriterPublisher(w io.Writer) services.Publisher {
+	return &writerPublisher{w: w}
+}
+
+// NewFilePublisher will create a Publisher appending events as JSON lines to the file at path,
+// "-" publishes to stdout. It is meant for local use in place of a broker
+func NewFilePublisher(path string) (services.Publisher, error) {
+	if path == "-" {
+		return NewWriterPublisher(os.Stdout), nil
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWriterPublisher(f), nil
+}
+
+func (p *writerPublisher) Publish(ctx context.Context, event *models.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, err = p.w.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,51 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"order-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriterPublisher_Publish(t *testing.T) {
+	var buf bytes.Buffer
+	publisher := NewWriterPublisher(&buf)
+
+	err := publisher.Publish(context.Background(), &models.Event{Id: 1, Type: models.EventTypeOrderPlaced, OrderId: 1, Payload: []byte(`{"id":1}`)})
+	assert.NoError(t, err)
+	err = publisher.Publish(context.Background(), &models.Event{Id: 2, Type: models.EventTypeOrderTaken, OrderId: 1, Payload: []byte(`{"id":1}`)})
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		var event models.Event
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &event))
+		assert.Equal(t, int64(2), event.Id)
+		assert.Equal(t, models.EventTypeOrderTaken, event.Type)
+		assert.JSONEq(t, `{"id":1}`, string(event.Payload))
+	}
+}
+
+func TestFilePublisher_Publish(t *testing.T) {
+	f, err := ioutil.TempFile("", "events-*.jsonl")
+	assert.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	publisher, err := NewFilePublisher(f.Name())
+	assert.NoError(t, err)
+
+	err = publisher.Publish(context.Background(), &models.Event{Id: 1, Type: models.EventTypeOrderPlaced, Payload: []byte(`{}`)})
+	assert.NoError(t, err)
+
+	bs, _ := ioutil.ReadFile(f.Name())
+	assert.Equal(t, 1, strings.Count(string(bs), "\n"))
+	assert.Contains(t, string(bs), `"type":"OrderPlaced"`)
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"time"
+
+	"order-service/logger"
+	"order-service/models"
+	"order-service/services"
+
+	"github.com/sirupsen/logrus"
+)
+
+// names of the rate limited operations, matching the keys of the configured limits. A REST route and
+// the gRPC method doing the same share the name, so a client is limited across both
+const (
+	PlaceOrder   = "place_order"
+	GetOrder     = "get_order"
+	TakeOrder    = "take_order"
+	ListOrders   = "list_orders"
+	CreateApiKey = "create_api_key"
+)
+
+// ClientKeys lists the buckets a request counts against, the IP of the client first and then its api key
+// if it sends one. Api keys are hashed so they are never kept in the limiter.
+func ClientKeys(ip, apiKey string) []string {
+	keys := []string{"ip:" + ip}
+	if apiKey != "" {
+		sum := sha256.Sum256([]byte(apiKey))
+		keys = append(keys, "key:"+hex.EncodeToString(sum[:]))
+	}
+
+	return keys
+}
+
+// Allow counts one request to route against the bucket of each of keys and returns the tightest result,
+// stopping at the first bucket over its limit. A bucket the limiter fails to check is skipped, since an
+// unavailable store must not take the service down with it, so the result is nil if no bucket was checked
+func Allow(ctx context.Context, limiter services.RateLimiter, route string, keys []string, limit models.RateLimit) *models.RateLimitResult {
+	var tightest *models.RateLimitResult
+	for _, key := range keys {
+		result, err := limiter.Allow(ctx, route+":"+key, limit)
+		if err != nil {
+			logger.FromContext(ctx).
+				WithFields(logrus.Fields{"module": "service/ratelimit", "method": "Allow", "route": route}).
+				WithError(err).Error("Failed to check rate limit, letting request through")
+			continue
+		}
+
+		if tightest == nil || !result.Allowed || result.Remaining < tightest.Remaining {
+			tightest = result
+		}
+		if !result.Allowed {
+			break
+		}
+	}
+
+	return tightest
+}
+
+// CeilSeconds rounds d up to whole seconds, so clients never retry too early
+func CeilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"order-service/models"
+	"order-service/services"
+)
+
+// sweepInterval is how often buckets that refilled completely are dropped, a full bucket
+// behaves exactly like a missing one so dropping it does not change any limit
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+}
+
+type memoryRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryRateLimiter will create a token bucket RateLimiter keeping its buckets in memory,
+// limits are enforced per instance of the service
+func NewMemoryRateLimiter() services.RateLimiter {
+	return newMemoryRateLimiter(time.Now)
+}
+
+func newMemoryRateLimiter(now func() time.Time) *memoryRateLimiter {
+	return &memoryRateLimiter{
+		buckets:   map[string]*bucket{},
+		lastSweep: now(),
+		now:       now,
+	}
+}
+
+func (l *memoryRateLimiter) Allow(ctx context.Context, key string, limit models.RateLimit) (*models.RateLimitResult, error) {
+	capacity := float64(limit.Requests)
+	// tokens refilled per second
+	rate := capacity / limit.Per.Seconds()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	result := &models.RateLimitResult{Limit: limit.Requests}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = seconds((capacity - b.tokens) / rate)
+	b.full = now.Add(result.ResetAfter)
+
+	return result, nil
+}
+
+// sweep drops the buckets which are full by now, at most once every sweepInterval
+func (l *memoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if !now.Before(b.full) {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.lastSweep = now
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// clock is a manually advanced time source
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestMemoryRateLimiter_Allow(t *testing.T) {
+	limit := models.RateLimit{Requests: 2, Per: 10 * time.Second}
+
+	t.Run("bursts up to the limit", func(t *testing.T) {
+		c := &clock{now: time.Now()}
+		limiter := newMemoryRateLimiter(c.Now)
+
+		result, err := limiter.Allow(context.Background(), "client", limit)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 2, result.Limit)
+		assert.Equal(t, 1, result.Remaining)
+		assert.Equal(t, 5*time.Second, result.ResetAfter)
+
+		result, _ = limiter.Allow(context.Background(), "client", limit)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+
+		result, _ = limiter.Allow(context.Background(), "client", limit)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+		assert.Equal(t, 5*time.Second, result.RetryAfter)
+		assert.Equal(t, 10*time.Second, result.ResetAfter)
+	})
+
+	t.Run("refills over time", func(t *testing.T) {
+		c := &clock{now: time.Now()}
+		limiter := newMemoryRateLimiter(c.Now)
+
+		limiter.Allow(context.Background(), "client", limit)
+		limiter.Allow(context.Background(), "client", limit)
+
+		c.Advance(4 * time.Second)
+		result, _ := limiter.Allow(context.Background(), "client", limit)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, time.Second, result.RetryAfter.Round(time.Millisecond))
+
+		c.Advance(time.Second)
+		result, _ = limiter.Allow(context.Background(), "client", limit)
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("clients have separate buckets", func(t *testing.T) {
+		c := &clock{now: time.Now()}
+		limiter := newMemoryRateLimiter(c.Now)
+
+		limiter.Allow(context.Background(), "client-1", limit)
+		limiter.Allow(context.Background(), "client-1", limit)
+
+		result, _ := limiter.Allow(context.Background(), "client-2", limit)
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("full buckets are swept", func(t *testing.T) {
+		c := &clock{now: time.Now()}
+		limiter := newMemoryRateLimiter(c.Now)
+
+		limiter.Allow(context.Background(), "client-1", limit)
+		c.Advance(sweepInterval)
+		limiter.Allow(context.Background(), "client-2", limit)
+
+		assert.Len(t, limiter.buckets, 1)
+		assert.Contains(t, limiter.buckets, "client-2")
+	})
+
+	t.Run("concurrent requests never exceed the limit", func(t *testing.T) {
+		limiter := NewMemoryRateLimiter()
+		limit := models.RateLimit{Requests: 50, Per: time.Hour}
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		allowed := 0
+
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				result, _ := limiter.Allow(context.Background(), "client", limit)
+				if result.Allowed {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 50, allowed)
+	})
+}
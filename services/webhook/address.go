@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// errForbiddenAddress is returned when a webhook would be sent to an address inside our network
+var errForbiddenAddress = errors.New("webhook address is not public")
+
+// lookupIPAddr resolves the host of a webhook url, a variable so tests do not depend on DNS
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// nonPublicNets are the ranges not covered by the net.IP methods which do not reach the internet either:
+// carrier-grade NAT (RFC 6598), used inside cloud networks, and "this network" (RFC 1122), which is
+// dialed as the local host on Linux
+var nonPublicNets = []*net.IPNet{
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("0.0.0.0/8"),
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return ipNet
+}
+
+// publicIP reports whether ip may receive webhooks. Loopback, private (RFC 1918 and RFC 4193),
+// carrier-grade NAT, link-local, including the cloud metadata address 169.254.169.254, multicast
+// and unspecified addresses would let a merchant make us send requests into our own network.
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, ipNet := range nonPublicNets {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicHost reports whether host, a name or an IP, only resolves to public addresses
+func publicHost(ctx context.Context, host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return publicIP(ip)
+	}
+
+	addrs, err := lookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return false
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewClient will create the http.Client sending deliveries, it checks the address every connection
+// is dialed to, so a host resolving to a public address at Subscribe cannot be pointed into our network later
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return errForbiddenAddress
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be dialed instead of the webhook, so the address could not be checked
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
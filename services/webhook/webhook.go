@@ -0,0 +1,148 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"order-service/logger"
+	"order-service/models"
+	"order-service/repositories"
+	"order-service/services"
+	"order-service/tenant"
+
+	"github.com/sirupsen/logrus"
+)
+
+// payload is the body POSTed to a webhook, Data is the order as of the event
+type payload struct {
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// webhookEvents names the webhook event of every type of order event
+var webhookEvents = map[string]string{
+	models.EventTypeOrderPlaced: models.EventOrderPlaced,
+	models.EventTypeOrderTaken:  models.EventOrderTaken,
+}
+
+type webhookService struct {
+	repo repositories.WebhookRepository
+}
+
+// NewWebhookService will create a WebhookService queueing deliveries in repo, they are sent by a Worker.
+// Deliveries are queued from the order events relayed from the outbox, so a change is notified
+// if and only if it was committed
+func NewWebhookService(repo repositories.WebhookRepository) services.WebhookService {
+	return &webhookService{
+		repo: repo,
+	}
+}
+
+func (s *webhookService) Subscribe(ctx context.Context, rawUrl string, events []string, secret string) (*models.Webhook, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/webhook", "method": "Subscribe", "events": events})
+
+	u, err := url.Parse(rawUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, services.ErrInvalidWebhookUrl
+	}
+	// deliveries must not reach into our own network, the worker checks the address again when it connects
+	if !publicHost(ctx, u.Hostname()) {
+		return nil, services.ErrWebhookUrlNotPublic
+	}
+
+	if len(events) == 0 {
+		return nil, services.ErrInvalidEvent
+	}
+	for _, event := range events {
+		if !models.ValidEvent(event) {
+			return nil, services.ErrInvalidEvent
+		}
+	}
+
+	webhook, err := s.repo.Create(ctx, &models.Webhook{
+		Url:    rawUrl,
+		Events: events,
+		Secret: secret,
+	})
+	if err != nil {
+		log.WithError(err).Error("Failed to create webhook")
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, webhookId int64, offset, limit int) ([]models.WebhookDelivery, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/webhook", "method": "ListDeliveries", "webhook_id": webhookId})
+
+	// make sure the webhook belongs to the merchant, so another merchant's webhook is not found
+	_, err := s.repo.GetById(ctx, webhookId)
+	if err != nil {
+		if err != models.ErrNotFound {
+			log.WithError(err).Error("Failed to get webhook")
+		}
+		return nil, err
+	}
+
+	deliveries, err := s.repo.ListDeliveries(ctx, webhookId, offset, limit)
+	if err != nil {
+		log.WithError(err).Error("Failed to list webhook deliveries")
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (s *webhookService) Publish(ctx context.Context, event *models.Event) error {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/webhook", "method": "Publish", "event_id": event.Id, "event_type": event.Type, "order_id": event.OrderId})
+
+	name, ok := webhookEvents[event.Type]
+	if !ok {
+		return nil
+	}
+
+	// the relay is not scoped to a merchant, the webhooks of the order's merchant are notified
+	ctx = tenant.WithMerchantId(ctx, event.MerchantId)
+
+	webhooks, err := s.repo.ListByEvent(ctx, name)
+	if err != nil {
+		log.WithError(err).Error("Failed to list webhooks")
+		return err
+	}
+
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(payload{Event: name, CreatedAt: event.CreatedAt, Data: event.Payload})
+	if err != nil {
+		log.WithError(err).Error("Failed to encode webhook payload")
+		return err
+	}
+
+	now := time.Now()
+
+	for _, webhook := range webhooks {
+		_, err := s.repo.CreateDelivery(ctx, &models.WebhookDelivery{
+			WebhookId:     webhook.Id,
+			EventId:       event.Id,
+			Event:         name,
+			Payload:       body,
+			Status:        models.DeliveryPending,
+			NextAttemptAt: now,
+		})
+		if err == models.ErrAlreadyExists {
+			// the event is relayed again after a failure, its delivery was queued the first time
+			continue
+		}
+		if err != nil {
+			log.WithError(err).WithField("webhook_id", webhook.Id).Error("Failed to queue webhook delivery")
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"order-service/models"
+	rpmocks "order-service/repositories/mocks"
+	"order-service/services"
+	"order-service/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// resolveTo makes every host resolve to ip for the duration of the test
+func resolveTo(t *testing.T, ip string) {
+	lookup := lookupIPAddr
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP(ip)}}, nil
+	}
+	t.Cleanup(func() { lookupIPAddr = lookup })
+}
+
+func TestWebhookService_Subscribe(t *testing.T) {
+	resolveTo(t, "93.184.216.34")
+
+	mockRepo := new(rpmocks.WebhookRepository)
+	service := NewWebhookService(mockRepo)
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.Webhook")).
+			Return(func(ctx context.Context, w *models.Webhook) *models.Webhook { w.Id = 1; return w }, nil).Once()
+
+		webhook, err := service.Subscribe(context.Background(), "https://example.com/hook", []string{models.EventOrderTaken}, "secret")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), webhook.Id)
+		assert.Equal(t, "secret", webhook.Secret)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid url", func(t *testing.T) {
+		for _, url := range []string{"ftp://example.com", "/hook", "example.com", "http://"} {
+			_, err := service.Subscribe(context.Background(), url, []string{models.EventOrderTaken}, "secret")
+			assert.Equal(t, services.ErrInvalidWebhookUrl, err, url)
+		}
+	})
+
+	t.Run("private address", func(t *testing.T) {
+		urls := []string{
+			"http://127.0.0.1/hook", "http://127.0.0.2:8080/hook", "http://[::1]/hook", "http://0.0.0.0/hook",
+			"http://10.0.0.1/hook", "http://172.16.5.4/hook", "https://192.168.1.1/hook",
+			"http://169.254.169.254/latest/meta-data", "http://[fe80::1]/hook", "http://[fd00::1]/hook",
+			"http://100.64.0.1/hook", "http://100.127.255.254/hook", "http://0.1.2.3/hook", "http://[::ffff:100.64.0.1]/hook",
+		}
+		for _, url := range urls {
+			_, err := service.Subscribe(context.Background(), url, []string{models.EventOrderTaken}, "secret")
+			assert.Equal(t, services.ErrWebhookUrlNotPublic, err, url)
+		}
+	})
+
+	t.Run("public address next to a private range", func(t *testing.T) {
+		for _, url := range []string{"http://100.63.255.255/hook", "http://100.128.0.1/hook", "http://1.0.0.1/hook"} {
+			mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.Webhook")).Return(&models.Webhook{Id: 1}, nil).Once()
+
+			_, err := service.Subscribe(context.Background(), url, []string{models.EventOrderTaken}, "secret")
+			assert.NoError(t, err, url)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("host resolving to a private address", func(t *testing.T) {
+		resolveTo(t, "10.1.2.3")
+
+		_, err := service.Subscribe(context.Background(), "https://internal.example.com/hook", []string{models.EventOrderTaken}, "secret")
+		assert.Equal(t, services.ErrWebhookUrlNotPublic, err)
+	})
+
+	t.Run("invalid event", func(t *testing.T) {
+		_, err := service.Subscribe(context.Background(), "https://example.com/hook", []string{"order.deleted"}, "secret")
+		assert.Equal(t, services.ErrInvalidEvent, err)
+
+		_, err = service.Subscribe(context.Background(), "https://example.com/hook", nil, "secret")
+		assert.Equal(t, services.ErrInvalidEvent, err)
+	})
+}
+
+func TestWebhookService_ListDeliveries(t *testing.T) {
+	mockRepo := new(rpmocks.WebhookRepository)
+	service := NewWebhookService(mockRepo)
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.On("GetById", mock.Anything, int64(1)).Return(&models.Webhook{Id: 1}, nil).Once()
+		mockRepo.On("ListDeliveries", mock.Anything, int64(1), 0, 10).Return([]models.WebhookDelivery{{Id: 2}}, nil).Once()
+
+		deliveries, err := service.ListDeliveries(context.Background(), 1, 0, 10)
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 1)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("webhook of another merchant", func(t *testing.T) {
+		mockRepo.On("GetById", mock.Anything, int64(2)).Return(nil, models.ErrNotFound).Once()
+
+		deliveries, err := service.ListDeliveries(context.Background(), 2, 0, 10)
+		assert.Equal(t, models.ErrNotFound, err)
+		assert.Nil(t, deliveries)
+
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestWebhookService_Publish(t *testing.T) {
+	mockRepo := new(rpmocks.WebhookRepository)
+	service := NewWebhookService(mockRepo)
+
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	event := &models.Event{
+		Id:         9,
+		Type:       models.EventTypeOrderTaken,
+		OrderId:    1,
+		MerchantId: "merchant-1",
+		Payload:    []byte(`{"id":"01KDZ3J0K8A7Z4W9X2M5N6P7Q8","distance":100,"status":"TAKEN"}`),
+		CreatedAt:  createdAt,
+	}
+	merchantCtx := mock.MatchedBy(func(ctx context.Context) bool {
+		merchantId, _ := tenant.MerchantId(ctx)
+		return merchantId == "merchant-1"
+	})
+
+	t.Run("queues a delivery per subscribed webhook of the merchant", func(t *testing.T) {
+		mockRepo.On("ListByEvent", merchantCtx, models.EventOrderTaken).
+			Return([]models.Webhook{{Id: 1}, {Id: 2}}, nil).Once()
+
+		for _, id := range []int64{1, 2} {
+			webhookId := id
+			mockRepo.On("CreateDelivery", merchantCtx, mock.MatchedBy(func(d *models.WebhookDelivery) bool {
+				var p map[string]interface{}
+				json.Unmarshal(d.Payload, &p)
+
+				return d.WebhookId == webhookId &&
+					d.EventId == 9 &&
+					d.Event == models.EventOrderTaken &&
+					d.Status == models.DeliveryPending &&
+					p["event"] == models.EventOrderTaken &&
+					p["created_at"] == "2026-01-02T03:04:05Z" &&
+					p["data"].(map[string]interface{})["id"] == "01KDZ3J0K8A7Z4W9X2M5N6P7Q8"
+			})).Return(&models.WebhookDelivery{}, nil).Once()
+		}
+
+		err := service.Publish(context.Background(), event)
+		assert.NoError(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("event relayed again", func(t *testing.T) {
+		mockRepo.On("ListByEvent", merchantCtx, models.EventOrderTaken).
+			Return([]models.Webhook{{Id: 1}, {Id: 2}}, nil).Once()
+		mockRepo.On("CreateDelivery", merchantCtx, mock.AnythingOfType("*models.WebhookDelivery")).
+			Return(nil, models.ErrAlreadyExists).Twice()
+
+		err := service.Publish(context.Background(), event)
+		assert.NoError(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("failed queueing is retried by the relay", func(t *testing.T) {
+		mockRepo.On("ListByEvent", merchantCtx, models.EventOrderTaken).
+			Return([]models.Webhook{{Id: 1}}, nil).Once()
+		mockRepo.On("CreateDelivery", merchantCtx, mock.AnythingOfType("*models.WebhookDelivery")).
+			Return(nil, errors.New("exception")).Once()
+
+		err := service.Publish(context.Background(), event)
+		assert.Error(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("no subscribed webhook", func(t *testing.T) {
+		placed := *event
+		placed.Type = models.EventTypeOrderPlaced
+		mockRepo.On("ListByEvent", merchantCtx, models.EventOrderPlaced).Return([]models.Webhook{}, nil).Once()
+
+		err := service.Publish(context.Background(), &placed)
+		assert.NoError(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("event without webhook event", func(t *testing.T) {
+		unknown := *event
+		unknown.Type = "OrderArchived"
+
+		err := service.Publish(context.Background(), &unknown)
+		assert.NoError(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+}
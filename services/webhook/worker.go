@@ -0,0 +1,207 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"order-service/logger"
+	"order-service/metrics"
+	"order-service/models"
+	"order-service/repositories"
+
+	"github.com/sirupsen/logrus"
+)
+
+// headers sent with every delivery
+const (
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// maxErrorLength bounds the error stored for a failed attempt
+const maxErrorLength = 1024
+
+// WorkerOptions configures how often deliveries are sent and retried
+type WorkerOptions struct {
+	// PollInterval is how often due deliveries are looked up
+	PollInterval time.Duration
+	// BatchSize is the maximum number of deliveries sent per poll
+	BatchSize int
+	// MaxAttempts is the number of attempts after which a delivery is dead
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry, it doubles with every further retry up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Lease is how long a claimed delivery is kept from other workers, it must outlast sending it
+	Lease time.Duration
+}
+
+// Worker sends the queued deliveries, retrying failed ones with exponential backoff
+type Worker struct {
+	repo   repositories.WebhookRepository
+	client *http.Client
+	opts   WorkerOptions
+	now    func() time.Time
+}
+
+// NewWorker will create a Worker sending the deliveries of repo with client
+func NewWorker(repo repositories.WebhookRepository, client *http.Client, opts WorkerOptions) *Worker {
+	return &Worker{
+		repo:   repo,
+		client: client,
+		opts:   opts,
+		now:    time.Now,
+	}
+}
+
+// Sign returns the signature of a delivery, the hex encoded HMAC-SHA256 of "<timestamp>.<body>"
+// keyed by the webhook secret. Receivers should compare it to the X-Webhook-Signature header
+// and reject old timestamps.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run sends due deliveries every PollInterval until ctx is done
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.DeliverDue(ctx)
+		}
+	}
+}
+
+// DeliverDue sends one batch of due deliveries and returns how many were attempted
+func (w *Worker) DeliverDue(ctx context.Context) (int, error) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/webhook", "method": "DeliverDue"})
+
+	deliveries, err := w.repo.ListDueDeliveries(ctx, w.now(), w.opts.BatchSize)
+	if err != nil {
+		log.WithError(err).Error("Failed to list due webhook deliveries")
+		return 0, err
+	}
+
+	attempted := 0
+	for i := range deliveries {
+		// deliveries are claimed one at a time, right before being sent, so the lease only has to cover one
+		err := w.repo.ClaimDelivery(ctx, deliveries[i].Id, w.now(), w.now().Add(w.opts.Lease))
+		if err == models.ErrCannotUpdate {
+			log.WithField("delivery_id", deliveries[i].Id).Debug("Webhook delivery claimed by another worker")
+			continue
+		}
+		if err != nil {
+			log.WithError(err).WithField("delivery_id", deliveries[i].Id).Error("Failed to claim webhook delivery")
+			continue
+		}
+
+		w.deliver(ctx, &deliveries[i])
+		attempted++
+	}
+
+	return attempted, nil
+}
+
+func (w *Worker) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"module": "service/webhook", "method": "deliver", "delivery_id": delivery.Id, "webhook_id": delivery.WebhookId, "attempt": delivery.Attempts + 1})
+
+	statusCode, err := w.send(ctx, delivery)
+
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = ""
+
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliverySucceeded
+		metrics.WebhookDeliveries.WithLabelValues(metrics.OutcomeSuccess).Inc()
+		log.Debug("Delivered webhook")
+	case delivery.Attempts >= w.opts.MaxAttempts:
+		delivery.Status = models.DeliveryDead
+		delivery.LastError = truncate(err.Error(), maxErrorLength)
+		metrics.WebhookDeliveries.WithLabelValues(metrics.OutcomeDead).Inc()
+		log.WithError(err).Warn("Failed to deliver webhook, giving up")
+	default:
+		delivery.NextAttemptAt = w.now().Add(w.backoff(delivery.Attempts))
+		delivery.LastError = truncate(err.Error(), maxErrorLength)
+		metrics.WebhookDeliveries.WithLabelValues(metrics.OutcomeRetry).Inc()
+		log.WithError(err).WithField("next_attempt_at", delivery.NextAttemptAt).Info("Failed to deliver webhook, will retry")
+	}
+
+	if err := w.repo.UpdateDelivery(ctx, delivery); err != nil {
+		log.WithError(err).Error("Failed to update webhook delivery")
+	}
+}
+
+// send POSTs the signed payload of delivery, any status but 2xx is an error
+func (w *Worker) send(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	timestamp := w.now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, delivery.Webhook.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.Id, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(delivery.Webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// drain the body, so the connection can be reused
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the retry following attempt
+func (w *Worker) backoff(attempt int) time.Duration {
+	d := w.opts.MinBackoff
+	for i := 1; i < attempt && d < w.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > w.opts.MaxBackoff {
+		return w.opts.MaxBackoff
+	}
+
+	return d
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return s[:n]
+}
@@ -0,0 +1,211 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"order-service/models"
+	rpmocks "order-service/repositories/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var workerOptions = WorkerOptions{
+	PollInterval: time.Second,
+	BatchSize:    10,
+	MaxAttempts:  3,
+	MinBackoff:   10 * time.Second,
+	MaxBackoff:   15 * time.Second,
+	Lease:        time.Minute,
+}
+
+func dueDelivery(url string, attempts int) models.WebhookDelivery {
+	return models.WebhookDelivery{
+		Id:        7,
+		WebhookId: 1,
+		Event:     models.EventOrderTaken,
+		Payload:   []byte(`{"event":"order.taken","data":{"id":1,"distance":100,"status":"TAKEN"}}`),
+		Status:    models.DeliveryPending,
+		Attempts:  attempts,
+		Webhook: &models.Webhook{
+			Id:     1,
+			Url:    url,
+			Events: []string{models.EventOrderTaken},
+			Secret: "secret",
+		},
+	}
+}
+
+func TestWorker_DeliverDue(t *testing.T) {
+	now := time.Now()
+
+	t.Run("delivers signed payload", func(t *testing.T) {
+		var req *http.Request
+		var body []byte
+
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req = r
+			body, _ = ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer receiver.Close()
+
+		mockRepo := new(rpmocks.WebhookRepository)
+		mockRepo.On("ListDueDeliveries", mock.Anything, now, 10).Return([]models.WebhookDelivery{dueDelivery(receiver.URL, 0)}, nil).Once()
+		mockRepo.On("ClaimDelivery", mock.Anything, int64(7), now, now.Add(time.Minute)).Return(nil).Once()
+		mockRepo.On("UpdateDelivery", mock.Anything, mock.MatchedBy(func(d *models.WebhookDelivery) bool {
+			return d.Status == models.DeliverySucceeded && d.Attempts == 1 && d.LastStatusCode == http.StatusNoContent
+		})).Return(nil).Once()
+
+		worker := NewWorker(mockRepo, receiver.Client(), workerOptions)
+		worker.now = func() time.Time { return now }
+
+		n, err := worker.DeliverDue(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		if assert.NotNil(t, req) {
+			timestamp, _ := strconv.ParseInt(req.Header.Get(TimestampHeader), 10, 64)
+			assert.Equal(t, now.Unix(), timestamp)
+			assert.Equal(t, Sign("secret", timestamp, body), req.Header.Get(SignatureHeader))
+			assert.Equal(t, models.EventOrderTaken, req.Header.Get(EventHeader))
+			assert.Equal(t, "7", req.Header.Get(DeliveryHeader))
+			assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+			assert.JSONEq(t, `{"event":"order.taken","data":{"id":1,"distance":100,"status":"TAKEN"}}`, string(body))
+		}
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("failed delivery is retried with backoff", func(t *testing.T) {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer receiver.Close()
+
+		mockRepo := new(rpmocks.WebhookRepository)
+		mockRepo.On("ListDueDeliveries", mock.Anything, now, 10).Return([]models.WebhookDelivery{dueDelivery(receiver.URL, 0)}, nil).Once()
+		mockRepo.On("ClaimDelivery", mock.Anything, int64(7), now, now.Add(time.Minute)).Return(nil).Once()
+		mockRepo.On("UpdateDelivery", mock.Anything, mock.MatchedBy(func(d *models.WebhookDelivery) bool {
+			return d.Status == models.DeliveryPending &&
+				d.Attempts == 1 &&
+				d.LastStatusCode == http.StatusInternalServerError &&
+				d.LastError == "unexpected status 500" &&
+				d.NextAttemptAt.Equal(now.Add(10*time.Second))
+		})).Return(nil).Once()
+
+		worker := NewWorker(mockRepo, receiver.Client(), workerOptions)
+		worker.now = func() time.Time { return now }
+
+		_, err := worker.DeliverDue(context.Background())
+		assert.NoError(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("delivery is dead after the last attempt", func(t *testing.T) {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer receiver.Close()
+
+		mockRepo := new(rpmocks.WebhookRepository)
+		mockRepo.On("ListDueDeliveries", mock.Anything, now, 10).Return([]models.WebhookDelivery{dueDelivery(receiver.URL, 2)}, nil).Once()
+		mockRepo.On("ClaimDelivery", mock.Anything, int64(7), now, now.Add(time.Minute)).Return(nil).Once()
+		mockRepo.On("UpdateDelivery", mock.Anything, mock.MatchedBy(func(d *models.WebhookDelivery) bool {
+			return d.Status == models.DeliveryDead && d.Attempts == 3
+		})).Return(nil).Once()
+
+		worker := NewWorker(mockRepo, receiver.Client(), workerOptions)
+		worker.now = func() time.Time { return now }
+
+		_, err := worker.DeliverDue(context.Background())
+		assert.NoError(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("unreachable receiver is retried", func(t *testing.T) {
+		receiver := httptest.NewServer(http.NotFoundHandler())
+		url := receiver.URL
+		receiver.Close()
+
+		mockRepo := new(rpmocks.WebhookRepository)
+		mockRepo.On("ListDueDeliveries", mock.Anything, now, 10).Return([]models.WebhookDelivery{dueDelivery(url, 0)}, nil).Once()
+		mockRepo.On("ClaimDelivery", mock.Anything, int64(7), now, now.Add(time.Minute)).Return(nil).Once()
+		mockRepo.On("UpdateDelivery", mock.Anything, mock.MatchedBy(func(d *models.WebhookDelivery) bool {
+			return d.Status == models.DeliveryPending && d.LastStatusCode == 0 && d.LastError != ""
+		})).Return(nil).Once()
+
+		worker := NewWorker(mockRepo, http.DefaultClient, workerOptions)
+		worker.now = func() time.Time { return now }
+
+		_, err := worker.DeliverDue(context.Background())
+		assert.NoError(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("delivery claimed by another worker is not sent", func(t *testing.T) {
+		var received bool
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { received = true }))
+		defer receiver.Close()
+
+		mockRepo := new(rpmocks.WebhookRepository)
+		mockRepo.On("ListDueDeliveries", mock.Anything, now, 10).Return([]models.WebhookDelivery{dueDelivery(receiver.URL, 0)}, nil).Once()
+		mockRepo.On("ClaimDelivery", mock.Anything, int64(7), now, now.Add(time.Minute)).Return(models.ErrCannotUpdate).Once()
+
+		worker := NewWorker(mockRepo, receiver.Client(), workerOptions)
+		worker.now = func() time.Time { return now }
+
+		n, err := worker.DeliverDue(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+		assert.False(t, received)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("private address is refused when connecting", func(t *testing.T) {
+		// the host of the webhook now resolves into our network, here the loopback of the test server
+		var received bool
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { received = true }))
+		defer receiver.Close()
+
+		mockRepo := new(rpmocks.WebhookRepository)
+		mockRepo.On("ListDueDeliveries", mock.Anything, now, 10).Return([]models.WebhookDelivery{dueDelivery(receiver.URL, 0)}, nil).Once()
+		mockRepo.On("ClaimDelivery", mock.Anything, int64(7), now, now.Add(time.Minute)).Return(nil).Once()
+		mockRepo.On("UpdateDelivery", mock.Anything, mock.MatchedBy(func(d *models.WebhookDelivery) bool {
+			return d.Status == models.DeliveryPending && strings.Contains(d.LastError, errForbiddenAddress.Error())
+		})).Return(nil).Once()
+
+		worker := NewWorker(mockRepo, NewClient(time.Second), workerOptions)
+		worker.now = func() time.Time { return now }
+
+		_, err := worker.DeliverDue(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, received)
+
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestWorker_Backoff(t *testing.T) {
+	worker := NewWorker(nil, nil, workerOptions)
+
+	assert.Equal(t, 10*time.Second, worker.backoff(1))
+	assert.Equal(t, 15*time.Second, worker.backoff(2))
+	assert.Equal(t, 15*time.Second, worker.backoff(10))
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '1600000000.{}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=1e56a11da123b137c26fa37b7c222060bdf22988aa9b3248c31244f8b2ef4a28", Sign("secret", 1600000000, []byte("{}")))
+}
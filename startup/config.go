@@ -3,6 +3,11 @@ package startup
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"order-service/models"
 
 	"github.com/joho/godotenv"
 )
@@ -18,21 +23,288 @@ func init() {
 
 	Config = &Configuration{
 		Database: Database{
-			UserName: os.Getenv("MYSQL_USER"),
-			Password: os.Getenv("MYSQL_PASSWORD"),
-			DbName:   os.Getenv("MYSQL_DATABASE"),
+			UserName:    os.Getenv("MYSQL_USER"),
+			Password:    os.Getenv("MYSQL_PASSWORD"),
+			DbName:      os.Getenv("MYSQL_DATABASE"),
+			Driver:      getEnv("DB_DRIVER", DriverMysql),
+			OrderDriver: getEnv("ORDER_DB_DRIVER", getEnv("DB_DRIVER", DriverMysql)),
+			PostgresDSN: os.Getenv("POSTGRES_DSN"),
+			SqlitePath:  getEnv("SQLITE_PATH", "order.db"),
+			ReplicaDSNs: getEnvList("ORDER_DB_REPLICA_DSNS"),
+		},
+		Pool: Pool{
+			MaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 25),
+			ConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+			ConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", time.Minute),
+			ConnectMaxWait:  getEnvDuration("DB_CONNECT_MAX_WAIT", time.Minute),
+			ReadRetries:     getEnvInt("DB_READ_RETRIES", 2),
+		},
+		GoogleApiKey:      os.Getenv("GOOGLE_API_KEY"),
+		GrpcAddr:          getEnv("GRPC_ADDR", ":9090"),
+		IdempotencyKeyTTL: getEnvDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+		LegacyOrderIds:    getEnv("LEGACY_ORDER_IDS", "true") == "true",
+		Auth: Auth{
+			HS256Secret: os.Getenv("JWT_HS256_SECRET"),
+			JWKSFile:    os.Getenv("JWT_JWKS_FILE"),
+			Issuer:      os.Getenv("JWT_ISSUER"),
+			Audience:    os.Getenv("JWT_AUDIENCE"),
+		},
+		Tracing: Tracing{
+			Endpoint:    os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+			Insecure:    os.Getenv("OTEL_EXPORTER_OTLP_INSECURE") == "true",
+			ServiceName: getEnv("OTEL_SERVICE_NAME", "order-service"),
+		},
+		Timeout: Timeout{
+			Request:  getEnvDuration("REQUEST_TIMEOUT", 10*time.Second),
+			Database: getEnvDuration("DB_TIMEOUT", 3*time.Second),
+			Distance: getEnvDuration("DISTANCE_TIMEOUT", 5*time.Second),
+		},
+		Webhook: Webhook{
+			PollInterval: getEnvDuration("WEBHOOK_POLL_INTERVAL", time.Second),
+			Timeout:      getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+			MaxAttempts:  getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+			MinBackoff:   getEnvDuration("WEBHOOK_MIN_BACKOFF", 10*time.Second),
+			MaxBackoff:   getEnvDuration("WEBHOOK_MAX_BACKOFF", time.Hour),
+			Lease:        getEnvDuration("WEBHOOK_LEASE", time.Minute),
+		},
+		Outbox: Outbox{
+			PollInterval:  getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+			PublisherFile: getEnv("OUTBOX_PUBLISHER_FILE", "-"),
+		},
+		Archive: Archive{
+			Interval:   getEnvDuration("ARCHIVE_INTERVAL", time.Hour),
+			After:      getEnvDuration("ARCHIVE_AFTER", 30*24*time.Hour),
+			BatchSize:  getEnvInt("ARCHIVE_BATCH_SIZE", 500),
+			BatchPause: getEnvDuration("ARCHIVE_BATCH_PAUSE", 100*time.Millisecond),
+		},
+		PublicIdBackfill: PublicIdBackfill{
+			BatchSize:  getEnvInt("PUBLIC_ID_BACKFILL_BATCH_SIZE", 500),
+			BatchPause: getEnvDuration("PUBLIC_ID_BACKFILL_BATCH_PAUSE", 100*time.Millisecond),
+		},
+		Stream: Stream{
+			PollInterval:      getEnvDuration("ORDER_STREAM_POLL_INTERVAL", time.Second),
+			HeartbeatInterval: getEnvDuration("ORDER_STREAM_HEARTBEAT_INTERVAL", 15*time.Second),
+			SettleWindow:      getEnvDuration("ORDER_STREAM_SETTLE_WINDOW", 10*time.Second),
+		},
+		DriverChannel: DriverChannel{
+			PingPeriod: getEnvDuration("DRIVER_CHANNEL_PING_PERIOD", 30*time.Second),
+		},
+		RateLimits: map[string]models.RateLimit{
+			"place_order":    getEnvRateLimit("RATE_LIMIT_PLACE_ORDER", models.RateLimit{Requests: 30, Per: time.Minute}),
+			"get_order":      getEnvRateLimit("RATE_LIMIT_GET_ORDER", models.RateLimit{Requests: 120, Per: time.Minute}),
+			"take_order":     getEnvRateLimit("RATE_LIMIT_TAKE_ORDER", models.RateLimit{Requests: 120, Per: time.Minute}),
+			"list_orders":    getEnvRateLimit("RATE_LIMIT_LIST_ORDERS", models.RateLimit{Requests: 120, Per: time.Minute}),
+			"create_api_key": getEnvRateLimit("RATE_LIMIT_CREATE_API_KEY", models.RateLimit{Requests: 10, Per: time.Minute}),
 		},
-		GoogleApiKey: os.Getenv("GOOGLE_API_KEY"),
 	}
 }
 
 type Configuration struct {
 	Database     Database
+	Pool         Pool
 	GoogleApiKey string
+	// GrpcAddr is the address the gRPC server listens on
+	GrpcAddr          string
+	IdempotencyKeyTTL time.Duration
+	Auth              Auth
+	Tracing           Tracing
+	Timeout           Timeout
+	Webhook           Webhook
+	Outbox            Outbox
+	Archive           Archive
+	PublicIdBackfill  PublicIdBackfill
+	Stream            Stream
+	DriverChannel     DriverChannel
+	// LegacyOrderIds accepts the sequential ids of orders besides their public ids, while clients migrate
+	LegacyOrderIds bool
+	// RateLimits holds the limit of each rate limited route by name
+	RateLimits map[string]models.RateLimit
 }
 
+// drivers of the databases, postgres only stores orders
+const (
+	DriverMysql    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSqlite   = "sqlite"
+)
+
+// Database holds the database settings. Everything is stored in the MySQL database of the credentials,
+// or with Driver "sqlite" in the SQLite file at SqlitePath, which needs no database server.
+// Orders and their outbox are stored in the database of OrderDriver, it defaults to Driver and with
+// "postgres" they are stored in the Postgres database of PostgresDSN instead. Orders are listed and
+// read from the replicas of ReplicaDSNs, in the DSN format of OrderDriver, when there are some
 type Database struct {
-	UserName string
-	Password string
-	DbName   string
+	UserName    string
+	Password    string
+	DbName      string
+	Driver      string
+	OrderDriver string
+	PostgresDSN string
+	SqlitePath  string
+	ReplicaDSNs []string
+}
+
+// Pool configures the connections to MySQL and Postgres. Zero MaxOpenConns means no limit and zero lifetimes
+// keep connections forever. The service waits up to ConnectMaxWait for a database at startup, and reads
+// failing on a broken connection are retried ReadRetries times
+type Pool struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	ConnectMaxWait  time.Duration
+	ReadRetries     int
+}
+
+// Auth holds the keys verifying bearer tokens, HS256Secret for HS256 and JWKSFile for RS256
+type Auth struct {
+	HS256Secret string
+	JWKSFile    string
+	Issuer      string
+	Audience    string
+}
+
+// Tracing holds the OTLP/HTTP collector settings, an empty Endpoint disables exporting
+type Tracing struct {
+	Endpoint    string
+	Insecure    bool
+	ServiceName string
+}
+
+// Timeout holds the deadline applied to each layer, zero means no deadline
+type Timeout struct {
+	Request  time.Duration
+	Database time.Duration
+	Distance time.Duration
+}
+
+// Webhook configures the delivery of webhooks, a failed delivery is retried after MinBackoff,
+// doubling up to MaxBackoff, until it failed MaxAttempts times. A delivery being sent is kept from
+// the workers of other replicas for Lease, which must outlast Timeout
+type Webhook struct {
+	PollInterval time.Duration
+	Timeout      time.Duration
+	MaxAttempts  int
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+	Lease        time.Duration
+}
+
+// Outbox configures the relay of domain events, they are appended as JSON lines to PublisherFile, "-" means stdout
+type Outbox struct {
+	PollInterval  time.Duration
+	PublisherFile string
+}
+
+// Archive configures the archival of orders, every Interval the orders done or deleted for longer than After
+// are moved to the archive BatchSize at a time, pausing BatchPause between batches. Zero Interval disables it
+type Archive struct {
+	Interval   time.Duration
+	After      time.Duration
+	BatchSize  int
+	BatchPause time.Duration
+}
+
+// PublicIdBackfill configures giving a public id to the orders placed before public ids, BatchSize orders
+// at a time pausing BatchPause between batches. A BatchSize of zero or less disables it
+type PublicIdBackfill struct {
+	BatchSize  int
+	BatchPause time.Duration
+}
+
+// Stream configures the order event streams, each stream polls the outbox every PollInterval
+// and sends a heartbeat comment every HeartbeatInterval to keep idle connections open. Events are
+// read again for SettleWindow, which must outlast the transactions writing them, so events committing
+// after events with higher ids are not skipped
+type Stream struct {
+	PollInterval      time.Duration
+	HeartbeatInterval time.Duration
+	SettleWindow      time.Duration
+}
+
+// DriverChannel configures the WebSocket connections of drivers, they are pinged every PingPeriod
+// and closed after two periods without a reply
+type DriverChannel struct {
+	PingPeriod time.Duration
+}
+
+// getEnv returns the value of key from env, falling back to def if unset
+func getEnv(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return def
+}
+
+// getEnvList splits the comma separated values of key from env, it is empty if unset
+func getEnvList(key string) []string {
+	values := make([]string, 0)
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// getEnvDuration parses a duration like "500ms" or "3s" from env, falling back to def if unset
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid duration for %s, value=%s, err=%+v\n", key, value, err)
+	}
+
+	return d
+}
+
+// getEnvInt parses an integer from env, falling back to def if unset
+func getEnvInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid integer for %s, value=%s, err=%+v\n", key, value, err)
+	}
+
+	return i
+}
+
+// getEnvRateLimit parses a limit like "30/1m" (30 requests per minute) from env, falling back to def if unset.
+// "0" disables the limit
+func getEnvRateLimit(key string, def models.RateLimit) models.RateLimit {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	if value == "0" {
+		return models.RateLimit{}
+	}
+
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		log.Fatalf("Invalid rate limit for %s, value=%s, expected requests/duration\n", key, value)
+	}
+
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests < 0 {
+		log.Fatalf("Invalid rate limit for %s, value=%s, err=%+v\n", key, value, err)
+	}
+
+	per, err := time.ParseDuration(parts[1])
+	if err != nil || per <= 0 {
+		log.Fatalf("Invalid rate limit for %s, value=%s, err=%+v\n", key, value, err)
+	}
+
+	return models.RateLimit{Requests: requests, Per: per}
 }
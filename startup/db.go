@@ -3,7 +3,7 @@ package startup
 import (
 	"database/sql"
 	"fmt"
-	"os"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/sirupsen/logrus"
@@ -11,47 +11,316 @@ import (
 
 var Db *sql.DB
 
+// OrderDb holds the orders and their outbox, it is Db unless the order driver differs from the driver of Db
+var OrderDb *sql.DB
+
+// OrderReplicas are the read replicas of OrderDb, orders are listed and read from them
+var OrderReplicas []*sql.DB
+
 func Init() {
 	log := logrus.WithFields(logrus.Fields{"module": "startup"})
 
-	dbName := Config.Database.DbName
-	userName := Config.Database.UserName
-	password := Config.Database.Password
+	switch Config.Database.Driver {
+	case DriverMysql:
+		Db = initMysql()
+	case DriverSqlite:
+		Db = initSqlite()
+	default:
+		log.Fatalf("Unknown database driver %s", Config.Database.Driver)
+	}
 
-	dataSource := fmt.Sprintf("%s:%s@tcp(mysql)/%s", userName, password, dbName)
-	//dataSource := fmt.Sprintf("%s:%s@tcp(127.0.0.1:3306)/%s", userName, password, dbName)
+	switch Config.Database.OrderDriver {
+	case Config.Database.Driver:
+		OrderDb = Db
+	case DriverMysql:
+		OrderDb = initMysql()
+	case DriverPostgres:
+		OrderDb = initPostgres()
+	case DriverSqlite:
+		OrderDb = initSqlite()
+	default:
+		log.Fatalf("Unknown order database driver %s", Config.Database.OrderDriver)
+	}
+
+	OrderReplicas = initReplicas()
+}
 
-	dbConn, err := sql.Open(`mysql`, dataSource)
+// connect opens the database of dsn with the configured pool and pings it until it answers, backing off
+// between attempts for up to Pool.ConnectMaxWait, so the service waits for a database starting after it
+func connect(driverName, dsn string) (*sql.DB, error) {
+	log := logrus.WithFields(logrus.Fields{"module": "startup", "driver": driverName})
+	pool := Config.Pool
+
+	dbConn, err := sql.Open(driverName, dsn)
 	if err != nil {
-		log.Error("Failed to connect to database")
-		os.Exit(1)
+		return nil, err
 	}
 
-	err = dbConn.Ping()
-	if err != nil {
-		log.Fatal(err)
-		os.Exit(1)
+	dbConn.SetMaxOpenConns(pool.MaxOpenConns)
+	dbConn.SetMaxIdleConns(pool.MaxIdleConns)
+	dbConn.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	dbConn.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+
+	deadline := time.Now().Add(pool.ConnectMaxWait)
+	backoff := connectMinBackoff
+
+	for {
+		err = dbConn.Ping()
+		if err == nil {
+			return dbConn, nil
+		}
+
+		if time.Now().Add(backoff).After(deadline) {
+			dbConn.Close()
+			return nil, err
+		}
+
+		log.WithError(err).WithField("retry_in", backoff).Warn("Database is not ready")
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > connectMaxBackoff {
+			backoff = connectMaxBackoff
+		}
+	}
+}
+
+// backoff between the connection attempts of connect
+const (
+	connectMinBackoff = 500 * time.Millisecond
+	connectMaxBackoff = 5 * time.Second
+)
+
+// initReplicas connects to the read replicas of the order database, they are written by replication
+// so no table is created
+func initReplicas() []*sql.DB {
+	log := logrus.WithFields(logrus.Fields{"module": "startup", "driver": Config.Database.OrderDriver})
+
+	replicas := make([]*sql.DB, 0, len(Config.Database.ReplicaDSNs))
+	if len(Config.Database.ReplicaDSNs) == 0 {
+		return replicas
+	}
+	if Config.Database.OrderDriver == DriverSqlite {
+		log.Fatal("SQLite has no read replicas")
+	}
+
+	for i, dsn := range Config.Database.ReplicaDSNs {
+		dbConn, err := connect(Config.Database.OrderDriver, dsn)
+		if err != nil {
+			log.WithError(err).WithField("replica", i).Fatal("Failed to connect to order replica")
+		}
+
+		replicas = append(replicas, dbConn)
 	}
 
-	Db = dbConn
+	log.WithField("replicas", len(replicas)).Info("Successfully connected to order replicas...")
+
+	return replicas
+}
+
+// initMysql connects to the MySQL database and creates its tables
+func initMysql() *sql.DB {
+	log := logrus.WithFields(logrus.Fields{"module": "startup", "driver": DriverMysql})
+
+	dbName := Config.Database.DbName
+	userName := Config.Database.UserName
+	password := Config.Database.Password
+
+	dataSource := fmt.Sprintf("%s:%s@tcp(mysql)/%s?parseTime=true", userName, password, dbName)
+	//dataSource := fmt.Sprintf("%s:%s@tcp(127.0.0.1:3306)/%s?parseTime=true", userName, password, dbName)
+
+	dbConn, err := connect(DriverMysql, dataSource)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to connect to database")
+	}
 
 	log.Info("Successfully connected to database...")
 
-	initTables()
+	initTables(dbConn)
+
+	return dbConn
 }
 
 var createTableStat = `CREATE TABLE IF NOT EXISTS orders (
     id BIGINT(20) UNSIGNED AUTO_INCREMENT PRIMARY KEY NOT NULL,
+    public_id CHAR(26) NULL,
+    merchant_id VARCHAR(64) NOT NULL,
     origin_lat DOUBLE NOT NULL,
     origin_lng DOUBLE NOT NULL,
     destination_lat DOUBLE NOT NULL,
     destination_lng DOUBLE NOT NULL,
     status VARCHAR(20) NOT NULL,
-    distance INT UNSIGNED NOT NULL
+    distance INT UNSIGNED NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
+    deleted_at TIMESTAMP NULL,
+    UNIQUE KEY uniq_public_id (public_id),
+    KEY idx_merchant_id (merchant_id, id),
+    KEY idx_created_at (created_at)
 ) ENGINE=InnoDB AUTO_INCREMENT=32 DEFAULT CHARSET=utf8;
 `
 
-func initTables() {
-	// create order table if not exists
-	Db.Exec(createTableStat)
+var createOrderArchiveTableStat = `CREATE TABLE IF NOT EXISTS orders_archive (
+    id BIGINT(20) UNSIGNED PRIMARY KEY NOT NULL,
+    public_id CHAR(26) NULL,
+    merchant_id VARCHAR(64) NOT NULL,
+    origin_lat DOUBLE NOT NULL,
+    origin_lng DOUBLE NOT NULL,
+    destination_lat DOUBLE NOT NULL,
+    destination_lng DOUBLE NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    distance INT UNSIGNED NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
+    deleted_at TIMESTAMP NULL,
+    archived_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uniq_public_id (public_id),
+    KEY idx_merchant_id (merchant_id, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+`
+
+var createIdempotencyTableStat = `CREATE TABLE IF NOT EXISTS idempotency_keys (
+    merchant_id VARCHAR(64) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INT NOT NULL DEFAULT 0,
+    response MEDIUMBLOB NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (merchant_id, idempotency_key)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+`
+
+var createApiKeyTableStat = `CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGINT(20) UNSIGNED AUTO_INCREMENT PRIMARY KEY NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    merchant_id VARCHAR(64) NOT NULL,
+    key_hash CHAR(64) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP NULL,
+    UNIQUE KEY uniq_key_hash (key_hash)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+`
+
+var createWebhookTableStat = `CREATE TABLE IF NOT EXISTS webhooks (
+    id BIGINT(20) UNSIGNED AUTO_INCREMENT PRIMARY KEY NOT NULL,
+    merchant_id VARCHAR(64) NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    events VARCHAR(255) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_merchant_id (merchant_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+`
+
+var createWebhookDeliveryTableStat = `CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGINT(20) UNSIGNED AUTO_INCREMENT PRIMARY KEY NOT NULL,
+    webhook_id BIGINT(20) UNSIGNED NOT NULL,
+    event_id BIGINT(20) UNSIGNED NULL,
+    event VARCHAR(64) NOT NULL,
+    payload MEDIUMBLOB NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_status_code INT NOT NULL DEFAULT 0,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_webhook_id (webhook_id, id),
+    UNIQUE KEY uniq_webhook_event (webhook_id, event_id),
+    KEY idx_status_next_attempt_at (status, next_attempt_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+`
+
+var createOutboxTableStat = `CREATE TABLE IF NOT EXISTS outbox (
+    id BIGINT(20) UNSIGNED AUTO_INCREMENT PRIMARY KEY NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    order_id BIGINT(20) UNSIGNED NOT NULL,
+    merchant_id VARCHAR(64) NOT NULL,
+    payload MEDIUMBLOB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP NULL,
+    KEY idx_sent_at (sent_at, id),
+    KEY idx_merchant_id (merchant_id, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+`
+
+// tenantMigrationStats add the merchant columns to tables created before orders were scoped to merchants,
+// existing rows get an empty merchant id which no caller can authenticate as.
+// They fail harmlessly once the columns exist
+var tenantMigrationStats = []string{
+	"ALTER TABLE orders ADD COLUMN merchant_id VARCHAR(64) NOT NULL DEFAULT '' AFTER id, ADD KEY idx_merchant_id (merchant_id, id)",
+	"ALTER TABLE api_keys ADD COLUMN merchant_id VARCHAR(64) NOT NULL DEFAULT '' AFTER name",
+	"ALTER TABLE idempotency_keys ADD COLUMN merchant_id VARCHAR(64) NOT NULL DEFAULT '' FIRST, DROP PRIMARY KEY, ADD PRIMARY KEY (merchant_id, idempotency_key)",
+}
+
+// archiveMigrationStats add the columns of soft deletes and archival to orders tables created before them,
+// existing orders are considered placed at the migration. They fail harmlessly once the columns exist
+var archiveMigrationStats = []string{
+	"ALTER TABLE orders ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, ADD COLUMN deleted_at TIMESTAMP NULL, ADD KEY idx_created_at (created_at)",
+}
+
+// versionMigrationStats add the update time and version of orders to tables created before them, existing
+// orders are considered updated at the migration. They fail harmlessly once the columns exist
+var versionMigrationStats = []string{
+	"ALTER TABLE orders ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP AFTER created_at, ADD COLUMN version BIGINT(20) UNSIGNED NOT NULL DEFAULT 1 AFTER updated_at",
+	"ALTER TABLE orders_archive ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP AFTER created_at, ADD COLUMN version BIGINT(20) UNSIGNED NOT NULL DEFAULT 1 AFTER updated_at",
+}
+
+// publicIdMigrationStats add the public ids of orders to tables created before them, existing orders get
+// theirs from OrderRepo.AssignPublicIds. They fail harmlessly once the columns exist
+var publicIdMigrationStats = []string{
+	"ALTER TABLE orders ADD COLUMN public_id CHAR(26) NULL AFTER id, ADD UNIQUE KEY uniq_public_id (public_id)",
+	"ALTER TABLE orders_archive ADD COLUMN public_id CHAR(26) NULL AFTER id, ADD UNIQUE KEY uniq_public_id (public_id)",
+}
+
+// webhookEventMigrationStats add the outbox event of deliveries to tables created before deliveries were queued
+// from the outbox, existing deliveries have none. They fail harmlessly once the column exists
+var webhookEventMigrationStats = []string{
+	"ALTER TABLE webhook_deliveries ADD COLUMN event_id BIGINT(20) UNSIGNED NULL AFTER webhook_id, ADD UNIQUE KEY uniq_webhook_event (webhook_id, event_id)",
+}
+
+func initTables(db *sql.DB) {
+	// create order tables if not exists
+	db.Exec(createTableStat)
+	db.Exec(createOrderArchiveTableStat)
+
+	// create idempotency key table if not exists
+	db.Exec(createIdempotencyTableStat)
+
+	// create api key table if not exists
+	db.Exec(createApiKeyTableStat)
+
+	// create webhook tables if not exists
+	db.Exec(createWebhookTableStat)
+	db.Exec(createWebhookDeliveryTableStat)
+
+	// create outbox table if not exists
+	db.Exec(createOutboxTableStat)
+
+	// scope tables of older deployments to merchants
+	for _, stat := range tenantMigrationStats {
+		db.Exec(stat)
+	}
+
+	// add soft deletes to orders of older deployments
+	for _, stat := range archiveMigrationStats {
+		db.Exec(stat)
+	}
+
+	// add versions to orders of older deployments
+	for _, stat := range versionMigrationStats {
+		db.Exec(stat)
+	}
+
+	// add public ids to orders of older deployments
+	for _, stat := range publicIdMigrationStats {
+		db.Exec(stat)
+	}
+
+	// queue webhook deliveries of older deployments once per event
+	for _, stat := range webhookEventMigrationStats {
+		db.Exec(stat)
+	}
 }
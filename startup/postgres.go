@@ -0,0 +1,91 @@
+package startup
+
+import (
+	"database/sql"
+
+	_ "github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// initPostgres connects to the Postgres database of the orders and creates their tables
+func initPostgres() *sql.DB {
+	log := logrus.WithFields(logrus.Fields{"module": "startup", "driver": DriverPostgres})
+
+	dbConn, err := connect(DriverPostgres, Config.Database.PostgresDSN)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to connect to order database")
+	}
+
+	log.Info("Successfully connected to order database...")
+
+	for _, stat := range postgresTableStats {
+		if _, err := dbConn.Exec(stat); err != nil {
+			log.WithError(err).Fatal("Failed to create order tables")
+		}
+	}
+
+	return dbConn
+}
+
+// postgresTableStats create the tables of the orders, their archive and their outbox, coordinates are plain
+// double precision columns so the database needs no extension
+var postgresTableStats = []string{
+	`CREATE TABLE IF NOT EXISTS orders (
+    id BIGSERIAL PRIMARY KEY,
+    public_id CHAR(26) NULL UNIQUE,
+    merchant_id VARCHAR(64) NOT NULL,
+    origin_lat DOUBLE PRECISION NOT NULL,
+    origin_lng DOUBLE PRECISION NOT NULL,
+    destination_lat DOUBLE PRECISION NOT NULL,
+    destination_lng DOUBLE PRECISION NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    distance INTEGER NOT NULL CHECK (distance >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    version BIGINT NOT NULL DEFAULT 1,
+    deleted_at TIMESTAMPTZ NULL
+)`,
+	`CREATE INDEX IF NOT EXISTS idx_orders_merchant_id ON orders (merchant_id, id)`,
+	// orders tables created before soft deletes and archival, existing orders are considered placed at the migration
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now()`,
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ NULL`,
+	`CREATE INDEX IF NOT EXISTS idx_orders_created_at ON orders (created_at)`,
+	// orders tables created before versions, existing orders are considered updated at the migration
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now()`,
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1`,
+	// orders tables created before public ids, existing orders get theirs from OrderRepo.AssignPublicIds
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS public_id CHAR(26) NULL`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS uniq_orders_public_id ON orders (public_id)`,
+	`CREATE TABLE IF NOT EXISTS orders_archive (
+    id BIGINT PRIMARY KEY,
+    public_id CHAR(26) NULL UNIQUE,
+    merchant_id VARCHAR(64) NOT NULL,
+    origin_lat DOUBLE PRECISION NOT NULL,
+    origin_lng DOUBLE PRECISION NOT NULL,
+    destination_lat DOUBLE PRECISION NOT NULL,
+    destination_lng DOUBLE PRECISION NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    distance INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    version BIGINT NOT NULL DEFAULT 1,
+    deleted_at TIMESTAMPTZ NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`,
+	`ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now()`,
+	`ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1`,
+	`ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS public_id CHAR(26) NULL`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS uniq_orders_archive_public_id ON orders_archive (public_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_orders_archive_merchant_id ON orders_archive (merchant_id, id)`,
+	`CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    order_id BIGINT NOT NULL,
+    merchant_id VARCHAR(64) NOT NULL,
+    payload BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at TIMESTAMPTZ NULL
+)`,
+	`CREATE INDEX IF NOT EXISTS idx_outbox_sent_at ON outbox (sent_at, id)`,
+	`CREATE INDEX IF NOT EXISTS idx_outbox_merchant_id ON outbox (merchant_id, id)`,
+}
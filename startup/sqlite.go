@@ -0,0 +1,23 @@
+package startup
+
+import (
+	"database/sql"
+
+	"order-service/repositories"
+
+	"github.com/sirupsen/logrus"
+)
+
+// initSqlite opens the SQLite database file and creates its tables, the file is created if missing
+func initSqlite() *sql.DB {
+	log := logrus.WithFields(logrus.Fields{"module": "startup", "driver": DriverSqlite, "path": Config.Database.SqlitePath})
+
+	dbConn, err := repositories.OpenSqlite(Config.Database.SqlitePath)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to open database")
+	}
+
+	log.Info("Successfully opened database...")
+
+	return dbConn
+}
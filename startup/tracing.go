@@ -0,0 +1,53 @@
+package startup
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+// InitTracing installs the W3C trace context propagator and, when an OTLP endpoint is configured,
+// a tracer provider exporting spans to it. The returned function flushes pending spans on shutdown.
+func InitTracing() func(context.Context) error {
+	log := logrus.WithFields(logrus.Fields{"module": "startup"})
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if Config.Tracing.Endpoint == "" {
+		log.Info("Tracing exporter disabled, since no OTLP endpoint is configured")
+		return func(context.Context) error { return nil }
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(Config.Tracing.Endpoint)}
+	if Config.Tracing.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		log.WithError(err).Error("Failed to create tracing exporter, tracing disabled")
+		return func(context.Context) error { return nil }
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(Config.Tracing.ServiceName),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	log.WithField("endpoint", Config.Tracing.Endpoint).Info("Exporting traces to OTLP collector")
+
+	return provider.Shutdown
+}
@@ -0,0 +1,18 @@
+package tenant
+
+import "context"
+
+type ctxKey int
+
+const merchantIdKey ctxKey = iota
+
+// WithMerchantId returns a copy of ctx scoped to the given merchant
+func WithMerchantId(ctx context.Context, merchantId string) context.Context {
+	return context.WithValue(ctx, merchantIdKey, merchantId)
+}
+
+// MerchantId returns the merchant ctx is scoped to, ok is false if ctx carries no merchant
+func MerchantId(ctx context.Context) (merchantId string, ok bool) {
+	merchantId, _ = ctx.Value(merchantIdKey).(string)
+	return merchantId, merchantId != ""
+}
@@ -0,0 +1,27 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerchantId(t *testing.T) {
+	t.Run("with merchant", func(t *testing.T) {
+		merchantId, ok := MerchantId(WithMerchantId(context.Background(), "merchant-1"))
+
+		assert.True(t, ok)
+		assert.Equal(t, "merchant-1", merchantId)
+	})
+
+	t.Run("without merchant", func(t *testing.T) {
+		_, ok := MerchantId(context.Background())
+		assert.False(t, ok)
+	})
+
+	t.Run("with empty merchant", func(t *testing.T) {
+		_, ok := MerchantId(WithMerchantId(context.Background(), ""))
+		assert.False(t, ok)
+	})
+}